@@ -1,16 +1,40 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
-	"github.com/go-chi/jwtauth"
 	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/handlers"
-	"net/http"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/leobelini-studies/go_expert_api/configs"
 	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/batch"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/broker"
 	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/graph"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/grpc/pb"
+	grpcserver "github.com/leobelini-studies/go_expert_api/internal/infra/grpc/server"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/grpc/service"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/health"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/exporter"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/importer"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/lifecycle"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/lockout"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/mail"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/metrics"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/outbox"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/ratelimit"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/realtime"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/tlsconfig"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/tracing"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webhook"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -31,50 +55,486 @@ import (
 
 // @host                       localhost:8081
 // @basePath                   /
+// @schemes                    http https
 // @securityDefinitions.apikey ApiKeyAuth
 // @in                         header
 // @name                       Authorization
+
+// @securityDefinitions.apikey XAPIKeyAuth
+// @in                         header
+// @name                       X-API-Key
 func main() {
 	config, err := configs.LoadConfig(".")
 	if err != nil {
 		panic(err)
 	}
 
+	log := logger.New(os.Stdout, config.Log.Level, config.Log.Format)
+	slog.SetDefault(log)
+
+	// settings the API cannot run with stop it before anything is started
+	if err := config.Validate(); err != nil {
+		log.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     config.Tracing.Exporter,
+		ServiceName:  config.Tracing.ServiceName,
+		OTLPEndpoint: config.Tracing.OTLPEndpoint,
+		OTLPInsecure: config.Tracing.OTLPInsecure,
+		FilePath:     config.Tracing.FilePath,
+		SampleRatio:  config.Tracing.SampleRatio,
+	})
+	if err != nil {
+		panic(err)
+	}
+
 	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
 	if err != nil {
 		panic(err)
 	}
 
-	db.AutoMigrate(&entity.Product{}, &entity.User{})
+	if err := db.Use(tracing.NewGormPlugin(nil)); err != nil {
+		panic(err)
+	}
+
+	m := metrics.New()
+	if err := m.InstrumentDB(db, "main"); err != nil {
+		panic(err)
+	}
+
+	models := []interface{}{&entity.Product{}, &entity.User{}, &entity.UserToken{}, &entity.RecoveryCode{}, &entity.APIKey{}, &entity.OAuthClient{}, &entity.RevokedToken{}, &entity.IdempotencyKey{}, &entity.ImportJob{}, &entity.ExportJob{}, &entity.Webhook{}, &entity.WebhookDelivery{}, &entity.OutboxMessage{}}
+	db.AutoMigrate(models...)
+
+	healthChecks := health.NewRegistry(time.Second * time.Duration(config.API.HealthCheckTimeout))
+	healthChecks.Register("database", health.DBPing(db), 0)
+	healthChecks.Register("migrations", health.Migrations(db, models...), 0)
+	healthChecks.Register("config", health.Config(config.Validate), 0)
+
+	var mailer mail.Mailer
+	switch config.Mail.Driver {
+	case "smtp":
+		mailer = mail.NewSMTPMailer(config.Mail.SMTPHost, config.Mail.SMTPPort, config.Mail.SMTPUser, config.Mail.SMTPPassword, config.Mail.From)
+	case "file":
+		mailer, err = mail.OpenFileMailer(config.Mail.FilePath, config.Mail.From)
+		if err != nil {
+			panic(err)
+		}
+	default:
+		mailer = mail.NewStdoutMailer(config.Mail.From)
+	}
+
+	ipResolver, err := middlewares.NewIPResolver(strings.Split(config.API.TrustedProxies, ","))
+	if err != nil {
+		panic(err)
+	}
+	// every route group has its own buckets, so exhausting one leaves the
+	// others alone; public groups count per IP, the others per user
+	rateLimitStore := ratelimit.NewMemoryStore()
+	productsRateLimit := middlewares.RateLimit(rateLimitStore, "products",
+		ratelimit.PerMinute(config.API.RateLimitProductsPerMinute, config.API.RateLimitProductsBurst), middlewares.KeyBySubject)
+	realtimeRateLimit := middlewares.RateLimit(rateLimitStore, "realtime",
+		ratelimit.PerMinute(config.API.RateLimitRealtimePerMinute, config.API.RateLimitRealtimeBurst), middlewares.KeyBySubject)
+	authRateLimit := middlewares.RateLimit(rateLimitStore, "auth",
+		ratelimit.PerMinute(config.API.RateLimitAuthPerMinute, config.API.RateLimitAuthBurst), middlewares.KeyByIP)
+	oauthRateLimit := middlewares.RateLimit(rateLimitStore, "oauth",
+		ratelimit.PerMinute(config.API.RateLimitOAuthPerMinute, config.API.RateLimitOAuthBurst), middlewares.KeyByIP)
+	accountRateLimit := middlewares.RateLimit(rateLimitStore, "account",
+		ratelimit.PerMinute(config.API.RateLimitAccountPerMinute, config.API.RateLimitAccountBurst), middlewares.KeyBySubject)
+	adminRateLimit := middlewares.RateLimit(rateLimitStore, "admin",
+		ratelimit.PerMinute(config.API.RateLimitAdminPerMinute, config.API.RateLimitAdminBurst), middlewares.KeyBySubject)
+	graphQLRateLimit := middlewares.RateLimit(rateLimitStore, "graphql",
+		ratelimit.PerMinute(config.API.RateLimitGraphQLPerMinute, config.API.RateLimitGraphQLBurst), middlewares.KeyBySubject)
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(middlewares.RealIP(ipResolver))
+	r.Use(middlewares.Trace(nil))
+	r.Use(middlewares.RequestID)
+	r.Use(middlewares.RequestLogger(log))
+	r.Use(middlewares.Instrument(m))
+	r.Use(middlewares.Deadline(time.Second * time.Duration(config.DB.Timeout)))
+	r.Use(middlewares.SecurityHeaders(middlewares.SecurityHeadersOptions{
+		HSTSMaxAge:            time.Second * time.Duration(config.TLS.HSTSMaxAge),
+		HSTSIncludeSubdomains: config.TLS.HSTSIncludeSubdomains,
+	}))
+	r.Use(middlewares.CORS(middlewares.CORSOptions{
+		AllowedOrigins:   splitList(config.CORS.AllowedOrigins),
+		AllowedMethods:   splitList(config.CORS.AllowedMethods),
+		AllowedHeaders:   splitList(config.CORS.AllowedHeaders),
+		ExposedHeaders:   splitList(config.CORS.ExposedHeaders),
+		AllowCredentials: config.CORS.AllowCredentials,
+		MaxAge:           time.Second * time.Duration(config.CORS.MaxAge),
+	}))
+	r.Use(middlewares.Compress(config.API.CompressionLevel))
+
+	r.Get("/healthz", healthChecks.LivenessHandler)
+	r.Get("/readyz", healthChecks.ReadinessHandler)
+
+	// Webhooks
+	webhookDB := database.NewWebhook(db)
+	webhookDeliveryDB := database.NewWebhookDelivery(db)
+	webhookDispatcher := webhook.New(webhookDB, webhookDeliveryDB,
+		webhook.NewClient(time.Second*time.Duration(config.API.WebhookTimeout), config.API.WebhookAllowPrivate),
+		webhook.Options{
+			MaxAttempts: config.API.WebhookMaxAttempts,
+			BackoffBase: time.Second * time.Duration(config.API.WebhookBackoffBase),
+			BackoffMax:  time.Second * time.Duration(config.API.WebhookBackoffMax),
+			Workers:     config.API.WebhookWorkers,
+		})
+	webhookHandler := handlers.NewWebhookHandler(webhookDB, webhookDeliveryDB, webhookDispatcher)
+
+	// Events are stored in the outbox with the changes and relayed to the
+	// webhooks and the configured publisher
+	outboxDB := database.NewOutbox(db)
+	productBroker := broker.New(entity.AggregateProduct, config.API.SSEBufferSize, config.API.SSEClientQueueSize)
+	// streams resume across restarts from the events already published
+	recentEvents, err := outboxDB.FindLatestPublished(context.Background(), entity.AggregateProduct, config.API.SSEBufferSize)
+	if err != nil {
+		panic(err)
+	}
+	productBroker.Load(recentEvents)
+	priceHub, err := realtime.NewHub(config.API.WSClientQueueSize, config.API.WSDropPolicy)
+	if err != nil {
+		panic(err)
+	}
+	// the relay publishes a message again to every publisher when one fails,
+	// so the broker, the hub and the dispatcher skip the messages they
+	// already got; they never fail, so they come before the external one
+	publishers := []outbox.Publisher{productBroker, priceHub, webhookDispatcher}
+	externalPublisher, publisherCloser, err := outbox.NewPublisher(outbox.Config{
+		Publisher:    config.Outbox.Publisher,
+		FilePath:     config.Outbox.FilePath,
+		NATSURL:      config.Outbox.NATSURL,
+		NATSSubject:  config.Outbox.NATSSubject,
+		KafkaBrokers: config.Outbox.KafkaBrokers,
+		KafkaTopic:   config.Outbox.KafkaTopic,
+	})
+	if err != nil {
+		panic(err)
+	}
+	if externalPublisher != nil {
+		publishers = append(publishers, externalPublisher)
+	}
+	outboxRelay := outbox.NewRelay(outboxDB, outbox.Multi(publishers...), config.Outbox.BatchSize,
+		time.Second*time.Duration(config.Outbox.PollInterval))
+	outboxRelay.MaxAttempts = config.Outbox.MaxAttempts
 
 	// Products
 	productDB := database.NewProduct(db)
+	productDB.Outbox = outboxDB
 	productHandler := handlers.NewProductHandler(productDB)
+	productHandler.Batch = batch.New(productDB, config.API.BatchMaxOperations)
+	apiKeyDB := database.NewAPIKey(db)
+	revokedTokenDB := database.NewRevokedToken(db)
+	idempotencyKeyDB := database.NewIdempotencyKey(db)
+	idempotency := middlewares.Idempotency(idempotencyKeyDB, time.Second*time.Duration(config.API.IdempotencyKeyTTL), time.Second*time.Duration(config.API.IdempotencyKeyLease))
+
+	importJobDB := database.NewImportJob(db)
+	productImporter := importer.New(productDB, config.API.ImportBatchSize)
+	importRunner, err := importer.NewRunner(productImporter, importJobDB, config.API.ImportDir,
+		config.API.ImportWorkers, config.API.ImportQueueSize)
+	if err != nil {
+		panic(err)
+	}
+	if err := importRunner.Recover(context.Background()); err != nil {
+		panic(err)
+	}
+	importHandler := handlers.NewImportHandler(productImporter, importRunner, importJobDB,
+		config.API.ImportSyncMaxBytes, config.API.ImportMaxBytes)
+
+	exportJobDB := database.NewExportJob(db)
+	productExporter := exporter.New(productDB)
+	exportRunner, err := exporter.NewRunner(productExporter, exportJobDB, config.API.ExportDir,
+		config.API.ExportWorkers, config.API.ExportQueueSize)
+	if err != nil {
+		panic(err)
+	}
+	if err := exportRunner.Recover(context.Background()); err != nil {
+		panic(err)
+	}
+	exportHandler := handlers.NewExportHandler(productExporter, exportRunner, exportJobDB,
+		time.Second*time.Duration(config.API.ExportTTL))
+
+	eventHandler := handlers.NewEventHandler(productBroker)
+	eventHandler.Heartbeat = time.Second * time.Duration(config.API.SSEHeartbeatInterval)
+	eventHandler.MaxClients = config.API.SSEMaxClients
+
+	// registered apart from the other product routes, as the token may come
+	// in the query string for EventSource clients
+	r.With(
+		middlewares.TokenFromQuery,
+		middlewares.Authenticate(config.API.TokenAuth, apiKeyDB, revokedTokenDB),
+		realtimeRateLimit,
+		middlewares.RequireScope(entity.ScopeProductsRead),
+	).Get("/products/events", eventHandler.ProductEvents)
+
+	webSocketHandler := handlers.NewWebSocketHandler(priceHub)
+	webSocketHandler.AllowedOrigins = splitList(config.CORS.AllowedOrigins)
+	webSocketHandler.PingInterval = time.Second * time.Duration(config.API.WSPingInterval)
+	webSocketHandler.PongTimeout = time.Second * time.Duration(config.API.WSPongTimeout)
+	webSocketHandler.MaxClients = config.API.WSMaxClients
+	r.With(
+		middlewares.TokenFromQuery,
+		middlewares.Authenticate(config.API.TokenAuth, apiKeyDB, revokedTokenDB),
+		realtimeRateLimit,
+		middlewares.RequireScope(entity.ScopeProductsRead),
+	).Get("/ws", webSocketHandler.Connect)
 
 	r.Route("/products", func(r chi.Router) {
-		r.Use(jwtauth.Verifier(config.API.TokenAuth))
-		r.Use(jwtauth.Authenticator)
-		r.Post("/", productHandler.CreateProduct)
-		r.Get("/{id}", productHandler.GetProduct)
-		r.Get("/", productHandler.GetProducts)
-		r.Put("/{id}", productHandler.UpdateProduct)
-		r.Delete("/{id}", productHandler.DeleteProduct)
+		r.Use(middlewares.Authenticate(config.API.TokenAuth, apiKeyDB, revokedTokenDB))
+		r.Use(productsRateLimit)
+		r.With(middlewares.RequireScope(entity.ScopeProductsWrite)).Post("/import", importHandler.ImportProducts)
+		r.With(middlewares.RequireScope(entity.ScopeProductsWrite)).Get("/import/{id}", importHandler.GetImportJob)
+		r.With(middlewares.RequireScope(entity.ScopeProductsRead)).Get("/export", exportHandler.ExportProducts)
+		r.With(middlewares.RequireScope(entity.ScopeProductsRead)).Get("/export/{id}", exportHandler.GetExportJob)
+		r.With(middlewares.RequireScope(entity.ScopeProductsRead)).Get("/export/{id}/download", exportHandler.DownloadExport)
+
+		r.Group(func(r chi.Router) {
+			// the listing also streams NDJSON
+			r.Use(render.Negotiate("application/x-ndjson"))
+			r.With(middlewares.RequireScope(entity.ScopeProductsWrite), idempotency).Post("/", productHandler.CreateProduct)
+			r.With(middlewares.RequireScope(entity.ScopeProductsWrite), idempotency).Post("/batch", productHandler.BatchProducts)
+			r.With(middlewares.RequireScope(entity.ScopeProductsRead)).Get("/{id}", productHandler.GetProduct)
+			r.With(middlewares.RequireScope(entity.ScopeProductsRead)).Get("/", productHandler.GetProducts)
+			r.With(middlewares.RequireScope(entity.ScopeProductsWrite)).Put("/{id}", productHandler.UpdateProduct)
+			r.With(middlewares.RequireScope(entity.ScopeProductsWrite)).Delete("/{id}", productHandler.DeleteProduct)
+		})
 	})
 
 	// Users
 	userDB := database.NewUser(db)
-	userHandler := handlers.NewUserHandler(userDB, config.API.TokenAuth, config.API.JWTExperesIn)
+	userDB.Outbox = outboxDB
+	userTokenDB := database.NewUserToken(db)
+	userHandler := handlers.NewUserHandler(userDB, userTokenDB, mailer, config.API.TokenAuth, config.API.JWTExperesIn)
+	userHandler.EmailVerificationExpiresIn = config.API.EmailVerificationExpiresIn
+	userHandler.PasswordResetExpiresIn = config.API.PasswordResetExpiresIn
+	userHandler.RequireVerifiedEmail = config.API.RequireVerifiedEmail
+	userHandler.RecoveryCodeDB = database.NewRecoveryCode(db)
+	userHandler.MFAJwt = config.API.MFATokenAuth
+	userHandler.MFATokenExpiresIn = config.API.MFATokenExpiresIn
+	userHandler.MFAIssuer = config.API.MFAIssuer
+	userHandler.RevokedTokenDB = revokedTokenDB
+	userHandler.Metrics = m
+	userHandler.LoginGuard = lockout.NewGuard(lockout.NewMemoryStore(),
+		lockout.Policy{
+			Threshold: config.API.LoginMaxAttempts,
+			BaseDelay: time.Second * time.Duration(config.API.LoginLockoutBase),
+			MaxDelay:  time.Second * time.Duration(config.API.LoginLockoutMax),
+			Window:    time.Second * time.Duration(config.API.LoginAttemptsWindow),
+		},
+		lockout.Policy{
+			Threshold: config.API.LoginIPMaxAttempts,
+			BaseDelay: time.Second * time.Duration(config.API.LoginLockoutBase),
+			MaxDelay:  time.Second * time.Duration(config.API.LoginLockoutMax),
+			Window:    time.Second * time.Duration(config.API.LoginAttemptsWindow),
+		},
+	)
+
+	negotiate := render.Negotiate()
+
+	r.Group(func(r chi.Router) {
+		r.Use(authRateLimit)
+		r.Use(negotiate)
+		r.With(idempotency).Post("/users", userHandler.CreateUser)
+		r.Post("/users/generate_token", userHandler.GetJWT)
+		r.Post("/users/verify_email", userHandler.VerifyEmail)
+		r.Post("/users/forgot_password", userHandler.ForgotPassword)
+		r.Post("/users/reset_password", userHandler.ResetPassword)
+	})
+
+	r.Route("/users/mfa", func(r chi.Router) {
+		r.Use(negotiate)
+		r.With(authRateLimit).Post("/verify", userHandler.VerifyMFA)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.Authenticate(config.API.TokenAuth, nil, revokedTokenDB))
+			r.Use(middlewares.RequireUserToken)
+			r.Use(accountRateLimit)
+			r.Post("/enroll", userHandler.EnrollMFA)
+			r.Post("/activate", userHandler.ActivateMFA)
+			r.Post("/disable", userHandler.DisableMFA)
+		})
+	})
+
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyDB)
+
+	r.Route("/users/api_keys", func(r chi.Router) {
+		r.Use(middlewares.Authenticate(config.API.TokenAuth, nil, revokedTokenDB))
+		r.Use(middlewares.RequireUserToken)
+		r.Use(accountRateLimit)
+		r.Post("/", apiKeyHandler.CreateAPIKey)
+		r.Get("/", apiKeyHandler.GetAPIKeys)
+		r.Delete("/{id}", apiKeyHandler.RevokeAPIKey)
+	})
+
+	// OAuth2
+	oauthHandler := handlers.NewOAuthHandler(database.NewOAuthClient(db), userDB, revokedTokenDB, config.API.TokenAuth, config.API.JWTExperesIn)
+	oauthHandler.LoginGuard = userHandler.LoginGuard
+	oauthHandler.RequireVerifiedEmail = config.API.RequireVerifiedEmail
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(middlewares.Authenticate(config.API.TokenAuth, nil, revokedTokenDB))
+		r.Use(middlewares.RequireUserToken)
+		r.Use(middlewares.RequireRole(entity.RoleAdmin))
+		r.Use(adminRateLimit)
+		r.With(negotiate).Post("/users/unlock", userHandler.UnlockUser)
+		r.Post("/oauth/clients", oauthHandler.CreateClient)
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Post("/", webhookHandler.CreateWebhook)
+			r.Get("/", webhookHandler.GetWebhooks)
+			r.Delete("/{id}", webhookHandler.DeleteWebhook)
+			r.Get("/{id}/deliveries", webhookHandler.GetDeliveries)
+			r.Post("/{id}/deliveries/{deliveryID}/redeliver", webhookHandler.Redeliver)
+		})
+	})
+
+	r.Route("/oauth", func(r chi.Router) {
+		r.Use(oauthRateLimit)
+		r.Post("/token", oauthHandler.Token)
+		r.Post("/introspect", oauthHandler.Introspect)
+		r.Post("/revoke", oauthHandler.Revoke)
+	})
+
+	// GraphQL
+	graphServer, err := graph.NewServer(graph.NewResolver(productDB, userDB))
+	if err != nil {
+		panic(err)
+	}
+	graphServer.MaxDepth = config.API.GraphQLMaxDepth
+	graphServer.MaxComplexity = config.API.GraphQLMaxComplexity
+	graphQLHandler := handlers.NewGraphQLHandler(graphServer)
+	// the resolvers check the scopes of each field
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.Authenticate(config.API.TokenAuth, apiKeyDB, revokedTokenDB))
+		r.Use(graphQLRateLimit)
+		r.Post("/graphql", graphQLHandler.Query)
+		r.Get("/graphql", graphQLHandler.Query)
+	})
+	if config.API.GraphiQL {
+		r.With(middlewares.ContentSecurityPolicy(middlewares.GraphiQLContentSecurityPolicy)).Get("/graphiql", graphQLHandler.GraphiQL)
+	}
+
+	r.With(middlewares.ContentSecurityPolicy(middlewares.DocsContentSecurityPolicy)).
+		Get("/docs/*", httpSwagger.Handler(httpSwagger.URL("/docs/doc.json")))
+
+	timeouts := lifecycle.Timeouts{
+		Read:       time.Second * time.Duration(config.API.ReadTimeout),
+		ReadHeader: time.Second * time.Duration(config.API.ReadHeaderTimeout),
+		Write:      time.Second * time.Duration(config.API.WriteTimeout),
+		Idle:       time.Second * time.Duration(config.API.IdleTimeout),
+	}
+	lc := lifecycle.New(
+		time.Second*time.Duration(config.API.ShutdownTimeout),
+		time.Second*time.Duration(config.API.ShutdownDrainPeriod),
+		log,
+	)
+	apiServer := lifecycle.NewHTTPServer(fmt.Sprintf(":%s", config.API.Port), r, timeouts)
+	apiServer.TLSConfig, err = tlsconfig.Load(tlsconfig.Config{
+		CertFile:     config.TLS.CertFile,
+		KeyFile:      config.TLS.KeyFile,
+		ClientCAFile: config.TLS.ClientCAFile,
+		ClientAuth:   config.TLS.ClientAuth,
+	})
+	if err != nil {
+		panic(err)
+	}
+	lc.AddServer("api", apiServer)
+
+	if config.TLS.RedirectPort != "" && apiServer.TLSConfig != nil {
+		lc.AddServer("https_redirect", lifecycle.NewHTTPServer(fmt.Sprintf(":%s", config.TLS.RedirectPort),
+			tlsconfig.RedirectHandler(config.API.Port), timeouts))
+	}
+
+	// metrics are served on a separate port so they are not exposed
+	// together with the public API
+	if config.API.MetricsPort != "" {
+		adminRouter := chi.NewRouter()
+		adminRouter.Handle("/metrics", m.Handler())
+		lc.AddServer("metrics", lifecycle.NewHTTPServer(fmt.Sprintf(":%s", config.API.MetricsPort), adminRouter, timeouts))
+	}
 
-	r.Post("/users", userHandler.CreateUser)
-	r.Post("/users/generate_token", userHandler.GetJWT)
+	// internal services call the products and login over gRPC, on its own
+	// port
+	if config.API.GRPCPort != "" {
+		grpcServer := grpcserver.New(grpcserver.Config{
+			TokenAuth:  config.API.TokenAuth,
+			APIKeys:    apiKeyDB,
+			Revoked:    revokedTokenDB,
+			Logger:     log,
+			Timeout:    time.Second * time.Duration(config.DB.Timeout),
+			Reflection: config.API.GRPCReflection,
+			TLSConfig:  apiServer.TLSConfig,
+			// the calls share the buckets of the matching REST routes, so
+			// switching protocols gives a client no extra requests
+			RateLimitStore: rateLimitStore,
+			RateLimits: map[string]grpcserver.RateLimit{
+				pb.AuthService_ServiceDesc.ServiceName: {Name: "auth",
+					Limit: ratelimit.PerMinute(config.API.RateLimitAuthPerMinute, config.API.RateLimitAuthBurst)},
+				pb.ProductService_ServiceDesc.ServiceName: {Name: "products",
+					Limit: ratelimit.PerMinute(config.API.RateLimitProductsPerMinute, config.API.RateLimitProductsBurst)},
+			},
+		}, service.NewProductService(productDB), service.NewAuthService(userHandler))
+		lc.AddOther("grpc", fmt.Sprintf(":%s", config.API.GRPCPort), grpcServer)
+		lc.OnShutdown(grpcServer.Health.Shutdown)
+	}
+
+	lc.OnShutdown(healthChecks.SetShuttingDown)
+	// event streams never finish on their own, so they are ended for the
+	// clients to reconnect to another instance
+	lc.OnShutdown(productBroker.Close)
+	lc.OnShutdown(priceHub.Close)
+	lc.AddWorker("revoked_token_cleanup", func(ctx context.Context) {
+		oauthHandler.RevokedTokenCleanup(ctx, time.Hour)
+	})
+	lc.AddWorker("password_reset_email", userHandler.SendPasswordResets)
+	lc.AddWorker("product_import", importRunner.Run)
+	lc.AddWorker("product_export", exportRunner.Run)
+	lc.AddWorker("product_export_cleanup", func(ctx context.Context) {
+		exportRunner.Cleanup(ctx, time.Hour)
+	})
+	lc.AddWorker("outbox_relay", outboxRelay.Run)
+	lc.AddWorker("outbox_cleanup", func(ctx context.Context) {
+		outboxRelay.Cleanup(ctx, time.Hour, time.Second*time.Duration(config.Outbox.Retention))
+	})
+	lc.AddWorker("webhook_delivery", webhookDispatcher.Run)
+	lc.AddWorker("webhook_delivery_cleanup", func(ctx context.Context) {
+		webhookDispatcher.Cleanup(ctx, time.Hour, time.Second*time.Duration(config.API.WebhookRetention))
+	})
+	lc.AddWorker("idempotency_key_cleanup", func(ctx context.Context) {
+		middlewares.IdempotencyKeyCleanup(ctx, idempotencyKeyDB, time.Hour)
+	})
+	// closers run in reverse order: the database closes before the tracer
+	// flushes, so the spans of the last queries are exported
+	lc.AddCloser("tracing", shutdownTracing)
+	lc.AddCloser("database", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+	if publisherCloser != nil {
+		lc.AddCloser("outbox_publisher", func(context.Context) error {
+			return publisherCloser.Close()
+		})
+	}
 
-	r.Get("/docs/*",httpSwagger.Handler(httpSwagger.URL("http://localhost:8081/docs/doc.json")))
+	if err := lc.Run(context.Background()); err != nil {
+		log.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
 
-	println("Starting server on port " + config.API.Port)
-	http.ListenAndServe(fmt.Sprintf(":%s", config.API.Port), r)
+// splitList parses a comma-separated setting, ignoring empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 //func LogRequest(next http.Handler) http.Handler{
@@ -1,6 +1,11 @@
 package configs
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"strings"
+
 	"github.com/go-chi/jwtauth"
 	"github.com/spf13/viper"
 )
@@ -12,18 +17,150 @@ type db struct {
 	User     string `mapstructure:"DB_USER"`
 	Password string `mapstructure:"DB_PASSWORD"`
 	Name     string `mapstructure:"DB_NAME"`
+	Timeout  int    `mapstructure:"DB_TIMEOUT"`
 }
 
 type api struct {
-	Port         string `mapstructure:"API_PORT"`
-	JWTSecret    string `mapstructure:"JWT_SECRET"`
-	JWTExperesIn int    `mapstructure:"JWT_EXPIRES_IN"`
-	TokenAuth    *jwtauth.JWTAuth
+	Port                       string `mapstructure:"API_PORT"`
+	MetricsPort                string `mapstructure:"METRICS_PORT"`
+	GRPCPort                   string `mapstructure:"GRPC_PORT"`
+	GRPCReflection             bool   `mapstructure:"GRPC_REFLECTION"`
+	HealthCheckTimeout         int    `mapstructure:"HEALTH_CHECK_TIMEOUT"`
+	ReadTimeout                int    `mapstructure:"HTTP_READ_TIMEOUT"`
+	ReadHeaderTimeout          int    `mapstructure:"HTTP_READ_HEADER_TIMEOUT"`
+	WriteTimeout               int    `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout                int    `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout            int    `mapstructure:"SHUTDOWN_TIMEOUT"`
+	ShutdownDrainPeriod        int    `mapstructure:"SHUTDOWN_DRAIN_PERIOD"`
+	TrustedProxies             string `mapstructure:"TRUSTED_PROXIES"`
+	RateLimitProductsPerMinute int    `mapstructure:"RATE_LIMIT_PRODUCTS_PER_MINUTE"`
+	RateLimitProductsBurst     int    `mapstructure:"RATE_LIMIT_PRODUCTS_BURST"`
+	RateLimitRealtimePerMinute int    `mapstructure:"RATE_LIMIT_REALTIME_PER_MINUTE"`
+	RateLimitRealtimeBurst     int    `mapstructure:"RATE_LIMIT_REALTIME_BURST"`
+	RateLimitAuthPerMinute     int    `mapstructure:"RATE_LIMIT_AUTH_PER_MINUTE"`
+	RateLimitAuthBurst         int    `mapstructure:"RATE_LIMIT_AUTH_BURST"`
+	RateLimitOAuthPerMinute    int    `mapstructure:"RATE_LIMIT_OAUTH_PER_MINUTE"`
+	RateLimitOAuthBurst        int    `mapstructure:"RATE_LIMIT_OAUTH_BURST"`
+	RateLimitAccountPerMinute  int    `mapstructure:"RATE_LIMIT_ACCOUNT_PER_MINUTE"`
+	RateLimitAccountBurst      int    `mapstructure:"RATE_LIMIT_ACCOUNT_BURST"`
+	RateLimitAdminPerMinute    int    `mapstructure:"RATE_LIMIT_ADMIN_PER_MINUTE"`
+	RateLimitAdminBurst        int    `mapstructure:"RATE_LIMIT_ADMIN_BURST"`
+	RateLimitGraphQLPerMinute  int    `mapstructure:"RATE_LIMIT_GRAPHQL_PER_MINUTE"`
+	RateLimitGraphQLBurst      int    `mapstructure:"RATE_LIMIT_GRAPHQL_BURST"`
+	IdempotencyKeyTTL          int    `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+	IdempotencyKeyLease        int    `mapstructure:"IDEMPOTENCY_KEY_LEASE"`
+	CompressionLevel           int    `mapstructure:"COMPRESSION_LEVEL"`
+	ImportDir                  string `mapstructure:"IMPORT_DIR"`
+	ImportBatchSize            int    `mapstructure:"IMPORT_BATCH_SIZE"`
+	ImportWorkers              int    `mapstructure:"IMPORT_WORKERS"`
+	ImportQueueSize            int    `mapstructure:"IMPORT_QUEUE_SIZE"`
+	ImportSyncMaxBytes         int64  `mapstructure:"IMPORT_SYNC_MAX_BYTES"`
+	ImportMaxBytes             int64  `mapstructure:"IMPORT_MAX_BYTES"`
+	BatchMaxOperations         int    `mapstructure:"BATCH_MAX_OPERATIONS"`
+	ExportDir                  string `mapstructure:"EXPORT_DIR"`
+	ExportWorkers              int    `mapstructure:"EXPORT_WORKERS"`
+	ExportQueueSize            int    `mapstructure:"EXPORT_QUEUE_SIZE"`
+	ExportTTL                  int    `mapstructure:"EXPORT_TTL"`
+	SSEBufferSize              int    `mapstructure:"SSE_BUFFER_SIZE"`
+	SSEClientQueueSize         int    `mapstructure:"SSE_CLIENT_QUEUE_SIZE"`
+	SSEHeartbeatInterval       int    `mapstructure:"SSE_HEARTBEAT_INTERVAL"`
+	SSEMaxClients              int    `mapstructure:"SSE_MAX_CLIENTS"`
+	WSClientQueueSize          int    `mapstructure:"WS_CLIENT_QUEUE_SIZE"`
+	WSDropPolicy               string `mapstructure:"WS_DROP_POLICY"`
+	WSPingInterval             int    `mapstructure:"WS_PING_INTERVAL"`
+	WSPongTimeout              int    `mapstructure:"WS_PONG_TIMEOUT"`
+	WSMaxClients               int    `mapstructure:"WS_MAX_CLIENTS"`
+	GraphQLMaxDepth            int    `mapstructure:"GRAPHQL_MAX_DEPTH"`
+	GraphQLMaxComplexity       int    `mapstructure:"GRAPHQL_MAX_COMPLEXITY"`
+	GraphiQL                   bool   `mapstructure:"GRAPHQL_GRAPHIQL"`
+	WebhookTimeout             int    `mapstructure:"WEBHOOK_TIMEOUT"`
+	WebhookMaxAttempts         int    `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookBackoffBase         int    `mapstructure:"WEBHOOK_BACKOFF_BASE"`
+	WebhookBackoffMax          int    `mapstructure:"WEBHOOK_BACKOFF_MAX"`
+	WebhookWorkers             int    `mapstructure:"WEBHOOK_WORKERS"`
+	WebhookRetention           int    `mapstructure:"WEBHOOK_RETENTION"`
+	WebhookAllowPrivate        bool   `mapstructure:"WEBHOOK_ALLOW_PRIVATE_NETWORKS"`
+	JWTSecret                  string `mapstructure:"JWT_SECRET"`
+	JWTExperesIn               int    `mapstructure:"JWT_EXPIRES_IN"`
+	RequireVerifiedEmail       bool   `mapstructure:"AUTH_REQUIRE_VERIFIED_EMAIL"`
+	EmailVerificationExpiresIn int    `mapstructure:"EMAIL_VERIFICATION_EXPIRES_IN"`
+	PasswordResetExpiresIn     int    `mapstructure:"PASSWORD_RESET_EXPIRES_IN"`
+	MFAIssuer                  string `mapstructure:"MFA_ISSUER"`
+	MFATokenExpiresIn          int    `mapstructure:"MFA_TOKEN_EXPIRES_IN"`
+	LoginMaxAttempts           int    `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginIPMaxAttempts         int    `mapstructure:"LOGIN_IP_MAX_ATTEMPTS"`
+	LoginLockoutBase           int    `mapstructure:"LOGIN_LOCKOUT_BASE"`
+	LoginLockoutMax            int    `mapstructure:"LOGIN_LOCKOUT_MAX"`
+	LoginAttemptsWindow        int    `mapstructure:"LOGIN_ATTEMPTS_WINDOW"`
+	TokenAuth                  *jwtauth.JWTAuth
+	MFATokenAuth               *jwtauth.JWTAuth
+}
+
+type mail struct {
+	Driver       string `mapstructure:"MAIL_DRIVER"`
+	From         string `mapstructure:"MAIL_FROM"`
+	FilePath     string `mapstructure:"MAIL_FILE_PATH"`
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     string `mapstructure:"SMTP_PORT"`
+	SMTPUser     string `mapstructure:"SMTP_USER"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+}
+
+type logging struct {
+	Level  string `mapstructure:"LOG_LEVEL"`
+	Format string `mapstructure:"LOG_FORMAT"`
+}
+
+type tracing struct {
+	Exporter     string  `mapstructure:"TRACING_EXPORTER"`
+	ServiceName  string  `mapstructure:"TRACING_SERVICE_NAME"`
+	OTLPEndpoint string  `mapstructure:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool    `mapstructure:"TRACING_OTLP_INSECURE"`
+	FilePath     string  `mapstructure:"TRACING_FILE_PATH"`
+	SampleRatio  float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
+}
+
+type outbox struct {
+	Publisher    string `mapstructure:"OUTBOX_PUBLISHER"`
+	FilePath     string `mapstructure:"OUTBOX_FILE_PATH"`
+	NATSURL      string `mapstructure:"OUTBOX_NATS_URL"`
+	NATSSubject  string `mapstructure:"OUTBOX_NATS_SUBJECT"`
+	KafkaBrokers string `mapstructure:"OUTBOX_KAFKA_BROKERS"`
+	KafkaTopic   string `mapstructure:"OUTBOX_KAFKA_TOPIC"`
+	BatchSize    int    `mapstructure:"OUTBOX_BATCH_SIZE"`
+	PollInterval int    `mapstructure:"OUTBOX_POLL_INTERVAL"`
+	Retention    int    `mapstructure:"OUTBOX_RETENTION"`
+	MaxAttempts  int    `mapstructure:"OUTBOX_MAX_ATTEMPTS"`
+}
+
+type cors struct {
+	AllowedOrigins   string `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   string `mapstructure:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   string `mapstructure:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   string `mapstructure:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool   `mapstructure:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           int    `mapstructure:"CORS_MAX_AGE"`
+}
+
+type tlsConf struct {
+	CertFile              string `mapstructure:"TLS_CERT_FILE"`
+	KeyFile               string `mapstructure:"TLS_KEY_FILE"`
+	ClientCAFile          string `mapstructure:"TLS_CLIENT_CA_FILE"`
+	ClientAuth            string `mapstructure:"TLS_CLIENT_AUTH"`
+	RedirectPort          string `mapstructure:"TLS_REDIRECT_PORT"`
+	HSTSMaxAge            int    `mapstructure:"HSTS_MAX_AGE"`
+	HSTSIncludeSubdomains bool   `mapstructure:"HSTS_INCLUDE_SUBDOMAINS"`
 }
 
 type conf struct {
-	DB  db
-	API api
+	DB      db
+	API     api
+	Mail    mail
+	Log     logging
+	Tracing tracing
+	Outbox  outbox
+	CORS    cors
+	TLS     tlsConf
 }
 
 func LoadConfig(path string) (*conf, error) {
@@ -35,6 +172,114 @@ func LoadConfig(path string) (*conf, error) {
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
 
+	viper.SetDefault("DB_TIMEOUT", 5)
+	viper.SetDefault("METRICS_PORT", "9091")
+	viper.SetDefault("GRPC_PORT", "50051")
+	viper.SetDefault("GRPC_REFLECTION", false)
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", 2)
+	viper.SetDefault("HTTP_READ_TIMEOUT", 15)
+	viper.SetDefault("HTTP_READ_HEADER_TIMEOUT", 5)
+	viper.SetDefault("HTTP_WRITE_TIMEOUT", 30)
+	viper.SetDefault("HTTP_IDLE_TIMEOUT", 60)
+	viper.SetDefault("SHUTDOWN_TIMEOUT", 30)
+	viper.SetDefault("SHUTDOWN_DRAIN_PERIOD", 5)
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("RATE_LIMIT_PRODUCTS_PER_MINUTE", 600)
+	viper.SetDefault("RATE_LIMIT_PRODUCTS_BURST", 100)
+	viper.SetDefault("RATE_LIMIT_REALTIME_PER_MINUTE", 60)
+	viper.SetDefault("RATE_LIMIT_REALTIME_BURST", 20)
+	viper.SetDefault("RATE_LIMIT_AUTH_PER_MINUTE", 60)
+	viper.SetDefault("RATE_LIMIT_AUTH_BURST", 20)
+	viper.SetDefault("RATE_LIMIT_OAUTH_PER_MINUTE", 120)
+	viper.SetDefault("RATE_LIMIT_OAUTH_BURST", 30)
+	viper.SetDefault("RATE_LIMIT_ACCOUNT_PER_MINUTE", 120)
+	viper.SetDefault("RATE_LIMIT_ACCOUNT_BURST", 30)
+	viper.SetDefault("RATE_LIMIT_ADMIN_PER_MINUTE", 120)
+	viper.SetDefault("RATE_LIMIT_ADMIN_BURST", 30)
+	viper.SetDefault("RATE_LIMIT_GRAPHQL_PER_MINUTE", 300)
+	viper.SetDefault("RATE_LIMIT_GRAPHQL_BURST", 50)
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", 86400)
+	viper.SetDefault("IDEMPOTENCY_KEY_LEASE", 60)
+	viper.SetDefault("COMPRESSION_LEVEL", 5)
+	viper.SetDefault("IMPORT_DIR", "imports")
+	viper.SetDefault("IMPORT_BATCH_SIZE", 500)
+	viper.SetDefault("IMPORT_WORKERS", 2)
+	viper.SetDefault("IMPORT_QUEUE_SIZE", 100)
+	viper.SetDefault("IMPORT_SYNC_MAX_BYTES", 10<<20)
+	viper.SetDefault("IMPORT_MAX_BYTES", 500<<20)
+	viper.SetDefault("BATCH_MAX_OPERATIONS", 1000)
+	viper.SetDefault("EXPORT_DIR", "exports")
+	viper.SetDefault("EXPORT_WORKERS", 2)
+	viper.SetDefault("EXPORT_QUEUE_SIZE", 100)
+	viper.SetDefault("EXPORT_TTL", 86400)
+	viper.SetDefault("SSE_BUFFER_SIZE", 1000)
+	viper.SetDefault("SSE_CLIENT_QUEUE_SIZE", 64)
+	viper.SetDefault("SSE_HEARTBEAT_INTERVAL", 15)
+	viper.SetDefault("SSE_MAX_CLIENTS", 1000)
+	viper.SetDefault("WS_CLIENT_QUEUE_SIZE", 64)
+	viper.SetDefault("WS_DROP_POLICY", "oldest")
+	viper.SetDefault("WS_PING_INTERVAL", 30)
+	viper.SetDefault("WS_PONG_TIMEOUT", 60)
+	viper.SetDefault("WS_MAX_CLIENTS", 1000)
+	viper.SetDefault("GRAPHQL_MAX_DEPTH", 10)
+	viper.SetDefault("GRAPHQL_MAX_COMPLEXITY", 1000)
+	viper.SetDefault("GRAPHQL_GRAPHIQL", false)
+	viper.SetDefault("WEBHOOK_TIMEOUT", 10)
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+	viper.SetDefault("WEBHOOK_BACKOFF_BASE", 30)
+	viper.SetDefault("WEBHOOK_BACKOFF_MAX", 3600)
+	viper.SetDefault("WEBHOOK_WORKERS", 4)
+	viper.SetDefault("WEBHOOK_RETENTION", 604800)
+	viper.SetDefault("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false)
+	viper.SetDefault("AUTH_REQUIRE_VERIFIED_EMAIL", false)
+	viper.SetDefault("EMAIL_VERIFICATION_EXPIRES_IN", 86400)
+	viper.SetDefault("PASSWORD_RESET_EXPIRES_IN", 3600)
+	viper.SetDefault("MFA_ISSUER", "Go Expert API")
+	viper.SetDefault("MFA_TOKEN_EXPIRES_IN", 300)
+	viper.SetDefault("LOGIN_MAX_ATTEMPTS", 5)
+	viper.SetDefault("LOGIN_IP_MAX_ATTEMPTS", 20)
+	viper.SetDefault("LOGIN_LOCKOUT_BASE", 30)
+	viper.SetDefault("LOGIN_LOCKOUT_MAX", 3600)
+	viper.SetDefault("LOGIN_ATTEMPTS_WINDOW", 900)
+	viper.SetDefault("MAIL_DRIVER", "stdout")
+	viper.SetDefault("MAIL_FROM", "no-reply@localhost")
+	viper.SetDefault("MAIL_FILE_PATH", "mail.log")
+	viper.SetDefault("SMTP_HOST", "")
+	viper.SetDefault("SMTP_PORT", "587")
+	viper.SetDefault("SMTP_USER", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", "json")
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_SERVICE_NAME", "go-expert-api")
+	viper.SetDefault("TRACING_OTLP_ENDPOINT", "localhost:4318")
+	viper.SetDefault("TRACING_OTLP_INSECURE", true)
+	viper.SetDefault("TRACING_FILE_PATH", "traces.log")
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+	viper.SetDefault("OUTBOX_PUBLISHER", "none")
+	viper.SetDefault("OUTBOX_FILE_PATH", "events.ndjson")
+	viper.SetDefault("OUTBOX_NATS_URL", "nats://localhost:4222")
+	viper.SetDefault("OUTBOX_NATS_SUBJECT", "catalog.events")
+	viper.SetDefault("OUTBOX_KAFKA_BROKERS", "localhost:9092")
+	viper.SetDefault("OUTBOX_KAFKA_TOPIC", "catalog.events")
+	viper.SetDefault("OUTBOX_BATCH_SIZE", 100)
+	viper.SetDefault("OUTBOX_POLL_INTERVAL", 1)
+	viper.SetDefault("OUTBOX_RETENTION", 86400)
+	viper.SetDefault("OUTBOX_MAX_ATTEMPTS", 20)
+	viper.SetDefault("CORS_ALLOWED_ORIGINS", "")
+	viper.SetDefault("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE")
+	viper.SetDefault("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,X-API-Key,Idempotency-Key,X-Request-ID")
+	viper.SetDefault("CORS_EXPOSED_HEADERS", "Location,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Idempotent-Replayed,X-Request-ID")
+	viper.SetDefault("CORS_ALLOW_CREDENTIALS", false)
+	viper.SetDefault("CORS_MAX_AGE", 600)
+	viper.SetDefault("TLS_CERT_FILE", "")
+	viper.SetDefault("TLS_KEY_FILE", "")
+	viper.SetDefault("TLS_CLIENT_CA_FILE", "")
+	viper.SetDefault("TLS_CLIENT_AUTH", "none")
+	viper.SetDefault("TLS_REDIRECT_PORT", "")
+	viper.SetDefault("HSTS_MAX_AGE", 31536000)
+	viper.SetDefault("HSTS_INCLUDE_SUBDOMAINS", false)
+
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(err)
@@ -48,7 +293,116 @@ func LoadConfig(path string) (*conf, error) {
 		panic(err)
 	}
 
+	if err := viper.Unmarshal(&cfg.Mail); err != nil {
+		panic(err)
+	}
+
+	if err := viper.Unmarshal(&cfg.Log); err != nil {
+		panic(err)
+	}
+
+	if err := viper.Unmarshal(&cfg.Tracing); err != nil {
+		panic(err)
+	}
+
+	if err := viper.Unmarshal(&cfg.Outbox); err != nil {
+		panic(err)
+	}
+
+	if err := viper.Unmarshal(&cfg.CORS); err != nil {
+		panic(err)
+	}
+
+	if err := viper.Unmarshal(&cfg.TLS); err != nil {
+		panic(err)
+	}
+
 	cfg.API.TokenAuth = jwtauth.New("HS256", []byte(cfg.API.JWTSecret), nil)
+	// mfa tokens are signed with a derived key so they are never accepted
+	// as access tokens by the routes protected with TokenAuth
+	cfg.API.MFATokenAuth = jwtauth.New("HS256", deriveKey(cfg.API.JWTSecret, "mfa"), nil)
 
 	return &cfg, nil
 }
+
+// Validate reports settings the API cannot run with.
+func (c *conf) Validate() error {
+	if c.API.Port == "" {
+		return errors.New("API_PORT is required")
+	}
+	if c.API.JWTSecret == "" {
+		return errors.New("JWT_SECRET is required")
+	}
+	if c.API.LoginLockoutBase < 0 || c.API.LoginLockoutMax < 0 {
+		return errors.New("LOGIN_LOCKOUT_BASE and LOGIN_LOCKOUT_MAX must not be negative")
+	}
+	if c.API.JWTExperesIn <= 0 {
+		return errors.New("JWT_EXPIRES_IN must be positive")
+	}
+	if c.API.MetricsPort != "" && c.API.MetricsPort == c.API.Port {
+		return errors.New("METRICS_PORT must differ from API_PORT")
+	}
+	if c.API.GRPCPort != "" && (c.API.GRPCPort == c.API.Port || c.API.GRPCPort == c.API.MetricsPort) {
+		return errors.New("GRPC_PORT must differ from API_PORT and METRICS_PORT")
+	}
+	// a request still running must keep its key, and a key left by one that
+	// died must be freed before it expires
+	if c.API.IdempotencyKeyLease <= c.API.WriteTimeout || c.API.IdempotencyKeyLease >= c.API.IdempotencyKeyTTL {
+		return errors.New("IDEMPOTENCY_KEY_LEASE must be greater than HTTP_WRITE_TIMEOUT and less than IDEMPOTENCY_KEY_TTL")
+	}
+	if c.API.ExportTTL <= 0 {
+		return errors.New("EXPORT_TTL must be positive")
+	}
+	if c.Outbox.PollInterval <= 0 {
+		return errors.New("OUTBOX_POLL_INTERVAL must be positive")
+	}
+	if c.Outbox.MaxAttempts < 0 {
+		return errors.New("OUTBOX_MAX_ATTEMPTS must not be negative")
+	}
+	if c.API.SSEHeartbeatInterval <= 0 {
+		return errors.New("SSE_HEARTBEAT_INTERVAL must be positive")
+	}
+	if c.API.WSPingInterval <= 0 {
+		return errors.New("WS_PING_INTERVAL must be positive")
+	}
+	if c.API.WSPongTimeout <= c.API.WSPingInterval {
+		return errors.New("WS_PONG_TIMEOUT must be greater than WS_PING_INTERVAL")
+	}
+	if c.API.GraphQLMaxDepth <= 0 {
+		return errors.New("GRAPHQL_MAX_DEPTH must be positive")
+	}
+	if c.API.GraphQLMaxComplexity <= 0 {
+		return errors.New("GRAPHQL_MAX_COMPLEXITY must be positive")
+	}
+	if c.API.WebhookTimeout <= 0 {
+		return errors.New("WEBHOOK_TIMEOUT must be positive")
+	}
+	if c.API.WebhookMaxAttempts <= 0 {
+		return errors.New("WEBHOOK_MAX_ATTEMPTS must be positive")
+	}
+	if c.CORS.AllowCredentials {
+		for _, origin := range strings.Split(c.CORS.AllowedOrigins, ",") {
+			if strings.TrimSpace(origin) == "*" {
+				return errors.New("CORS_ALLOW_CREDENTIALS cannot be used with CORS_ALLOWED_ORIGINS=*")
+			}
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLS.RedirectPort != "" {
+		if c.TLS.CertFile == "" {
+			return errors.New("TLS_REDIRECT_PORT requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		if c.TLS.RedirectPort == c.API.Port || c.TLS.RedirectPort == c.API.MetricsPort {
+			return errors.New("TLS_REDIRECT_PORT must differ from API_PORT and METRICS_PORT")
+		}
+	}
+	return nil
+}
+
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -1,25 +1,203 @@
 package dto
 
+import (
+	"encoding/xml"
+	"time"
+)
+
 type CreateProductInput struct {
-	Name  string  `json:"name" binding:"required"`
-	Price float64 `json:"price" binding:"required"`
+	Name     string  `json:"name" xml:"name" binding:"required"`
+	Price    float64 `json:"price" xml:"price" binding:"required"`
+	SKU      string  `json:"sku" xml:"sku"`
+	Category string  `json:"category" xml:"category"`
+}
+
+type BatchOperation struct {
+	Op      string              `json:"op" xml:"op" enums:"create,update,delete"`
+	ID      string              `json:"id,omitempty" xml:"id,omitempty"`
+	Product *CreateProductInput `json:"product,omitempty" xml:"product,omitempty"`
+}
+
+type BatchInput struct {
+	Atomic     bool             `json:"atomic" xml:"atomic"`
+	Operations []BatchOperation `json:"operations" xml:"operations>operation" binding:"required"`
+}
+
+type BatchResult struct {
+	Status int         `json:"status" xml:"status"`
+	Body   interface{} `json:"body,omitempty" xml:"body,omitempty"`
+}
+
+type BatchOutput struct {
+	XMLName   xml.Name      `json:"-" xml:"batch"`
+	Atomic    bool          `json:"atomic" xml:"atomic"`
+	Succeeded int           `json:"succeeded" xml:"succeeded"`
+	Failed    int           `json:"failed" xml:"failed"`
+	Results   []BatchResult `json:"results" xml:"results>result"`
 }
 
 type CreateUserInput struct {
-	Name string `json:"name" binding:"required"`
-	Email string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Name string `json:"name" xml:"name" binding:"required"`
+	Email string `json:"email" xml:"email" binding:"required"`
+	Password string `json:"password" xml:"password" binding:"required"`
 }
 
 type GetJWTInput struct {
-	Email string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Email string `json:"email" xml:"email" binding:"required"`
+	Password string `json:"password" xml:"password" binding:"required"`
 }
 
 type GetJWTOutput struct {
-	AccessToken string `json:"access_token"`
+	XMLName     xml.Name `json:"-" xml:"token"`
+	AccessToken string   `json:"access_token,omitempty" xml:"access_token,omitempty"`
+	MFARequired bool     `json:"mfa_required,omitempty" xml:"mfa_required,omitempty"`
+	MFAToken    string   `json:"mfa_token,omitempty" xml:"mfa_token,omitempty"`
 }
 
 type ErrorOutput struct {
-	Message string `json:"message"`
-}
\ No newline at end of file
+	XMLName xml.Name `json:"-" xml:"error"`
+	Message string   `json:"message" xml:"message"`
+}
+type VerifyEmailInput struct {
+	Token string `json:"token" xml:"token" binding:"required"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" xml:"email" binding:"required"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" xml:"token" binding:"required"`
+	Password string `json:"password" xml:"password" binding:"required"`
+}
+
+type EnrollMFAOutput struct {
+	XMLName         xml.Name `json:"-" xml:"mfa_enrollment"`
+	Secret          string   `json:"secret" xml:"secret"`
+	ProvisioningURI string   `json:"provisioning_uri" xml:"provisioning_uri"`
+}
+
+type MFACodeInput struct {
+	Code string `json:"code" xml:"code" binding:"required"`
+}
+
+type ActivateMFAOutput struct {
+	XMLName       xml.Name `json:"-" xml:"mfa_activation"`
+	RecoveryCodes []string `json:"recovery_codes" xml:"recovery_codes>code"`
+}
+
+type DisableMFAInput struct {
+	Code         string `json:"code" xml:"code"`
+	RecoveryCode string `json:"recovery_code" xml:"recovery_code"`
+}
+
+type VerifyMFAInput struct {
+	MFAToken     string `json:"mfa_token" xml:"mfa_token" binding:"required"`
+	Code         string `json:"code" xml:"code"`
+	RecoveryCode string `json:"recovery_code" xml:"recovery_code"`
+}
+
+type UnlockUserInput struct {
+	Email string `json:"email" xml:"email"`
+	IP    string `json:"ip" xml:"ip"`
+}
+
+type CreateAPIKeyInput struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+type APIKeyOutput struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAPIKeyOutput struct {
+	APIKeyOutput
+	Key string `json:"key"`
+}
+
+type CreateOAuthClientInput struct {
+	Name       string   `json:"name" binding:"required"`
+	GrantTypes []string `json:"grant_types" binding:"required"`
+	Scopes     []string `json:"scopes" binding:"required"`
+}
+
+type CreateOAuthClientOutput struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Name         string   `json:"name"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+}
+
+type OAuthTokenOutput struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+type OAuthErrorOutput struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type IntrospectionOutput struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+type CreateWebhookInput struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required" enums:"product.created,product.updated,product.deleted,product.price_changed,user.created"`
+	Secret string   `json:"secret"`
+}
+
+type WebhookOutput struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateWebhookOutput struct {
+	WebhookOutput
+	Secret string `json:"secret"`
+}
+
+type GraphQLInput struct {
+	Query         string                 `json:"query" binding:"required"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type GraphQLOutput struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
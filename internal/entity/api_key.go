@@ -0,0 +1,122 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+
+	apiKeyPrefix = "gea"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrAPIKeyRevoked  = errors.New("api key revoked")
+	ErrAPIKeyExpired  = errors.New("api key expired")
+	ErrInvalidScope   = errors.New("invalid scope")
+	ErrScopesRequired = errors.New("at least one scope is required")
+)
+
+var validScopes = map[string]bool{
+	ScopeProductsRead:  true,
+	ScopeProductsWrite: true,
+}
+
+// APIKey gives machine-to-machine access on behalf of a user. The key has the
+// form gea_<prefix>_<secret>: the prefix is stored in clear to find the key
+// and only the hash of the secret is persisted.
+type APIKey struct {
+	ID         entity.ID  `json:"id"`
+	UserID     entity.ID  `json:"user_id" gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"uniqueIndex"`
+	SecretHash string     `json:"-"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewAPIKey creates a key for the user and returns it with the plain key,
+// which is only shown once.
+func NewAPIKey(userID entity.ID, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	if name == "" {
+		return nil, "", ErrNameIsRequired
+	}
+	if len(scopes) == 0 {
+		return nil, "", ErrScopesRequired
+	}
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return nil, "", ErrInvalidScope
+		}
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	prefix := hex.EncodeToString(b)
+
+	secret, err := GenerateSecret(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &APIKey{
+		ID:         entity.NewID(),
+		UserID:     userID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: HashToken(secret),
+		Scopes:     strings.Join(scopes, " "),
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now(),
+	}
+	return key, apiKeyPrefix + "_" + prefix + "_" + secret, nil
+}
+
+// ParseAPIKey splits a plain key into its lookup prefix and secret.
+func ParseAPIKey(key string) (string, string, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", ErrInvalidAPIKey
+	}
+	return parts[1], parts[2], nil
+}
+
+// Validate checks the secret and that the key can still be used.
+func (k *APIKey) Validate(secret string, now time.Time) error {
+	if subtle.ConstantTimeCompare([]byte(k.SecretHash), []byte(HashToken(secret))) != 1 {
+		return ErrInvalidAPIKey
+	}
+	if k.RevokedAt != nil {
+		return ErrAPIKeyRevoked
+	}
+	if k.ExpiresAt != nil && now.After(*k.ExpiresAt) {
+		return ErrAPIKeyExpired
+	}
+	return nil
+}
+
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+func (k *APIKey) Revoke() {
+	if k.RevokedAt != nil {
+		return
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+}
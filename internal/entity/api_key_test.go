@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	key, plain, err := NewAPIKey(user.ID, "integration", []string{ScopeProductsRead, ScopeProductsWrite}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, key.UserID)
+	assert.Equal(t, []string{ScopeProductsRead, ScopeProductsWrite}, key.ScopeList())
+
+	prefix, secret, err := ParseAPIKey(plain)
+	assert.Nil(t, err)
+	assert.Equal(t, key.Prefix, prefix)
+	assert.NotContains(t, key.SecretHash, secret)
+	assert.Nil(t, key.Validate(secret, time.Now()))
+	assert.Equal(t, ErrInvalidAPIKey, key.Validate("wrong", time.Now()))
+}
+
+func TestNewAPIKeyWhenScopeIsInvalid(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	_, _, err := NewAPIKey(user.ID, "integration", []string{"admin"}, nil)
+	assert.Equal(t, ErrInvalidScope, err)
+
+	_, _, err = NewAPIKey(user.ID, "integration", nil, nil)
+	assert.Equal(t, ErrScopesRequired, err)
+
+	_, _, err = NewAPIKey(user.ID, "", []string{ScopeProductsRead}, nil)
+	assert.Equal(t, ErrNameIsRequired, err)
+}
+
+func TestAPIKey_ValidateWhenExpiredOrRevoked(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	expiresAt := time.Now().Add(time.Hour)
+	key, plain, _ := NewAPIKey(user.ID, "integration", []string{ScopeProductsRead}, &expiresAt)
+	_, secret, _ := ParseAPIKey(plain)
+
+	assert.Nil(t, key.Validate(secret, time.Now()))
+	assert.Equal(t, ErrAPIKeyExpired, key.Validate(secret, time.Now().Add(2*time.Hour)))
+
+	key.Revoke()
+	assert.Equal(t, ErrAPIKeyRevoked, key.Validate(secret, time.Now()))
+}
+
+func TestParseAPIKey(t *testing.T) {
+	_, _, err := ParseAPIKey("not-a-key")
+	assert.Equal(t, ErrInvalidAPIKey, err)
+
+	prefix, secret, err := ParseAPIKey("gea_abc_se_cret")
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", prefix)
+	assert.Equal(t, "se_cret", secret)
+}
@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const (
+	EventProductCreated = "product.created"
+	EventProductUpdated = "product.updated"
+	EventProductDeleted = "product.deleted"
+	// EventProductPriceChanged follows the product.updated event of an
+	// update that changed the price.
+	EventProductPriceChanged = "product.price_changed"
+	EventUserCreated         = "user.created"
+)
+
+// EventTypes are the events other systems can subscribe to.
+var EventTypes = []string{EventProductCreated, EventProductUpdated, EventProductDeleted, EventProductPriceChanged, EventUserCreated}
+
+// Event is a change made through the API, sent to the systems that
+// subscribed to its type.
+type Event struct {
+	ID        entity.ID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func NewEvent(eventType string, data interface{}) Event {
+	return Event{
+		ID:        entity.NewID(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+func IsEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeletedOutput is the data of the events of deleted records.
+type DeletedOutput struct {
+	ID string `json:"id"`
+}
+
+// PriceChangedOutput is the data of the product.price_changed events.
+type PriceChangedOutput struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Category string  `json:"category,omitempty"`
+	OldPrice float64 `json:"old_price"`
+	Price    float64 `json:"price"`
+}
+
+func NewPriceChangedOutput(product *Product, oldPrice float64) PriceChangedOutput {
+	return PriceChangedOutput{
+		ID:       product.ID.String(),
+		Name:     product.Name,
+		Category: product.Category,
+		OldPrice: oldPrice,
+		Price:    product.Price,
+	}
+}
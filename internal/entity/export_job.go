@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatXLSX   = "xlsx"
+
+	ExportStatusPending   = "pending"
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+var ErrInvalidExportFormat = errors.New("export format must be csv, ndjson or xlsx")
+
+// ExportJob tracks an export written in the background to a file its owner
+// downloads once it completed. The file is kept until ExpiresAt.
+type ExportJob struct {
+	ID      entity.ID `json:"id" gorm:"primaryKey"`
+	OwnerID string    `json:"-" gorm:"index"`
+	Format  string    `json:"format"`
+	// Columns and Sort are the options the export was requested with.
+	Columns string `json:"columns"`
+	Sort    string `json:"sort,omitempty"`
+	Status  string `json:"status"`
+	// Rows counts the products written once the export finished.
+	Rows        int        `json:"rows"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty" gorm:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"index"`
+}
+
+func NewExportJob(ownerID, format, columns, sort string, ttl time.Duration) (*ExportJob, error) {
+	if !IsExportFormat(format) {
+		return nil, ErrInvalidExportFormat
+	}
+	now := time.Now()
+	return &ExportJob{
+		ID:        entity.NewID(),
+		OwnerID:   ownerID,
+		Format:    format,
+		Columns:   columns,
+		Sort:      sort,
+		Status:    ExportStatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+func IsExportFormat(format string) bool {
+	return format == ExportFormatCSV || format == ExportFormatNDJSON || format == ExportFormatXLSX
+}
+
+func (j *ExportJob) Start() {
+	now := time.Now()
+	j.Status = ExportStatusRunning
+	j.StartedAt = &now
+}
+
+func (j *ExportJob) Finish(err error) {
+	now := time.Now()
+	j.FinishedAt = &now
+	if err != nil {
+		j.Status = ExportStatusFailed
+		j.Error = err.Error()
+		return
+	}
+	j.Status = ExportStatusCompleted
+}
+
+// IsDownloadable reports whether the file of the export can be downloaded.
+func (j *ExportJob) IsDownloadable(now time.Time) bool {
+	return j.Status == ExportStatusCompleted && now.Before(j.ExpiresAt)
+}
+
+// FileName is the name the export is downloaded as.
+func (j *ExportJob) FileName() string {
+	return "products-" + j.CreatedAt.UTC().Format("20060102-150405") + "." + j.Format
+}
@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewExportJob(t *testing.T) {
+	job, err := NewExportJob("user-1", ExportFormatXLSX, "name,price", "desc", time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, job.ID)
+	assert.Equal(t, ExportStatusPending, job.Status)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), job.ExpiresAt, time.Second)
+	assert.Regexp(t, `^products-\d{8}-\d{6}\.xlsx$`, job.FileName())
+
+	_, err = NewExportJob("user-1", "pdf", "", "", time.Hour)
+	assert.Equal(t, ErrInvalidExportFormat, err)
+}
+
+func TestExportJobIsDownloadable(t *testing.T) {
+	job, _ := NewExportJob("user-1", ExportFormatCSV, "", "", time.Hour)
+	assert.False(t, job.IsDownloadable(time.Now()))
+
+	job.Start()
+	job.Finish(nil)
+	assert.True(t, job.IsDownloadable(time.Now()))
+	assert.False(t, job.IsDownloadable(time.Now().Add(2*time.Hour)))
+
+	job, _ = NewExportJob("user-1", ExportFormatCSV, "", "", time.Hour)
+	job.Finish(errors.New("disk full"))
+	assert.Equal(t, ExportStatusFailed, job.Status)
+	assert.False(t, job.IsDownloadable(time.Now()))
+}
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// IdempotencyKeyMaxLength bounds the Idempotency-Key header value.
+const IdempotencyKeyMaxLength = 255
+
+var (
+	ErrInvalidIdempotencyKey  = errors.New("idempotency key must have between 1 and 255 characters")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key already used with a different request")
+	ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is still being processed")
+	ErrIdempotencyKeyLost     = errors.New("idempotency key was taken over by another request")
+)
+
+// IdempotencyKey remembers the response given to a request so retries with
+// the same key get the same response instead of repeating the side effects.
+// Keys are scoped to the caller, so two clients can use the same value.
+type IdempotencyKey struct {
+	// ID is the hash of the scope and the key sent by the client.
+	ID string `gorm:"primaryKey"`
+	// Fingerprint is the hash of the request the key was first used with.
+	Fingerprint    string
+	Completed      bool
+	StatusCode     int
+	ResponseHeader string
+	ResponseBody   []byte
+	CreatedAt      time.Time
+	ExpiresAt      time.Time `gorm:"index"`
+	// LockedUntil ends the lease of the request processing the key. A key
+	// still in flight after it is left by a request that died and can be
+	// taken over.
+	LockedUntil time.Time
+}
+
+func NewIdempotencyKey(scope, key, fingerprint string, ttl, lease time.Duration) (*IdempotencyKey, error) {
+	if key == "" || len(key) > IdempotencyKeyMaxLength {
+		return nil, ErrInvalidIdempotencyKey
+	}
+	now := time.Now()
+	return &IdempotencyKey{
+		ID:          IdempotencyKeyID(scope, key),
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+		LockedUntil: now.Add(lease),
+	}, nil
+}
+
+func IdempotencyKeyID(scope, key string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Check tells whether a request with fingerprint may be answered with the
+// stored response.
+func (k *IdempotencyKey) Check(fingerprint string) error {
+	if k.Fingerprint != fingerprint {
+		return ErrIdempotencyKeyReused
+	}
+	if !k.Completed {
+		return ErrIdempotencyKeyInFlight
+	}
+	return nil
+}
+
+func (k *IdempotencyKey) Complete(statusCode int, header string, body []byte) {
+	k.Completed = true
+	k.StatusCode = statusCode
+	k.ResponseHeader = header
+	k.ResponseBody = body
+}
+
+func (k *IdempotencyKey) IsExpired(now time.Time) bool {
+	return now.After(k.ExpiresAt)
+}
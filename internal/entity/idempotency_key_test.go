@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewIdempotencyKey(t *testing.T) {
+	key, err := NewIdempotencyKey("user-1", "abc", "fingerprint", time.Hour, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, IdempotencyKeyID("user-1", "abc"), key.ID)
+	assert.NotEqual(t, IdempotencyKeyID("user-2", "abc"), key.ID)
+	assert.False(t, key.Completed)
+	assert.False(t, key.IsExpired(time.Now()))
+	assert.True(t, key.IsExpired(time.Now().Add(2*time.Hour)))
+	assert.True(t, key.LockedUntil.Before(key.ExpiresAt))
+}
+
+func TestNewIdempotencyKeyWhenKeyIsInvalid(t *testing.T) {
+	_, err := NewIdempotencyKey("user-1", "", "fingerprint", time.Hour, time.Minute)
+	assert.Equal(t, ErrInvalidIdempotencyKey, err)
+
+	_, err = NewIdempotencyKey("user-1", strings.Repeat("a", IdempotencyKeyMaxLength+1), "fingerprint", time.Hour, time.Minute)
+	assert.Equal(t, ErrInvalidIdempotencyKey, err)
+}
+
+func TestIdempotencyKeyCheck(t *testing.T) {
+	key, _ := NewIdempotencyKey("user-1", "abc", "fingerprint", time.Hour, time.Minute)
+	assert.Equal(t, ErrIdempotencyKeyInFlight, key.Check("fingerprint"))
+	assert.Equal(t, ErrIdempotencyKeyReused, key.Check("other"))
+
+	key.Complete(201, `{"Location":["/products/1"]}`, []byte("{}"))
+	assert.Nil(t, key.Check("fingerprint"))
+	assert.Equal(t, ErrIdempotencyKeyReused, key.Check("other"))
+	assert.Equal(t, 201, key.StatusCode)
+}
@@ -0,0 +1,104 @@
+package entity
+
+import (
+	"errors"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+
+	// ImportMaxReportedErrors bounds the row errors kept in a report. Rows
+	// failing beyond it are only counted.
+	ImportMaxReportedErrors = 1000
+)
+
+var ErrInvalidImportFormat = errors.New("import format must be csv or ndjson")
+
+// ImportRowError explains why a row of an import was rejected. Line is the
+// 1-based line or record number in the uploaded file.
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportReport counts what an import did, or would do in a dry run.
+type ImportReport struct {
+	DryRun    bool             `json:"dry_run"`
+	Upsert    bool             `json:"upsert"`
+	Processed int              `json:"processed"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Failed    int              `json:"failed"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+func (r *ImportReport) AddError(line int, sku string, err error) {
+	r.Failed++
+	if len(r.Errors) < ImportMaxReportedErrors {
+		r.Errors = append(r.Errors, ImportRowError{Line: line, SKU: sku, Error: err.Error()})
+	}
+}
+
+// ImportJob tracks an import running in the background so its owner can
+// poll the progress.
+type ImportJob struct {
+	ID      entity.ID `json:"id" gorm:"primaryKey"`
+	OwnerID string    `json:"-" gorm:"index"`
+	Format  string    `json:"format"`
+	Status  string    `json:"status"`
+	// Total is the number of rows in the file, known once it was counted.
+	Total  int          `json:"total"`
+	Report ImportReport `json:"report" gorm:"serializer:json"`
+	// Error is set when the job failed as a whole, e.g. the database became
+	// unavailable; rows processed before it stay imported.
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func NewImportJob(ownerID, format string, dryRun, upsert bool) (*ImportJob, error) {
+	if format != ImportFormatCSV && format != ImportFormatNDJSON {
+		return nil, ErrInvalidImportFormat
+	}
+	return &ImportJob{
+		ID:        entity.NewID(),
+		OwnerID:   ownerID,
+		Format:    format,
+		Status:    ImportStatusPending,
+		Report:    ImportReport{DryRun: dryRun, Upsert: upsert, Errors: []ImportRowError{}},
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (j *ImportJob) Start(total int) {
+	now := time.Now()
+	j.Status = ImportStatusRunning
+	j.Total = total
+	j.StartedAt = &now
+}
+
+func (j *ImportJob) Finish(err error) {
+	now := time.Now()
+	j.FinishedAt = &now
+	if err != nil {
+		j.Status = ImportStatusFailed
+		j.Error = err.Error()
+		return
+	}
+	j.Status = ImportStatusCompleted
+}
+
+func (j *ImportJob) IsFinished() bool {
+	return j.Status == ImportStatusCompleted || j.Status == ImportStatusFailed
+}
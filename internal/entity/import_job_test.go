@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewImportJob(t *testing.T) {
+	job, err := NewImportJob("user-1", ImportFormatCSV, true, false)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, job.ID)
+	assert.Equal(t, ImportStatusPending, job.Status)
+	assert.True(t, job.Report.DryRun)
+	assert.False(t, job.IsFinished())
+
+	_, err = NewImportJob("user-1", "xml", false, false)
+	assert.Equal(t, ErrInvalidImportFormat, err)
+}
+
+func TestImportJobLifecycle(t *testing.T) {
+	job, _ := NewImportJob("user-1", ImportFormatNDJSON, false, true)
+	job.Start(10)
+	assert.Equal(t, ImportStatusRunning, job.Status)
+	assert.Equal(t, 10, job.Total)
+	assert.NotNil(t, job.StartedAt)
+
+	job.Finish(nil)
+	assert.Equal(t, ImportStatusCompleted, job.Status)
+	assert.True(t, job.IsFinished())
+
+	job, _ = NewImportJob("user-1", ImportFormatNDJSON, false, true)
+	job.Finish(errors.New("database is down"))
+	assert.Equal(t, ImportStatusFailed, job.Status)
+	assert.Equal(t, "database is down", job.Error)
+}
+
+func TestImportReportCapsErrors(t *testing.T) {
+	var report ImportReport
+	for i := 0; i < ImportMaxReportedErrors+5; i++ {
+		report.AddError(i+1, "", ErrNameIsRequired)
+	}
+	assert.Equal(t, ImportMaxReportedErrors+5, report.Failed)
+	assert.Len(t, report.Errors, ImportMaxReportedErrors)
+	assert.Equal(t, ImportRowError{Line: 1, Error: ErrNameIsRequired.Error()}, report.Errors[0])
+}
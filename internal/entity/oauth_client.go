@@ -0,0 +1,120 @@
+package entity
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypePassword          = "password"
+)
+
+var (
+	ErrInvalidGrantType    = errors.New("invalid grant type")
+	ErrGrantTypesRequired  = errors.New("at least one grant type is required")
+	ErrInvalidClientSecret = errors.New("invalid client secret")
+)
+
+// OAuthClient is a service registered to obtain tokens from /oauth/token.
+// Only the hash of the client secret is stored.
+type OAuthClient struct {
+	ID         entity.ID `json:"client_id"`
+	Name       string    `json:"name"`
+	SecretHash string    `json:"-"`
+	GrantTypes string    `json:"grant_types"`
+	Scopes     string    `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NewOAuthClient registers a client and returns it with the plain secret,
+// which is only shown once.
+func NewOAuthClient(name string, grantTypes, scopes []string) (*OAuthClient, string, error) {
+	if name == "" {
+		return nil, "", ErrNameIsRequired
+	}
+	if len(grantTypes) == 0 {
+		return nil, "", ErrGrantTypesRequired
+	}
+	for _, grantType := range grantTypes {
+		if grantType != GrantTypeClientCredentials && grantType != GrantTypePassword {
+			return nil, "", ErrInvalidGrantType
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, "", ErrScopesRequired
+	}
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return nil, "", ErrInvalidScope
+		}
+	}
+
+	secret, err := GenerateSecret(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &OAuthClient{
+		ID:         entity.NewID(),
+		Name:       name,
+		SecretHash: HashToken(secret),
+		GrantTypes: strings.Join(grantTypes, " "),
+		Scopes:     strings.Join(scopes, " "),
+		CreatedAt:  time.Now(),
+	}, secret, nil
+}
+
+func (c *OAuthClient) ValidateSecret(secret string) error {
+	if subtle.ConstantTimeCompare([]byte(c.SecretHash), []byte(HashToken(secret))) != 1 {
+		return ErrInvalidClientSecret
+	}
+	return nil
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, g := range strings.Fields(c.GrantTypes) {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// GrantScopes returns the requested scopes the client may use, or every scope
+// of the client when none is requested.
+func (c *OAuthClient) GrantScopes(requested []string) ([]string, error) {
+	allowed := c.ScopeList()
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+	for _, scope := range requested {
+		found := false
+		for _, a := range allowed {
+			if a == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrInvalidScope
+		}
+	}
+	return requested, nil
+}
+
+// RevokedToken records the jti of an access token revoked before expiring.
+// Rows can be deleted once ExpiresAt has passed.
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOAuthClient(t *testing.T) {
+	client, secret, err := NewOAuthClient("billing", []string{GrantTypeClientCredentials}, []string{ScopeProductsRead})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, client.ID)
+	assert.NotEqual(t, secret, client.SecretHash)
+	assert.Nil(t, client.ValidateSecret(secret))
+	assert.Equal(t, ErrInvalidClientSecret, client.ValidateSecret("wrong"))
+	assert.True(t, client.AllowsGrant(GrantTypeClientCredentials))
+	assert.False(t, client.AllowsGrant(GrantTypePassword))
+}
+
+func TestNewOAuthClientWhenInvalid(t *testing.T) {
+	_, _, err := NewOAuthClient("", []string{GrantTypePassword}, []string{ScopeProductsRead})
+	assert.Equal(t, ErrNameIsRequired, err)
+
+	_, _, err = NewOAuthClient("billing", []string{"implicit"}, []string{ScopeProductsRead})
+	assert.Equal(t, ErrInvalidGrantType, err)
+
+	_, _, err = NewOAuthClient("billing", nil, []string{ScopeProductsRead})
+	assert.Equal(t, ErrGrantTypesRequired, err)
+
+	_, _, err = NewOAuthClient("billing", []string{GrantTypePassword}, []string{"admin"})
+	assert.Equal(t, ErrInvalidScope, err)
+}
+
+func TestOAuthClient_GrantScopes(t *testing.T) {
+	client, _, _ := NewOAuthClient("billing", []string{GrantTypeClientCredentials}, []string{ScopeProductsRead, ScopeProductsWrite})
+
+	scopes, err := client.GrantScopes(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{ScopeProductsRead, ScopeProductsWrite}, scopes)
+
+	scopes, err = client.GrantScopes([]string{ScopeProductsRead})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{ScopeProductsRead}, scopes)
+
+	client, _, _ = NewOAuthClient("billing", []string{GrantTypeClientCredentials}, []string{ScopeProductsRead})
+	_, err = client.GrantScopes([]string{ScopeProductsWrite})
+	assert.Equal(t, ErrInvalidScope, err)
+}
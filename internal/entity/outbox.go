@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const (
+	AggregateProduct = "product"
+	AggregateUser    = "user"
+)
+
+// OutboxMessage is an event stored in the same transaction as the change it
+// describes, waiting to be published. ID grows with every message, so it
+// orders the events of an aggregate.
+type OutboxMessage struct {
+	ID            uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	EventID       entity.ID `json:"event_id" gorm:"uniqueIndex"`
+	EventType     string    `json:"event_type"`
+	AggregateType string    `json:"aggregate_type"`
+	AggregateID   string    `json:"aggregate_id"`
+	// Payload is the JSON of the event.
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	CreatedAt   time.Time       `json:"created_at"`
+	PublishedAt *time.Time      `json:"published_at,omitempty" gorm:"index"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	// DeadAt is set when the message failed too many times; it is no longer
+	// published nor holds back its aggregate.
+	DeadAt *time.Time `json:"dead_at,omitempty" gorm:"index"`
+}
+
+func NewOutboxMessage(event Event, aggregateType, aggregateID string) (*OutboxMessage, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxMessage{
+		EventID:       event.ID,
+		EventType:     event.Type,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       payload,
+		CreatedAt:     event.CreatedAt,
+	}, nil
+}
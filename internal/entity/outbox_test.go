@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOutboxMessage(t *testing.T) {
+	product, _ := NewProduct("Product", 10)
+	event := NewEvent(EventProductCreated, product)
+	msg, err := NewOutboxMessage(event, AggregateProduct, product.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, event.ID, msg.EventID)
+	assert.Equal(t, EventProductCreated, msg.EventType)
+	assert.Equal(t, AggregateProduct, msg.AggregateType)
+	assert.Equal(t, product.ID.String(), msg.AggregateID)
+	assert.Nil(t, msg.PublishedAt)
+
+	var payload struct {
+		ID   string  `json:"id"`
+		Type string  `json:"type"`
+		Data Product `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(msg.Payload, &payload))
+	assert.Equal(t, event.ID.String(), payload.ID)
+	assert.Equal(t, EventProductCreated, payload.Type)
+	assert.Equal(t, product.Name, payload.Data.Name)
+}
@@ -1,7 +1,9 @@
 package entity
 
 import (
+	"encoding/xml"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/leobelini-studies/go_expert_api/pkg/entity"
@@ -13,13 +15,30 @@ var (
 	ErrNameIsRequired  = errors.New("name is required")
 	ErrPriceIsRequired = errors.New("price is required")
 	ErrInvalidPrice    = errors.New("invalid price")
+	ErrInvalidSKU      = errors.New("sku must have at most 64 characters and no spaces")
+	ErrSKUAlreadyUsed  = errors.New("sku already used by another product")
+	ErrInvalidCategory = errors.New("category must have at most 64 characters")
+)
+
+const (
+	// SKUMaxLength bounds the stock keeping unit of a product.
+	SKUMaxLength = 64
+	// CategoryMaxLength bounds the category of a product.
+	CategoryMaxLength = 64
 )
 
 type Product struct {
-	ID        entity.ID `json:"id"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
+	XMLName xml.Name  `json:"-" xml:"product" gorm:"-"`
+	ID      entity.ID `json:"id" xml:"id"`
+	Name    string    `json:"name" xml:"name"`
+	Price   float64   `json:"price" xml:"price"`
+	// SKU optionally identifies the product in external catalogs. It is
+	// unique when set.
+	SKU string `json:"sku,omitempty" xml:"sku,omitempty" gorm:"index:idx_products_sku,unique,where:sku <> ''"`
+	// Category optionally groups products, e.g. for the clients following
+	// the price changes of a category.
+	Category  string    `json:"category,omitempty" xml:"category,omitempty" gorm:"index"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
 }
 
 func NewProduct(name string, price float64) (*Product, error) {
@@ -59,5 +78,13 @@ func (p *Product) Validate() error {
 		return ErrInvalidPrice
 	}
 
+	if len(p.SKU) > SKUMaxLength || strings.ContainsAny(p.SKU, " \t\r\n") {
+		return ErrInvalidSKU
+	}
+
+	if len(p.Category) > CategoryMaxLength {
+		return ErrInvalidCategory
+	}
+
 	return nil
 }
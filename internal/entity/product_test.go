@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -38,3 +39,28 @@ func TestProductValidate(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, product.Validate())
 }
+
+func TestProductWhenSKUIsInvalid(t *testing.T) {
+	product, err := NewProduct("Product 1", 10)
+	assert.Nil(t, err)
+
+	product.SKU = "ABC-123"
+	assert.Nil(t, product.Validate())
+
+	product.SKU = "ABC 123"
+	assert.Equal(t, ErrInvalidSKU, product.Validate())
+
+	product.SKU = strings.Repeat("A", SKUMaxLength+1)
+	assert.Equal(t, ErrInvalidSKU, product.Validate())
+}
+
+func TestProductWhenCategoryIsInvalid(t *testing.T) {
+	product, err := NewProduct("Product 1", 10)
+	assert.Nil(t, err)
+
+	product.Category = "drinks"
+	assert.Nil(t, product.Validate())
+
+	product.Category = strings.Repeat("A", CategoryMaxLength+1)
+	assert.Equal(t, ErrInvalidCategory, product.Validate())
+}
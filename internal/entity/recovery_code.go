@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const RecoveryCodesCount = 10
+
+// RecoveryCode lets a user finish an MFA login without the authenticator.
+// Codes are single use and only their hash is stored.
+type RecoveryCode struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewRecoveryCodes creates n codes for the user and returns the plain codes,
+// which are shown once and never stored.
+func NewRecoveryCodes(userID entity.ID, n int) ([]*RecoveryCode, []string, error) {
+	codes := make([]*RecoveryCode, 0, n)
+	plain := make([]string, 0, n)
+	now := time.Now()
+
+	for i := 0; i < n; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code := raw[:8] + "-" + raw[8:16]
+
+		codes = append(codes, &RecoveryCode{
+			ID:        entity.NewID(),
+			UserID:    userID,
+			CodeHash:  HashRecoveryCode(code),
+			CreatedAt: now,
+		})
+		plain = append(plain, code)
+	}
+
+	return codes, plain, nil
+}
+
+// HashRecoveryCode normalizes the code so that case and separators typed by
+// the user do not matter, then hashes it.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return HashToken(code)
+}
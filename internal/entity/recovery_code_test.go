@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	codes, plain, err := NewRecoveryCodes(user.ID, RecoveryCodesCount)
+	assert.Nil(t, err)
+	assert.Len(t, codes, RecoveryCodesCount)
+	assert.Len(t, plain, RecoveryCodesCount)
+
+	for i, code := range codes {
+		assert.Equal(t, user.ID, code.UserID)
+		assert.Len(t, plain[i], 17)
+		assert.Equal(t, HashRecoveryCode(plain[i]), code.CodeHash)
+		assert.NotContains(t, code.CodeHash, plain[i])
+	}
+}
+
+func TestHashRecoveryCodeIsNormalized(t *testing.T) {
+	assert.Equal(t, HashRecoveryCode("abcd1234-efgh5678"), HashRecoveryCode(strings.ToUpper("abcd 1234efgh5678")))
+}
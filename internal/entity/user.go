@@ -1,15 +1,41 @@
 package entity
 
 import (
+	"errors"
+	"time"
+
 	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"github.com/leobelini-studies/go_expert_api/pkg/totp"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// MFASkew is the number of TOTP steps accepted before and after the current
+// one to tolerate clock drift between the server and the user device.
+const MFASkew = 1
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	ErrMFANotEnabled     = errors.New("mfa not enabled")
+	ErrMFANotEnrolled    = errors.New("mfa enrollment not started")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrMFACodeReused     = errors.New("mfa code already used")
+)
+
 type User struct {
-	ID       entity.ID `json:"id"`
-	Name     string    `json:"name"`
-	Email    string    `json:"email"`
-	Password string    `json:"-"`
+	ID              entity.ID  `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	MFAEnabled      bool       `json:"mfa_enabled"`
+	MFASecret       string     `json:"-"`
+	MFALastStep     int64      `json:"-"`
 }
 
 func NewUser(name, email, password string) (*User, error) {
@@ -23,6 +49,7 @@ func NewUser(name, email, password string) (*User, error) {
 		Name:     name,
 		Email:    email,
 		Password: string(passwordHash),
+		Role:     RoleUser,
 	}, nil
 }
 
@@ -30,3 +57,84 @@ func (u *User) ValidatePassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
 }
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+func (u *User) ChangePassword(password string) error {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.Password = string(passwordHash)
+	return nil
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) VerifyEmail() {
+	if u.IsEmailVerified() {
+		return
+	}
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+}
+
+// EnrollMFA generates a new TOTP secret. MFA is only enforced after the user
+// proves the authenticator works through EnableMFA.
+func (u *User) EnrollMFA() (string, error) {
+	if u.MFAEnabled {
+		return "", ErrMFAAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	u.MFASecret = secret
+	u.MFALastStep = 0
+	return secret, nil
+}
+
+func (u *User) EnableMFA(code string, now time.Time) error {
+	if u.MFAEnabled {
+		return ErrMFAAlreadyEnabled
+	}
+	if u.MFASecret == "" {
+		return ErrMFANotEnrolled
+	}
+	if err := u.checkMFACode(code, now); err != nil {
+		return err
+	}
+	u.MFAEnabled = true
+	return nil
+}
+
+func (u *User) DisableMFA() {
+	u.MFAEnabled = false
+	u.MFASecret = ""
+	u.MFALastStep = 0
+}
+
+// ValidateMFACode checks a TOTP code and records its time step so the same
+// code cannot be replayed.
+func (u *User) ValidateMFACode(code string, now time.Time) error {
+	if !u.MFAEnabled {
+		return ErrMFANotEnabled
+	}
+	return u.checkMFACode(code, now)
+}
+
+func (u *User) checkMFACode(code string, now time.Time) error {
+	step, ok := totp.Validate(u.MFASecret, code, now, MFASkew)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	if step <= u.MFALastStep {
+		return ErrMFACodeReused
+	}
+	u.MFALastStep = step
+	return nil
+}
@@ -2,7 +2,9 @@ package entity
 
 import (
 	"testing"
+	"time"
 
+	"github.com/leobelini-studies/go_expert_api/pkg/totp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,6 +16,8 @@ func TestNewUser(t *testing.T) {
 	assert.NotEmpty(t, user.Password)
 	assert.Equal(t, "John Doe", user.Name)
 	assert.Equal(t, "1y3t3@example.com", user.Email)
+	assert.Equal(t, RoleUser, user.Role)
+	assert.False(t, user.IsAdmin())
 }
 
 func TestUser_ValidatePassword(t *testing.T) {
@@ -23,3 +27,64 @@ func TestUser_ValidatePassword(t *testing.T) {
 	assert.False(t, user.ValidatePassword("wrong-password"))
 	assert.NotEqual(t, user.Password, "wrong-password")
 }
+
+func TestUser_ChangePassword(t *testing.T) {
+	user, err := NewUser("John Doe", "1y3t3@example.com", "password")
+	assert.Nil(t, err)
+	assert.Nil(t, user.ChangePassword("new-password"))
+	assert.True(t, user.ValidatePassword("new-password"))
+	assert.False(t, user.ValidatePassword("password"))
+}
+
+func TestUser_VerifyEmail(t *testing.T) {
+	user, err := NewUser("John Doe", "1y3t3@example.com", "password")
+	assert.Nil(t, err)
+	assert.False(t, user.IsEmailVerified())
+	user.VerifyEmail()
+	assert.True(t, user.IsEmailVerified())
+}
+
+func TestUser_EnableMFA(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	now := time.Now()
+
+	assert.Equal(t, ErrMFANotEnrolled, user.EnableMFA("000000", now))
+
+	secret, err := user.EnrollMFA()
+	assert.Nil(t, err)
+	assert.Equal(t, secret, user.MFASecret)
+	assert.False(t, user.MFAEnabled)
+
+	assert.Equal(t, ErrInvalidMFACode, user.EnableMFA("000000x", now))
+
+	code, _ := totp.Code(secret, totp.Step(now))
+	assert.Nil(t, user.EnableMFA(code, now))
+	assert.True(t, user.MFAEnabled)
+
+	_, err = user.EnrollMFA()
+	assert.Equal(t, ErrMFAAlreadyEnabled, err)
+}
+
+func TestUser_ValidateMFACode(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	now := time.Now()
+	assert.Equal(t, ErrMFANotEnabled, user.ValidateMFACode("000000", now))
+
+	secret, _ := user.EnrollMFA()
+	code, _ := totp.Code(secret, totp.Step(now)-1)
+	assert.Nil(t, user.EnableMFA(code, now))
+
+	// the code used to enable MFA cannot be replayed
+	assert.Equal(t, ErrMFACodeReused, user.ValidateMFACode(code, now))
+
+	code, _ = totp.Code(secret, totp.Step(now)+1)
+	assert.Nil(t, user.ValidateMFACode(code, now))
+	assert.Equal(t, ErrMFACodeReused, user.ValidateMFACode(code, now))
+
+	code, _ = totp.Code(secret, totp.Step(now)+3)
+	assert.Equal(t, ErrInvalidMFACode, user.ValidateMFACode(code, now))
+
+	user.DisableMFA()
+	assert.False(t, user.MFAEnabled)
+	assert.Empty(t, user.MFASecret)
+}
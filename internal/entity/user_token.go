@@ -0,0 +1,82 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const (
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypePasswordReset     = "password_reset"
+)
+
+var (
+	ErrInvalidTokenType = errors.New("invalid token type")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenAlreadyUsed = errors.New("token already used")
+)
+
+// UserToken is a single-use secret sent to the user by email. Only the
+// SHA-256 hash of the secret is persisted.
+type UserToken struct {
+	ID        entity.ID  `json:"id"`
+	UserID    entity.ID  `json:"user_id" gorm:"index"`
+	Type      string     `json:"type" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NewUserToken creates a token for the user and returns it together with the
+// plain secret, which must be delivered to the user and never stored.
+func NewUserToken(userID entity.ID, tokenType string, ttl time.Duration) (*UserToken, string, error) {
+	if tokenType != TokenTypeEmailVerification && tokenType != TokenTypePasswordReset {
+		return nil, "", ErrInvalidTokenType
+	}
+
+	secret, err := GenerateSecret(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	return &UserToken{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		Type:      tokenType,
+		TokenHash: HashToken(secret),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, secret, nil
+}
+
+func (t *UserToken) Validate() error {
+	if t.UsedAt != nil {
+		return ErrTokenAlreadyUsed
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// GenerateSecret returns n random bytes encoded as URL-safe base64.
+func GenerateSecret(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUserToken(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	token, secret, err := NewUserToken(user.ID, TokenTypeEmailVerification, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, secret)
+	assert.Equal(t, user.ID, token.UserID)
+	assert.Equal(t, HashToken(secret), token.TokenHash)
+	assert.NotEqual(t, secret, token.TokenHash)
+	assert.Nil(t, token.Validate())
+}
+
+func TestNewUserTokenWhenTypeIsInvalid(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	token, _, err := NewUserToken(user.ID, "invalid", time.Hour)
+	assert.Nil(t, token)
+	assert.Equal(t, ErrInvalidTokenType, err)
+}
+
+func TestUserToken_Validate(t *testing.T) {
+	user, _ := NewUser("John Doe", "1y3t3@example.com", "password")
+	token, _, err := NewUserToken(user.ID, TokenTypePasswordReset, -time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrTokenExpired, token.Validate())
+
+	token, _, _ = NewUserToken(user.ID, TokenTypePasswordReset, time.Hour)
+	now := time.Now()
+	token.UsedAt = &now
+	assert.Equal(t, ErrTokenAlreadyUsed, token.Validate())
+}
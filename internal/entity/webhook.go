@@ -0,0 +1,141 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	// DeliveryStatusDead marks deliveries that failed every attempt. They
+	// stay listed until redelivered.
+	DeliveryStatusDead = "dead"
+)
+
+var (
+	ErrInvalidWebhookURL = errors.New("url must be an absolute http or https url")
+	ErrEventsRequired    = errors.New("at least one event is required")
+	ErrInvalidEvent      = errors.New("invalid event")
+)
+
+// Webhook subscribes a URL to events. The secret signs every delivery, so
+// it is stored in clear and only shown when the webhook is created.
+type Webhook struct {
+	ID        entity.ID `json:"id"`
+	UserID    entity.ID `json:"user_id" gorm:"index"`
+	URL       string    `json:"url"`
+	Events    string    `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewWebhook creates a webhook and returns it with its secret, generated
+// when empty.
+func NewWebhook(userID entity.ID, rawURL string, events []string, secret string) (*Webhook, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", ErrInvalidWebhookURL
+	}
+	if len(events) == 0 {
+		return nil, "", ErrEventsRequired
+	}
+	for _, event := range events {
+		if !IsEventType(event) {
+			return nil, "", ErrInvalidEvent
+		}
+	}
+	if secret == "" {
+		if secret, err = GenerateSecret(32); err != nil {
+			return nil, "", err
+		}
+	}
+	return &Webhook{
+		ID:        entity.NewID(),
+		UserID:    userID,
+		URL:       u.String(),
+		Events:    strings.Join(events, ","),
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}, secret, nil
+}
+
+func (w *Webhook) EventList() []string {
+	return strings.Split(w.Events, ",")
+}
+
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, event := range w.EventList() {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is an event to send to a webhook, kept with the outcome
+// of its attempts. An event is delivered once per webhook, however many
+// times the outbox relay publishes it.
+type WebhookDelivery struct {
+	ID        entity.ID `json:"id"`
+	WebhookID entity.ID `json:"webhook_id" gorm:"uniqueIndex:idx_webhook_deliveries_event"`
+	EventID   entity.ID `json:"event_id" gorm:"uniqueIndex:idx_webhook_deliveries_event"`
+	Event     string    `json:"event"`
+	// Payload is the body sent, the JSON of the event.
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" gorm:"index"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" gorm:"index"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func NewWebhookDelivery(webhookID entity.ID, event Event, payload []byte) *WebhookDelivery {
+	now := time.Now()
+	return &WebhookDelivery{
+		ID:            entity.NewID(),
+		WebhookID:     webhookID,
+		EventID:       event.ID,
+		Event:         event.Type,
+		Payload:       payload,
+		Status:        DeliveryStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+func (d *WebhookDelivery) Succeed(statusCode int) {
+	now := time.Now()
+	d.Attempts++
+	d.Status = DeliveryStatusSucceeded
+	d.LastStatusCode = statusCode
+	d.LastError = ""
+	d.DeliveredAt = &now
+}
+
+// Fail records a failed attempt. The delivery is retried after backoff, or
+// is dead once maxAttempts were made.
+func (d *WebhookDelivery) Fail(statusCode int, err error, maxAttempts int, backoff time.Duration) {
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	d.LastError = err.Error()
+	if d.Attempts >= maxAttempts {
+		d.Status = DeliveryStatusDead
+		return
+	}
+	d.NextAttemptAt = time.Now().Add(backoff)
+}
+
+// Redeliver schedules the delivery again with a fresh set of attempts.
+func (d *WebhookDelivery) Redeliver() {
+	d.Status = DeliveryStatusPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+}
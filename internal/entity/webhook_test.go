@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWebhook(t *testing.T) {
+	userID := entity.NewID()
+	webhook, secret, err := NewWebhook(userID, "https://example.com/hooks", []string{EventProductCreated, EventUserCreated}, "")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, secret)
+	assert.Equal(t, secret, webhook.Secret)
+	assert.True(t, webhook.Subscribes(EventUserCreated))
+	assert.False(t, webhook.Subscribes(EventProductDeleted))
+
+	webhook, secret, err = NewWebhook(userID, "http://example.com", []string{EventProductDeleted}, "my-secret")
+	assert.Nil(t, err)
+	assert.Equal(t, "my-secret", secret)
+	assert.Equal(t, []string{EventProductDeleted}, webhook.EventList())
+}
+
+func TestNewWebhookValidation(t *testing.T) {
+	userID := entity.NewID()
+	for _, u := range []string{"", "example.com/hooks", "ftp://example.com", "https://"} {
+		_, _, err := NewWebhook(userID, u, []string{EventProductCreated}, "")
+		assert.Equal(t, ErrInvalidWebhookURL, err, u)
+	}
+	_, _, err := NewWebhook(userID, "https://example.com", nil, "")
+	assert.Equal(t, ErrEventsRequired, err)
+	_, _, err = NewWebhook(userID, "https://example.com", []string{"product.sold"}, "")
+	assert.Equal(t, ErrInvalidEvent, err)
+}
+
+func TestWebhookDeliveryAttempts(t *testing.T) {
+	event := NewEvent(EventProductCreated, nil)
+	d := NewWebhookDelivery(entity.NewID(), event, []byte(`{}`))
+	assert.Equal(t, DeliveryStatusPending, d.Status)
+	assert.Equal(t, event.ID, d.EventID)
+
+	d.Fail(500, errors.New("server error"), 2, time.Minute)
+	assert.Equal(t, DeliveryStatusPending, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), d.NextAttemptAt, time.Second)
+
+	d.Fail(0, errors.New("connection refused"), 2, time.Minute)
+	assert.Equal(t, DeliveryStatusDead, d.Status)
+	assert.Equal(t, "connection refused", d.LastError)
+
+	d.Redeliver()
+	assert.Equal(t, DeliveryStatusPending, d.Status)
+	assert.Equal(t, 0, d.Attempts)
+
+	d.Succeed(204)
+	assert.Equal(t, DeliveryStatusSucceeded, d.Status)
+	assert.Equal(t, 204, d.LastStatusCode)
+	assert.Empty(t, d.LastError)
+	assert.NotNil(t, d.DeliveredAt)
+}
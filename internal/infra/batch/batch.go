@@ -0,0 +1,209 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"gorm.io/gorm"
+)
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+
+	DefaultMaxOperations = 1000
+)
+
+var (
+	ErrNoOperations      = errors.New("operations are required")
+	ErrProductIsRequired = errors.New("product is required")
+)
+
+// errRollback ends the transaction of an atomic batch after a failed
+// operation.
+var errRollback = errors.New("batch rolled back")
+
+// Executor applies batches of product operations, each with the rules of
+// the single product endpoints.
+type Executor struct {
+	Products      database.ProductInterface
+	MaxOperations int
+}
+
+func New(products database.ProductInterface, maxOperations int) *Executor {
+	if maxOperations <= 0 {
+		maxOperations = DefaultMaxOperations
+	}
+	return &Executor{
+		Products:      products,
+		MaxOperations: maxOperations,
+	}
+}
+
+// Validate reports a batch that cannot be run at all.
+func (e *Executor) Validate(input dto.BatchInput) error {
+	if len(input.Operations) == 0 {
+		return ErrNoOperations
+	}
+	if len(input.Operations) > e.MaxOperations {
+		return fmt.Errorf("a batch has at most %d operations", e.MaxOperations)
+	}
+	return nil
+}
+
+// Run applies the operations in order and returns the result of each one.
+// In best-effort mode every operation runs and the failures do not affect
+// the others. In atomic mode the operations share a transaction: the first
+// failure rolls back the batch, and the operations that were not applied
+// report 424 Failed Dependency.
+func (e *Executor) Run(ctx context.Context, input dto.BatchInput) dto.BatchOutput {
+	output := dto.BatchOutput{
+		Atomic:  input.Atomic,
+		Results: make([]dto.BatchResult, len(input.Operations)),
+	}
+	if !input.Atomic {
+		for i, op := range input.Operations {
+			output.Results[i] = apply(ctx, e.Products, op)
+		}
+		count(&output)
+		return output
+	}
+
+	failed := -1
+	err := e.Products.Transaction(ctx, func(products database.ProductInterface) error {
+		for i, op := range input.Operations {
+			output.Results[i] = apply(ctx, products, op)
+			if output.Results[i].Status >= http.StatusBadRequest {
+				failed = i
+				return errRollback
+			}
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+	case failed >= 0:
+		notApplied := failure(http.StatusFailedDependency, fmt.Errorf("not applied: operation at index %d failed", failed))
+		for i := range output.Results {
+			if i != failed {
+				output.Results[i] = notApplied
+			}
+		}
+	default:
+		// the commit failed, so none of the operations was applied
+		for i := range output.Results {
+			output.Results[i] = failure(render.ErrorStatus(err, http.StatusInternalServerError), err)
+		}
+	}
+	count(&output)
+	return output
+}
+
+// Apply runs a single operation with the rules of the product endpoints.
+func (e *Executor) Apply(ctx context.Context, op dto.BatchOperation) dto.BatchResult {
+	return apply(ctx, e.Products, op)
+}
+
+func count(output *dto.BatchOutput) {
+	for _, r := range output.Results {
+		if r.Status < http.StatusBadRequest {
+			output.Succeeded++
+		} else {
+			output.Failed++
+		}
+	}
+}
+
+func apply(ctx context.Context, products database.ProductInterface, op dto.BatchOperation) dto.BatchResult {
+	switch op.Op {
+	case OpCreate:
+		return create(ctx, products, op)
+	case OpUpdate:
+		return update(ctx, products, op)
+	case OpDelete:
+		return remove(ctx, products, op)
+	}
+	return failure(http.StatusBadRequest, fmt.Errorf("op must be %s, %s or %s", OpCreate, OpUpdate, OpDelete))
+}
+
+func create(ctx context.Context, products database.ProductInterface, op dto.BatchOperation) dto.BatchResult {
+	if op.Product == nil {
+		return failure(http.StatusBadRequest, ErrProductIsRequired)
+	}
+	p, err := entity.NewProduct(op.Product.Name, op.Product.Price)
+	if err == nil {
+		p.SKU = op.Product.SKU
+		p.Category = op.Product.Category
+		err = p.Validate()
+	}
+	if err != nil {
+		return failure(http.StatusBadRequest, err)
+	}
+	if p.SKU != "" {
+		if _, err := products.FindBySKU(ctx, p.SKU); err == nil {
+			return failure(http.StatusConflict, entity.ErrSKUAlreadyUsed)
+		}
+	}
+	if err := products.Create(ctx, p); err != nil {
+		return failure(render.ErrorStatus(err, http.StatusInternalServerError), err)
+	}
+	return dto.BatchResult{Status: http.StatusCreated, Body: p}
+}
+
+func update(ctx context.Context, products database.ProductInterface, op dto.BatchOperation) dto.BatchResult {
+	if _, err := entityPkg.ParseID(op.ID); err != nil {
+		return failure(http.StatusBadRequest, entity.ErrInvalidID)
+	}
+	if op.Product == nil {
+		return failure(http.StatusBadRequest, ErrProductIsRequired)
+	}
+	p, err := products.FindByID(ctx, op.ID)
+	if err != nil {
+		return failure(findStatus(err), err)
+	}
+	p.Name = op.Product.Name
+	p.Price = op.Product.Price
+	p.SKU = op.Product.SKU
+	p.Category = op.Product.Category
+	if err := p.Validate(); err != nil {
+		return failure(http.StatusBadRequest, err)
+	}
+	if p.SKU != "" {
+		if other, err := products.FindBySKU(ctx, p.SKU); err == nil && other.ID != p.ID {
+			return failure(http.StatusConflict, entity.ErrSKUAlreadyUsed)
+		}
+	}
+	if err := products.Update(ctx, p); err != nil {
+		return failure(render.ErrorStatus(err, http.StatusInternalServerError), err)
+	}
+	return dto.BatchResult{Status: http.StatusOK, Body: p}
+}
+
+func remove(ctx context.Context, products database.ProductInterface, op dto.BatchOperation) dto.BatchResult {
+	if _, err := entityPkg.ParseID(op.ID); err != nil {
+		return failure(http.StatusBadRequest, entity.ErrInvalidID)
+	}
+	if err := products.Delete(ctx, op.ID); err != nil {
+		return failure(findStatus(err), err)
+	}
+	return dto.BatchResult{Status: http.StatusOK}
+}
+
+func failure(status int, err error) dto.BatchResult {
+	return dto.BatchResult{Status: status, Body: dto.ErrorOutput{Message: err.Error()}}
+}
+
+func findStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return render.ErrorStatus(err, http.StatusInternalServerError)
+}
@@ -0,0 +1,122 @@
+package batch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProductDB(t *testing.T) *database.Product {
+	db := testutil.NewSQLite(t, &entity.Product{})
+	return database.NewProduct(db)
+}
+
+func createProduct(t *testing.T, products *database.Product, name, sku string) *entity.Product {
+	p, err := entity.NewProduct(name, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SKU = sku
+	if err := products.Create(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func statuses(output dto.BatchOutput) []int {
+	var s []int
+	for _, r := range output.Results {
+		s = append(s, r.Status)
+	}
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	e := New(nil, 2)
+	assert.Equal(t, ErrNoOperations, e.Validate(dto.BatchInput{}))
+	assert.ErrorContains(t, e.Validate(dto.BatchInput{Operations: make([]dto.BatchOperation, 3)}), "at most 2")
+	assert.Nil(t, e.Validate(dto.BatchInput{Operations: make([]dto.BatchOperation, 2)}))
+}
+
+func TestRunBestEffort(t *testing.T) {
+	products := newProductDB(t)
+	existing := createProduct(t, products, "Existing", "SKU-1")
+	removed := createProduct(t, products, "Removed", "")
+
+	output := New(products, 0).Run(context.Background(), dto.BatchInput{Operations: []dto.BatchOperation{
+		{Op: OpCreate, Product: &dto.CreateProductInput{Name: "New", Price: 5, SKU: "SKU-2"}},
+		{Op: OpCreate, Product: &dto.CreateProductInput{Name: "Duplicate", Price: 5, SKU: "SKU-1"}},
+		{Op: OpCreate, Product: &dto.CreateProductInput{Name: "", Price: 5}},
+		{Op: OpUpdate, ID: existing.ID.String(), Product: &dto.CreateProductInput{Name: "Renamed", Price: 20, SKU: "SKU-1"}},
+		{Op: OpUpdate, ID: "not-an-id", Product: &dto.CreateProductInput{Name: "X", Price: 1}},
+		{Op: OpDelete, ID: removed.ID.String()},
+		{Op: OpDelete, ID: removed.ID.String()},
+		{Op: "upsert"},
+	}})
+
+	assert.Equal(t, []int{201, 409, 400, 200, 400, 200, 404, 400}, statuses(output))
+	assert.Equal(t, 3, output.Succeeded)
+	assert.Equal(t, 5, output.Failed)
+	assert.Equal(t, dto.ErrorOutput{Message: entity.ErrNameIsRequired.Error()}, output.Results[2].Body)
+
+	created := output.Results[0].Body.(*entity.Product)
+	found, err := products.FindBySKU(context.Background(), "SKU-2")
+	assert.Nil(t, err)
+	assert.Equal(t, created.ID, found.ID)
+
+	found, _ = products.FindByID(context.Background(), existing.ID.String())
+	assert.Equal(t, "Renamed", found.Name)
+	// updates keep the fields the operation does not set
+	assert.Equal(t, existing.CreatedAt.Unix(), found.CreatedAt.Unix())
+}
+
+func TestRunAtomic(t *testing.T) {
+	products := newProductDB(t)
+	existing := createProduct(t, products, "Existing", "")
+
+	output := New(products, 0).Run(context.Background(), dto.BatchInput{Atomic: true, Operations: []dto.BatchOperation{
+		{Op: OpCreate, Product: &dto.CreateProductInput{Name: "New", Price: 5, SKU: "SKU-1"}},
+		// the SKU created by the first operation is seen within the batch
+		{Op: OpUpdate, ID: existing.ID.String(), Product: &dto.CreateProductInput{Name: "Existing", Price: 5, SKU: "SKU-2"}},
+		{Op: OpDelete, ID: existing.ID.String()},
+	}})
+	assert.Equal(t, []int{201, 200, 200}, statuses(output))
+	assert.Equal(t, 3, output.Succeeded)
+
+	_, err := products.FindBySKU(context.Background(), "SKU-1")
+	assert.Nil(t, err)
+	_, err = products.FindByID(context.Background(), existing.ID.String())
+	assert.NotNil(t, err)
+}
+
+func TestRunAtomicRollsBack(t *testing.T) {
+	products := newProductDB(t)
+	existing := createProduct(t, products, "Existing", "SKU-1")
+
+	output := New(products, 0).Run(context.Background(), dto.BatchInput{Atomic: true, Operations: []dto.BatchOperation{
+		{Op: OpCreate, Product: &dto.CreateProductInput{Name: "New", Price: 5, SKU: "SKU-2"}},
+		{Op: OpDelete, ID: existing.ID.String()},
+		{Op: OpCreate, Product: &dto.CreateProductInput{Name: "Duplicate", Price: 5, SKU: "SKU-2"}},
+		{Op: OpCreate, Product: &dto.CreateProductInput{Name: "Never run", Price: 5}},
+	}})
+	assert.Equal(t, []int{
+		http.StatusFailedDependency,
+		http.StatusFailedDependency,
+		http.StatusConflict,
+		http.StatusFailedDependency,
+	}, statuses(output))
+	assert.Equal(t, 0, output.Succeeded)
+	assert.Equal(t, 4, output.Failed)
+	assert.Equal(t, dto.ErrorOutput{Message: "not applied: operation at index 2 failed"}, output.Results[0].Body)
+
+	_, err := products.FindBySKU(context.Background(), "SKU-2")
+	assert.NotNil(t, err)
+	_, err = products.FindByID(context.Background(), existing.ID.String())
+	assert.Nil(t, err)
+}
@@ -0,0 +1,191 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+)
+
+// Event is a change sent to the subscribers. ID is the ID of its outbox
+// message.
+type Event struct {
+	ID          uint64
+	Type        string
+	AggregateID string
+	Data        json.RawMessage
+}
+
+func eventOf(msg *entity.OutboxMessage) Event {
+	return Event{
+		ID:          msg.ID,
+		Type:        msg.EventType,
+		AggregateID: msg.AggregateID,
+		Data:        msg.Payload,
+	}
+}
+
+// Subscription receives the events published after it was made. C is
+// closed when the subscription ends: when it is cancelled, when the broker
+// closes, or when the subscriber fell behind and was dropped.
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	dropped bool
+}
+
+// Dropped reports whether the subscription ended because its queue was
+// full.
+func (s *Subscription) Dropped() bool {
+	return s.dropped
+}
+
+// Broker fans out the events of an aggregate type, published by the outbox
+// relay, to the subscribers, and keeps the last ones so a subscriber that
+// reconnects resumes where it stopped. Publishing never blocks: a
+// subscriber whose queue is full is dropped, and is expected to reconnect
+// and resume from the buffer.
+type Broker struct {
+	Aggregate string
+
+	mu          sync.Mutex
+	buffer      []Event
+	next        int
+	ids         map[uint64]bool
+	queueSize   int
+	subscribers map[*Subscription]bool
+	closed      bool
+}
+
+// New returns a broker of aggregate keeping the last bufferSize events,
+// whose subscribers queue up to queueSize events.
+func New(aggregate string, bufferSize, queueSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	return &Broker{
+		Aggregate:   aggregate,
+		buffer:      make([]Event, 0, bufferSize),
+		ids:         make(map[uint64]bool, bufferSize),
+		queueSize:   queueSize,
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+// Load adds messages already published, oldest first, to the buffer, so
+// the events from before a restart can be resumed from.
+func (b *Broker) Load(messages []*entity.OutboxMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, msg := range messages {
+		if msg.AggregateType == b.Aggregate && !b.ids[msg.ID] {
+			b.add(eventOf(msg))
+		}
+	}
+}
+
+// Publish buffers the message and sends it to the subscribers. Messages of
+// other aggregates and copies of buffered ones, republished after a
+// failure, are ignored.
+func (b *Broker) Publish(_ context.Context, msg *entity.OutboxMessage) error {
+	if msg.AggregateType != b.Aggregate {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.ids[msg.ID] {
+		return nil
+	}
+	event := eventOf(msg)
+	b.add(event)
+	for s := range b.subscribers {
+		select {
+		case s.ch <- event:
+		default:
+			s.dropped = true
+			b.remove(s)
+		}
+	}
+	return nil
+}
+
+// add appends event to the buffer, replacing the oldest one when full.
+func (b *Broker) add(event Event) {
+	if len(b.buffer) < cap(b.buffer) {
+		b.buffer = append(b.buffer, event)
+	} else {
+		delete(b.ids, b.buffer[b.next].ID)
+		b.buffer[b.next] = event
+		b.next = (b.next + 1) % len(b.buffer)
+	}
+	b.ids[event.ID] = true
+}
+
+// Subscribe subscribes to the events published from now on. With a
+// lastID, the buffered events published after it are returned too, in
+// order; ok is false when lastID is no longer buffered, so the events
+// missed cannot be sent. The subscription is nil once the broker closed.
+func (b *Broker) Subscribe(lastID *uint64) (s *Subscription, missed []Event, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, false
+	}
+
+	ok = true
+	if lastID != nil {
+		ok = false
+		events := b.ordered()
+		for i, event := range events {
+			if event.ID == *lastID {
+				missed = append([]Event(nil), events[i+1:]...)
+				ok = true
+				break
+			}
+		}
+	}
+
+	ch := make(chan Event, b.queueSize)
+	s = &Subscription{C: ch, ch: ch}
+	b.subscribers[s] = true
+	return s, missed, ok
+}
+
+// ordered returns the buffered events, oldest first.
+func (b *Broker) ordered() []Event {
+	return append(append([]Event(nil), b.buffer[b.next:]...), b.buffer[:b.next]...)
+}
+
+// Unsubscribe ends s. It is a no-op when s already ended.
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(s)
+}
+
+func (b *Broker) remove(s *Subscription) {
+	if b.subscribers[s] {
+		delete(b.subscribers, s)
+		close(s.ch)
+	}
+}
+
+// Subscribers returns how many subscriptions are active.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Close ends every subscription and refuses new ones, so the streams
+// finish when the server shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s)
+	}
+}
@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func message(id uint64, aggregate string) *entity.OutboxMessage {
+	return &entity.OutboxMessage{
+		ID:            id,
+		EventType:     entity.EventProductUpdated,
+		AggregateType: aggregate,
+		AggregateID:   "1",
+		Payload:       []byte(`{}`),
+	}
+}
+
+func ids(events []Event) []uint64 {
+	var out []uint64
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestPublishToSubscribers(t *testing.T) {
+	b := New(entity.AggregateProduct, 10, 10)
+	s, missed, ok := b.Subscribe(nil)
+	assert.True(t, ok)
+	assert.Empty(t, missed)
+
+	ctx := context.Background()
+	b.Publish(ctx, message(1, entity.AggregateProduct))
+	b.Publish(ctx, message(2, entity.AggregateUser))
+	// a republished message is not sent twice
+	b.Publish(ctx, message(1, entity.AggregateProduct))
+	b.Publish(ctx, message(3, entity.AggregateProduct))
+
+	assert.Equal(t, uint64(1), (<-s.C).ID)
+	assert.Equal(t, uint64(3), (<-s.C).ID)
+	assert.Len(t, s.C, 0)
+
+	b.Unsubscribe(s)
+	_, open := <-s.C
+	assert.False(t, open)
+	assert.False(t, s.Dropped())
+	assert.Equal(t, 0, b.Subscribers())
+}
+
+func TestResumeFromBuffer(t *testing.T) {
+	b := New(entity.AggregateProduct, 3, 10)
+	b.Load([]*entity.OutboxMessage{message(1, entity.AggregateProduct), message(2, entity.AggregateProduct)})
+	ctx := context.Background()
+	for id := uint64(3); id <= 5; id++ {
+		b.Publish(ctx, message(id, entity.AggregateProduct))
+	}
+
+	last := uint64(3)
+	_, missed, ok := b.Subscribe(&last)
+	assert.True(t, ok)
+	assert.Equal(t, []uint64{4, 5}, ids(missed))
+
+	last = 5
+	_, missed, ok = b.Subscribe(&last)
+	assert.True(t, ok)
+	assert.Empty(t, missed)
+
+	// 2 no longer fits in the buffer
+	last = 2
+	_, missed, ok = b.Subscribe(&last)
+	assert.False(t, ok)
+	assert.Empty(t, missed)
+}
+
+func TestDropSlowSubscriber(t *testing.T) {
+	b := New(entity.AggregateProduct, 10, 2)
+	slow, _, _ := b.Subscribe(nil)
+	fast, _, _ := b.Subscribe(nil)
+	ctx := context.Background()
+
+	for id := uint64(1); id <= 3; id++ {
+		b.Publish(ctx, message(id, entity.AggregateProduct))
+		if id < 3 {
+			<-fast.C
+		}
+	}
+
+	assert.Equal(t, []uint64{1, 2}, ids([]Event{<-slow.C, <-slow.C}))
+	_, open := <-slow.C
+	assert.False(t, open)
+	assert.True(t, slow.Dropped())
+	assert.Equal(t, uint64(3), (<-fast.C).ID)
+	assert.Equal(t, 1, b.Subscribers())
+}
+
+func TestClose(t *testing.T) {
+	b := New(entity.AggregateProduct, 10, 10)
+	s, _, _ := b.Subscribe(nil)
+	b.Close()
+	_, open := <-s.C
+	assert.False(t, open)
+	s, _, _ = b.Subscribe(nil)
+	assert.Nil(t, s)
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type APIKey struct {
+	DB *gorm.DB
+}
+
+func NewAPIKey(db *gorm.DB) *APIKey {
+	return &APIKey{
+		DB: db,
+	}
+}
+
+func (k *APIKey) Create(ctx context.Context, key *entity.APIKey) error {
+	return k.DB.WithContext(ctx).Create(key).Error
+}
+
+func (k *APIKey) FindByPrefix(ctx context.Context, prefix string) (*entity.APIKey, error) {
+	var key entity.APIKey
+	if err := k.DB.WithContext(ctx).First(&key, "prefix = ?", prefix).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (k *APIKey) FindByUser(ctx context.Context, userID string) ([]*entity.APIKey, error) {
+	var keys []*entity.APIKey
+	err := k.DB.WithContext(ctx).Where("user_id = ?", userID).Order("created_at desc").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke revokes the key only if it belongs to the user.
+func (k *APIKey) Revoke(ctx context.Context, userID, id string) error {
+	var key entity.APIKey
+	if err := k.DB.WithContext(ctx).First(&key, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		return err
+	}
+	key.Revoke()
+	return k.DB.WithContext(ctx).Save(&key).Error
+}
+
+func (k *APIKey) TouchLastUsed(ctx context.Context, key *entity.APIKey, at time.Time) error {
+	key.LastUsedAt = &at
+	return k.DB.WithContext(ctx).Model(&entity.APIKey{}).Where("id = ?", key.ID).Update("last_used_at", at).Error
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func createAPIKeyDatabase(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.APIKey{})
+	return db
+}
+
+func TestCreateAndFindAPIKeyByPrefix(t *testing.T) {
+	db := createAPIKeyDatabase(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	key, plain, _ := entity.NewAPIKey(user.ID, "integration", []string{entity.ScopeProductsRead}, nil)
+
+	keyDB := NewAPIKey(db)
+	assert.Nil(t, keyDB.Create(context.Background(), key))
+
+	prefix, secret, _ := entity.ParseAPIKey(plain)
+	keyFound, err := keyDB.FindByPrefix(context.Background(), prefix)
+	assert.Nil(t, err)
+	assert.Equal(t, key.ID, keyFound.ID)
+	assert.Nil(t, keyFound.Validate(secret, time.Now()))
+}
+
+func TestFindAPIKeysByUserAndRevoke(t *testing.T) {
+	db := createAPIKeyDatabase(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	other, _ := entity.NewUser("Jane Doe", "jane@example.com", "password")
+
+	keyDB := NewAPIKey(db)
+	key, _, _ := entity.NewAPIKey(user.ID, "first", []string{entity.ScopeProductsRead}, nil)
+	assert.Nil(t, keyDB.Create(context.Background(), key))
+	otherKey, _, _ := entity.NewAPIKey(other.ID, "other", []string{entity.ScopeProductsRead}, nil)
+	assert.Nil(t, keyDB.Create(context.Background(), otherKey))
+
+	keys, err := keyDB.FindByUser(context.Background(), user.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, key.ID, keys[0].ID)
+
+	assert.Error(t, keyDB.Revoke(context.Background(), user.ID.String(), otherKey.ID.String()))
+	assert.Nil(t, keyDB.Revoke(context.Background(), user.ID.String(), key.ID.String()))
+
+	keyFound, _ := keyDB.FindByPrefix(context.Background(), key.Prefix)
+	assert.NotNil(t, keyFound.RevokedAt)
+}
+
+func TestTouchAPIKeyLastUsed(t *testing.T) {
+	db := createAPIKeyDatabase(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	key, _, _ := entity.NewAPIKey(user.ID, "integration", []string{entity.ScopeProductsRead}, nil)
+
+	keyDB := NewAPIKey(db)
+	assert.Nil(t, keyDB.Create(context.Background(), key))
+	assert.Nil(t, keyDB.TouchLastUsed(context.Background(), key, time.Now()))
+
+	keyFound, _ := keyDB.FindByPrefix(context.Background(), key.Prefix)
+	assert.NotNil(t, keyFound.LastUsedAt)
+}
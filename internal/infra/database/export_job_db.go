@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type ExportJob struct {
+	DB *gorm.DB
+}
+
+func NewExportJob(db *gorm.DB) *ExportJob {
+	return &ExportJob{
+		DB: db,
+	}
+}
+
+func (j *ExportJob) Create(ctx context.Context, job *entity.ExportJob) error {
+	return j.DB.WithContext(ctx).Create(job).Error
+}
+
+func (j *ExportJob) FindByID(ctx context.Context, id string) (*entity.ExportJob, error) {
+	var job entity.ExportJob
+	if err := j.DB.WithContext(ctx).First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (j *ExportJob) Update(ctx context.Context, job *entity.ExportJob) error {
+	return j.DB.WithContext(ctx).Save(job).Error
+}
+
+// FailUnfinished marks the jobs left pending or running, e.g. by a process
+// that stopped in the middle of an export, as failed with reason.
+func (j *ExportJob) FailUnfinished(ctx context.Context, reason string) error {
+	return j.DB.WithContext(ctx).Model(&entity.ExportJob{}).
+		Where("status IN ?", []string{entity.ExportStatusPending, entity.ExportStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":      entity.ExportStatusFailed,
+			"error":       reason,
+			"finished_at": time.Now(),
+		}).Error
+}
+
+// FindExpired returns the jobs whose files are no longer kept at now.
+func (j *ExportJob) FindExpired(ctx context.Context, now time.Time) ([]*entity.ExportJob, error) {
+	var jobs []*entity.ExportJob
+	err := j.DB.WithContext(ctx).Where("expires_at <= ?", now).Find(&jobs).Error
+	return jobs, err
+}
+
+func (j *ExportJob) Delete(ctx context.Context, id string) error {
+	return j.DB.WithContext(ctx).Delete(&entity.ExportJob{}, "id = ?", id).Error
+}
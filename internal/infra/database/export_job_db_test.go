@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCreateAndUpdateExportJob(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.ExportJob{})
+	jobDB := NewExportJob(db)
+	ctx := context.Background()
+
+	job, _ := entity.NewExportJob("user-1", entity.ExportFormatCSV, "name,price", "desc", time.Hour)
+	assert.Nil(t, jobDB.Create(ctx, job))
+
+	job.Start()
+	job.Rows = 42
+	job.Finish(nil)
+	assert.Nil(t, jobDB.Update(ctx, job))
+
+	found, err := jobDB.FindByID(ctx, job.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, entity.ExportStatusCompleted, found.Status)
+	assert.Equal(t, "user-1", found.OwnerID)
+	assert.Equal(t, "name,price", found.Columns)
+	assert.Equal(t, 42, found.Rows)
+}
+
+func TestFailUnfinishedExportJobs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.ExportJob{})
+	jobDB := NewExportJob(db)
+	ctx := context.Background()
+
+	running, _ := entity.NewExportJob("user-1", entity.ExportFormatXLSX, "", "", time.Hour)
+	running.Start()
+	jobDB.Create(ctx, running)
+	done, _ := entity.NewExportJob("user-1", entity.ExportFormatXLSX, "", "", time.Hour)
+	done.Finish(nil)
+	jobDB.Create(ctx, done)
+
+	assert.Nil(t, jobDB.FailUnfinished(ctx, "interrupted"))
+
+	found, _ := jobDB.FindByID(ctx, running.ID.String())
+	assert.Equal(t, entity.ExportStatusFailed, found.Status)
+	assert.Equal(t, "interrupted", found.Error)
+	found, _ = jobDB.FindByID(ctx, done.ID.String())
+	assert.Equal(t, entity.ExportStatusCompleted, found.Status)
+}
+
+func TestFindExpiredAndDeleteExportJobs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.ExportJob{})
+	jobDB := NewExportJob(db)
+	ctx := context.Background()
+
+	expired, _ := entity.NewExportJob("user-1", entity.ExportFormatCSV, "", "", -time.Minute)
+	jobDB.Create(ctx, expired)
+	kept, _ := entity.NewExportJob("user-1", entity.ExportFormatCSV, "", "", time.Hour)
+	jobDB.Create(ctx, kept)
+
+	jobs, err := jobDB.FindExpired(ctx, time.Now())
+	assert.Nil(t, err)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, expired.ID, jobs[0].ID)
+	}
+
+	assert.Nil(t, jobDB.Delete(ctx, expired.ID.String()))
+	_, err = jobDB.FindByID(ctx, expired.ID.String())
+	assert.NotNil(t, err)
+	_, err = jobDB.FindByID(ctx, kept.ID.String())
+	assert.Nil(t, err)
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyKey struct {
+	DB *gorm.DB
+}
+
+func NewIdempotencyKey(db *gorm.DB) *IdempotencyKey {
+	return &IdempotencyKey{
+		DB: db,
+	}
+}
+
+// Acquire stores key if no live key with the same ID exists and returns nil.
+// Otherwise the existing key is returned untouched. The insert is atomic, so
+// only one of several concurrent requests with the same key acquires it.
+func (k *IdempotencyKey) Acquire(ctx context.Context, key *entity.IdempotencyKey) (*entity.IdempotencyKey, error) {
+	db := k.DB.WithContext(ctx)
+
+	// expired keys, and keys whose request died before settling them, are
+	// released so the value can be used again
+	if err := db.Where("id = ? AND (expires_at < ? OR (completed = ? AND locked_until < ?))", key.ID, key.CreatedAt, false, key.CreatedAt).
+		Delete(&entity.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing entity.IdempotencyKey
+	if err := db.First(&existing, "id = ?", key.ID).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Save stores the response of a completed key. It returns
+// entity.ErrIdempotencyKeyLost when the lease ran out and another request
+// took the key over.
+func (k *IdempotencyKey) Save(ctx context.Context, key *entity.IdempotencyKey) error {
+	result := k.DB.WithContext(ctx).Model(key).Where("locked_until = ?", key.LockedUntil).
+		Select("Completed", "StatusCode", "ResponseHeader", "ResponseBody").Updates(key)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrIdempotencyKeyLost
+	}
+	return nil
+}
+
+// Release deletes the key so the request can be retried, unless another
+// request already took it over.
+func (k *IdempotencyKey) Release(ctx context.Context, key *entity.IdempotencyKey) error {
+	return k.DB.WithContext(ctx).Where("id = ? AND locked_until = ?", key.ID, key.LockedUntil).Delete(&entity.IdempotencyKey{}).Error
+}
+
+func (k *IdempotencyKey) DeleteExpired(ctx context.Context, now time.Time) error {
+	return k.DB.WithContext(ctx).Where("expires_at < ?", now).Delete(&entity.IdempotencyKey{}).Error
+}
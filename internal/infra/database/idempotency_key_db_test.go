@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAcquireIdempotencyKey(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.IdempotencyKey{})
+	keyDB := NewIdempotencyKey(db)
+	ctx := context.Background()
+
+	key, _ := entity.NewIdempotencyKey("user-1", "abc", "fingerprint", time.Hour, time.Minute)
+	existing, err := keyDB.Acquire(ctx, key)
+	assert.Nil(t, err)
+	assert.Nil(t, existing)
+
+	duplicate, _ := entity.NewIdempotencyKey("user-1", "abc", "fingerprint", time.Hour, time.Minute)
+	existing, err = keyDB.Acquire(ctx, duplicate)
+	assert.Nil(t, err)
+	assert.NotNil(t, existing)
+	assert.False(t, existing.Completed)
+
+	key.Complete(201, "{}", []byte(`{"id":"1"}`))
+	assert.Nil(t, keyDB.Save(ctx, key))
+	existing, _ = keyDB.Acquire(ctx, duplicate)
+	assert.True(t, existing.Completed)
+	assert.Equal(t, []byte(`{"id":"1"}`), existing.ResponseBody)
+
+	assert.Nil(t, keyDB.Release(ctx, key))
+	existing, err = keyDB.Acquire(ctx, duplicate)
+	assert.Nil(t, err)
+	assert.Nil(t, existing)
+}
+
+func TestAcquireExpiredIdempotencyKey(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.IdempotencyKey{})
+	keyDB := NewIdempotencyKey(db)
+	ctx := context.Background()
+
+	expired, _ := entity.NewIdempotencyKey("user-1", "abc", "old", -time.Minute, time.Minute)
+	keyDB.Acquire(ctx, expired)
+
+	key, _ := entity.NewIdempotencyKey("user-1", "abc", "new", time.Hour, time.Minute)
+	existing, err := keyDB.Acquire(ctx, key)
+	assert.Nil(t, err)
+	assert.Nil(t, existing)
+
+	other, _ := entity.NewIdempotencyKey("user-2", "abc", "old", -time.Minute, time.Minute)
+	keyDB.Acquire(ctx, other)
+	assert.Nil(t, keyDB.DeleteExpired(ctx, time.Now()))
+
+	var count int64
+	db.Model(&entity.IdempotencyKey{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestTakeOverIdempotencyKeyAfterLease(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.IdempotencyKey{})
+	keyDB := NewIdempotencyKey(db)
+	ctx := context.Background()
+
+	// the request holding the key died before its lease ran out
+	stale, _ := entity.NewIdempotencyKey("user-1", "abc", "fingerprint", time.Hour, -time.Second)
+	keyDB.Acquire(ctx, stale)
+
+	key, _ := entity.NewIdempotencyKey("user-1", "abc", "fingerprint", time.Hour, time.Minute)
+	existing, err := keyDB.Acquire(ctx, key)
+	assert.Nil(t, err)
+	assert.Nil(t, existing)
+
+	// the stale request can no longer settle the key
+	stale.Complete(201, "{}", []byte(`{"id":"1"}`))
+	assert.Equal(t, entity.ErrIdempotencyKeyLost, keyDB.Save(ctx, stale))
+	assert.Nil(t, keyDB.Release(ctx, stale))
+	existing, _ = keyDB.Acquire(ctx, key)
+	if assert.NotNil(t, existing) {
+		assert.False(t, existing.Completed)
+	}
+
+	key.Complete(201, "{}", []byte(`{"id":"2"}`))
+	assert.Nil(t, keyDB.Save(ctx, key))
+	existing, _ = keyDB.Acquire(ctx, key)
+	assert.Equal(t, []byte(`{"id":"2"}`), existing.ResponseBody)
+}
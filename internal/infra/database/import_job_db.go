@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type ImportJob struct {
+	DB *gorm.DB
+}
+
+func NewImportJob(db *gorm.DB) *ImportJob {
+	return &ImportJob{
+		DB: db,
+	}
+}
+
+func (j *ImportJob) Create(ctx context.Context, job *entity.ImportJob) error {
+	return j.DB.WithContext(ctx).Create(job).Error
+}
+
+func (j *ImportJob) FindByID(ctx context.Context, id string) (*entity.ImportJob, error) {
+	var job entity.ImportJob
+	if err := j.DB.WithContext(ctx).First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (j *ImportJob) Update(ctx context.Context, job *entity.ImportJob) error {
+	return j.DB.WithContext(ctx).Save(job).Error
+}
+
+// FailUnfinished marks the jobs left pending or running, e.g. by a process
+// that stopped in the middle of an import, as failed with reason.
+func (j *ImportJob) FailUnfinished(ctx context.Context, reason string) error {
+	return j.DB.WithContext(ctx).Model(&entity.ImportJob{}).
+		Where("status IN ?", []string{entity.ImportStatusPending, entity.ImportStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":      entity.ImportStatusFailed,
+			"error":       reason,
+			"finished_at": time.Now(),
+		}).Error
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCreateAndUpdateImportJob(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.ImportJob{})
+	jobDB := NewImportJob(db)
+	ctx := context.Background()
+
+	job, _ := entity.NewImportJob("user-1", entity.ImportFormatCSV, false, true)
+	assert.Nil(t, jobDB.Create(ctx, job))
+
+	job.Start(3)
+	job.Report.Processed = 3
+	job.Report.Created = 2
+	job.Report.AddError(3, "SKU-3", entity.ErrInvalidPrice)
+	job.Finish(nil)
+	assert.Nil(t, jobDB.Update(ctx, job))
+
+	found, err := jobDB.FindByID(ctx, job.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, entity.ImportStatusCompleted, found.Status)
+	assert.Equal(t, "user-1", found.OwnerID)
+	assert.Equal(t, job.Report, found.Report)
+}
+
+func TestFailUnfinishedImportJobs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.ImportJob{})
+	jobDB := NewImportJob(db)
+	ctx := context.Background()
+
+	running, _ := entity.NewImportJob("user-1", entity.ImportFormatCSV, false, false)
+	running.Start(10)
+	jobDB.Create(ctx, running)
+	done, _ := entity.NewImportJob("user-1", entity.ImportFormatCSV, false, false)
+	done.Finish(nil)
+	jobDB.Create(ctx, done)
+
+	assert.Nil(t, jobDB.FailUnfinished(ctx, "interrupted"))
+
+	found, _ := jobDB.FindByID(ctx, running.ID.String())
+	assert.Equal(t, entity.ImportStatusFailed, found.Status)
+	assert.Equal(t, "interrupted", found.Error)
+	assert.NotNil(t, found.FinishedAt)
+	found, _ = jobDB.FindByID(ctx, done.ID.String())
+	assert.Equal(t, entity.ImportStatusCompleted, found.Status)
+}
@@ -1,16 +1,111 @@
 package database
 
-import "github.com/leobelini-studies/go_expert_api/internal/entity"
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+)
 
 type UserInterface interface {
-	Create(user *entity.User) error
-	FindByEmail(email string) (*entity.User, error)
+	Create(ctx context.Context, user *entity.User) error
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	FindByID(ctx context.Context, id string) (*entity.User, error)
+	Update(ctx context.Context, user *entity.User) error
+	UpdateMFALastStep(ctx context.Context, user *entity.User, previous int64) error
+}
+
+type UserTokenInterface interface {
+	Create(ctx context.Context, token *entity.UserToken) error
+	FindByHash(ctx context.Context, tokenType, hash string) (*entity.UserToken, error)
+	MarkUsed(ctx context.Context, token *entity.UserToken) error
+	DeleteByUser(ctx context.Context, userID, tokenType string) error
+}
+
+type RecoveryCodeInterface interface {
+	Replace(ctx context.Context, userID string, codes []*entity.RecoveryCode) error
+	Consume(ctx context.Context, userID, hash string) error
+	DeleteByUser(ctx context.Context, userID string) error
+}
+
+type APIKeyInterface interface {
+	Create(ctx context.Context, key *entity.APIKey) error
+	FindByPrefix(ctx context.Context, prefix string) (*entity.APIKey, error)
+	FindByUser(ctx context.Context, userID string) ([]*entity.APIKey, error)
+	Revoke(ctx context.Context, userID, id string) error
+	TouchLastUsed(ctx context.Context, key *entity.APIKey, at time.Time) error
+}
+
+type OAuthClientInterface interface {
+	Create(ctx context.Context, client *entity.OAuthClient) error
+	FindByID(ctx context.Context, id string) (*entity.OAuthClient, error)
+}
+
+type RevokedTokenInterface interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type IdempotencyKeyInterface interface {
+	Acquire(ctx context.Context, key *entity.IdempotencyKey) (*entity.IdempotencyKey, error)
+	Save(ctx context.Context, key *entity.IdempotencyKey) error
+	Release(ctx context.Context, key *entity.IdempotencyKey) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type ImportJobInterface interface {
+	Create(ctx context.Context, job *entity.ImportJob) error
+	FindByID(ctx context.Context, id string) (*entity.ImportJob, error)
+	Update(ctx context.Context, job *entity.ImportJob) error
+	FailUnfinished(ctx context.Context, reason string) error
+}
+
+type ExportJobInterface interface {
+	Create(ctx context.Context, job *entity.ExportJob) error
+	FindByID(ctx context.Context, id string) (*entity.ExportJob, error)
+	Update(ctx context.Context, job *entity.ExportJob) error
+	FailUnfinished(ctx context.Context, reason string) error
+	FindExpired(ctx context.Context, now time.Time) ([]*entity.ExportJob, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type WebhookInterface interface {
+	Create(ctx context.Context, webhook *entity.Webhook) error
+	FindByID(ctx context.Context, id string) (*entity.Webhook, error)
+	FindByUser(ctx context.Context, userID string) ([]*entity.Webhook, error)
+	FindByEvent(ctx context.Context, eventType string) ([]*entity.Webhook, error)
+	Delete(ctx context.Context, userID, id string) error
+}
+
+type WebhookDeliveryInterface interface {
+	Create(ctx context.Context, deliveries []*entity.WebhookDelivery) error
+	FindByID(ctx context.Context, webhookID, id string) (*entity.WebhookDelivery, error)
+	FindByWebhook(ctx context.Context, webhookID, status string, limit int) ([]*entity.WebhookDelivery, error)
+	FindDue(ctx context.Context, now time.Time, limit int) ([]*entity.WebhookDelivery, error)
+	Update(ctx context.Context, delivery *entity.WebhookDelivery) error
+	DeleteSucceededBefore(ctx context.Context, t time.Time) error
+}
+
+type OutboxInterface interface {
+	FindPending(ctx context.Context, afterID uint64, limit int) ([]*entity.OutboxMessage, error)
+	MarkPublished(ctx context.Context, id uint64, at time.Time) error
+	MarkFailed(ctx context.Context, id uint64, reason string) error
+	MarkDead(ctx context.Context, id uint64, reason string, at time.Time) error
+	DeletePublishedBefore(ctx context.Context, t time.Time) error
+	Appended() <-chan struct{}
 }
 
 type ProductInterface interface {
-	Create(product *entity.Product) error
-	FindAll(page, limit int, sort string) ([]*entity.Product, error)
-	FindByID(id string) (*entity.Product, error)
-	Update(product *entity.Product) error
-	Delete(id string) error
+	Create(ctx context.Context, product *entity.Product) error
+	FindAll(ctx context.Context, page, limit int, sort string) ([]*entity.Product, error)
+	Search(ctx context.Context, filter ProductFilter, page, limit int, sort string) ([]*entity.Product, int64, error)
+	FindByIDs(ctx context.Context, ids []string) ([]*entity.Product, error)
+	Stream(ctx context.Context, sort string, fn func(*entity.Product) error) error
+	ImportBatch(ctx context.Context, products []*entity.Product, upsert, dryRun bool) (ImportBatchResult, error)
+	FindByID(ctx context.Context, id string) (*entity.Product, error)
+	FindBySKU(ctx context.Context, sku string) (*entity.Product, error)
+	Update(ctx context.Context, product *entity.Product) error
+	Delete(ctx context.Context, id string) error
+	Transaction(ctx context.Context, fn func(products ProductInterface) error) error
 }
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type OAuthClient struct {
+	DB *gorm.DB
+}
+
+func NewOAuthClient(db *gorm.DB) *OAuthClient {
+	return &OAuthClient{
+		DB: db,
+	}
+}
+
+func (c *OAuthClient) Create(ctx context.Context, client *entity.OAuthClient) error {
+	return c.DB.WithContext(ctx).Create(client).Error
+}
+
+func (c *OAuthClient) FindByID(ctx context.Context, id string) (*entity.OAuthClient, error) {
+	var client entity.OAuthClient
+	if err := c.DB.WithContext(ctx).First(&client, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCreateAndFindOAuthClient(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.OAuthClient{})
+
+	client, secret, _ := entity.NewOAuthClient("billing", []string{entity.GrantTypeClientCredentials}, []string{entity.ScopeProductsRead})
+	clientDB := NewOAuthClient(db)
+	assert.Nil(t, clientDB.Create(context.Background(), client))
+
+	clientFound, err := clientDB.FindByID(context.Background(), client.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, client.Name, clientFound.Name)
+	assert.Nil(t, clientFound.ValidateSecret(secret))
+}
+
+func TestRevokeToken(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.RevokedToken{})
+
+	tokenDB := NewRevokedToken(db)
+	revoked, err := tokenDB.IsRevoked(context.Background(), "jti-1")
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	assert.Nil(t, tokenDB.Revoke(context.Background(), "jti-1", time.Now().Add(time.Hour)))
+	assert.Nil(t, tokenDB.Revoke(context.Background(), "jti-1", time.Now().Add(time.Hour)))
+	assert.Nil(t, tokenDB.Revoke(context.Background(), "jti-2", time.Now().Add(-time.Hour)))
+
+	revoked, _ = tokenDB.IsRevoked(context.Background(), "jti-1")
+	assert.True(t, revoked)
+
+	assert.Nil(t, tokenDB.DeleteExpired(context.Background(), time.Now()))
+	revoked, _ = tokenDB.IsRevoked(context.Background(), "jti-1")
+	assert.True(t, revoked)
+	revoked, _ = tokenDB.IsRevoked(context.Background(), "jti-2")
+	assert.False(t, revoked)
+}
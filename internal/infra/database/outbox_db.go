@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+)
+
+// Outbox stores the events of the changes made by the other stores in the
+// same transaction as the changes, so an event is never lost once its
+// change is committed, nor published for a change rolled back.
+type Outbox struct {
+	DB *gorm.DB
+
+	appended chan struct{}
+}
+
+func NewOutbox(db *gorm.DB) *Outbox {
+	return &Outbox{
+		DB:       db,
+		appended: make(chan struct{}, 1),
+	}
+}
+
+// append stores an event of aggregate with tx, the transaction of the
+// change. A nil outbox stores nothing.
+func (o *Outbox) append(tx *gorm.DB, eventType, aggregateType, aggregateID string, data interface{}) error {
+	if o == nil {
+		return nil
+	}
+	msg, err := entity.NewOutboxMessage(entity.NewEvent(eventType, data), aggregateType, aggregateID)
+	if err != nil {
+		return err
+	}
+	return tx.Create(msg).Error
+}
+
+// committed signals Appended once a transaction that appended events was
+// committed.
+func (o *Outbox) committed() {
+	if o == nil {
+		return
+	}
+	select {
+	case o.appended <- struct{}{}:
+	default:
+	}
+}
+
+// Appended receives a value after events were committed, so they can be
+// published without waiting for the next poll.
+func (o *Outbox) Appended() <-chan struct{} {
+	return o.appended
+}
+
+// FindPending returns the messages after afterID that are neither published
+// nor dead, oldest first.
+func (o *Outbox) FindPending(ctx context.Context, afterID uint64, limit int) ([]*entity.OutboxMessage, error) {
+	var messages []*entity.OutboxMessage
+	err := o.DB.WithContext(ctx).Where("published_at IS NULL AND dead_at IS NULL AND id > ?", afterID).
+		Order("id").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// FindLatestPublished returns the last limit messages of aggregateType that
+// were published, oldest first.
+func (o *Outbox) FindLatestPublished(ctx context.Context, aggregateType string, limit int) ([]*entity.OutboxMessage, error) {
+	var messages []*entity.OutboxMessage
+	err := o.DB.WithContext(ctx).
+		Where("published_at IS NOT NULL AND aggregate_type = ?", aggregateType).
+		Order("id desc").Limit(limit).Find(&messages).Error
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, err
+}
+
+func (o *Outbox) MarkPublished(ctx context.Context, id uint64, at time.Time) error {
+	return o.DB.WithContext(ctx).Model(&entity.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"published_at": at,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"last_error":   "",
+		}).Error
+}
+
+func (o *Outbox) MarkFailed(ctx context.Context, id uint64, reason string) error {
+	return o.DB.WithContext(ctx).Model(&entity.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": reason,
+		}).Error
+}
+
+// MarkDead gives up on a message that failed for the last time.
+func (o *Outbox) MarkDead(ctx context.Context, id uint64, reason string, at time.Time) error {
+	return o.DB.WithContext(ctx).Model(&entity.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"dead_at":    at,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": reason,
+		}).Error
+}
+
+// DeletePublishedBefore deletes the messages published before t.
+func (o *Outbox) DeletePublishedBefore(ctx context.Context, t time.Time) error {
+	return o.DB.WithContext(ctx).Delete(&entity.OutboxMessage{}, "published_at < ?", t).Error
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func newOutboxDB(t *testing.T) *gorm.DB {
+	return testutil.NewSQLite(t, &entity.Product{}, &entity.User{}, &entity.OutboxMessage{})
+}
+
+func TestProductChangesAppendEvents(t *testing.T) {
+	db := newOutboxDB(t)
+	outbox := NewOutbox(db)
+	productDB := NewProduct(db)
+	productDB.Outbox = outbox
+	ctx := context.Background()
+
+	product, _ := entity.NewProduct("Product", 10)
+	assert.Nil(t, productDB.Create(ctx, product))
+	product.Price = 20
+	assert.Nil(t, productDB.Update(ctx, product))
+	assert.Nil(t, productDB.Delete(ctx, product.ID.String()))
+
+	select {
+	case <-outbox.Appended():
+	default:
+		t.Error("expected the outbox to be signalled")
+	}
+
+	messages, err := outbox.FindPending(ctx, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 4)
+	for i, eventType := range []string{entity.EventProductCreated, entity.EventProductUpdated, entity.EventProductPriceChanged, entity.EventProductDeleted} {
+		assert.Equal(t, eventType, messages[i].EventType)
+		assert.Equal(t, entity.AggregateProduct, messages[i].AggregateType)
+		assert.Equal(t, product.ID.String(), messages[i].AggregateID)
+	}
+	assert.Less(t, messages[0].ID, messages[1].ID)
+	assert.Less(t, messages[1].ID, messages[2].ID)
+	assert.Less(t, messages[2].ID, messages[3].ID)
+}
+
+func TestProductUpdateAppendsPriceChange(t *testing.T) {
+	db := newOutboxDB(t)
+	outbox := NewOutbox(db)
+	productDB := NewProduct(db)
+	productDB.Outbox = outbox
+	ctx := context.Background()
+
+	product, _ := entity.NewProduct("Product", 10)
+	product.Category = "drinks"
+	assert.Nil(t, productDB.Create(ctx, product))
+	product.Name = "Renamed"
+	assert.Nil(t, productDB.Update(ctx, product))
+	product.Price = 12.5
+	assert.Nil(t, productDB.Update(ctx, product))
+
+	messages, err := outbox.FindPending(ctx, 0, 10)
+	assert.Nil(t, err)
+	if !assert.Len(t, messages, 4) {
+		return
+	}
+	assert.Equal(t, entity.EventProductUpdated, messages[1].EventType)
+	assert.Equal(t, entity.EventProductUpdated, messages[2].EventType)
+	assert.Equal(t, entity.EventProductPriceChanged, messages[3].EventType)
+	var event struct {
+		Data entity.PriceChangedOutput `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(messages[3].Payload, &event))
+	assert.Equal(t, entity.PriceChangedOutput{ID: product.ID.String(), Name: "Renamed", Category: "drinks", OldPrice: 10, Price: 12.5}, event.Data)
+}
+
+func TestRolledBackChangesAppendNoEvents(t *testing.T) {
+	db := newOutboxDB(t)
+	outbox := NewOutbox(db)
+	productDB := NewProduct(db)
+	productDB.Outbox = outbox
+	ctx := context.Background()
+
+	err := productDB.Transaction(ctx, func(products ProductInterface) error {
+		product, _ := entity.NewProduct("Product", 10)
+		if err := products.Create(ctx, product); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+
+	product, _ := entity.NewProduct("Imported", 10)
+	_, err = productDB.ImportBatch(ctx, []*entity.Product{product}, false, true)
+	assert.Nil(t, err)
+
+	messages, _ := outbox.FindPending(ctx, 0, 10)
+	assert.Empty(t, messages)
+
+	_, err = productDB.ImportBatch(ctx, []*entity.Product{product}, false, false)
+	assert.Nil(t, err)
+	messages, _ = outbox.FindPending(ctx, 0, 10)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, entity.EventProductCreated, messages[0].EventType)
+}
+
+func TestUserCreateAppendsEvent(t *testing.T) {
+	db := newOutboxDB(t)
+	outbox := NewOutbox(db)
+	userDB := NewUser(db)
+	userDB.Outbox = outbox
+	ctx := context.Background()
+
+	user, _ := entity.NewUser("John", "j@j.com", "123456")
+	assert.Nil(t, userDB.Create(ctx, user))
+
+	messages, _ := outbox.FindPending(ctx, 0, 10)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, entity.EventUserCreated, messages[0].EventType)
+	assert.NotContains(t, string(messages[0].Payload), user.Password)
+}
+
+func TestPublishOutboxMessages(t *testing.T) {
+	db := newOutboxDB(t)
+	outbox := NewOutbox(db)
+	productDB := NewProduct(db)
+	productDB.Outbox = outbox
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		product, _ := entity.NewProduct("Product", 10)
+		productDB.Create(ctx, product)
+	}
+	messages, _ := outbox.FindPending(ctx, 0, 10)
+
+	assert.Nil(t, outbox.MarkFailed(ctx, messages[0].ID, "broker down"))
+	assert.Nil(t, outbox.MarkPublished(ctx, messages[1].ID, time.Now()))
+
+	pending, _ := outbox.FindPending(ctx, 0, 10)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, messages[0].ID, pending[0].ID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "broker down", pending[0].LastError)
+	pending, _ = outbox.FindPending(ctx, messages[0].ID, 10)
+	assert.Empty(t, pending)
+
+	latest, err := outbox.FindLatestPublished(ctx, entity.AggregateProduct, 10)
+	assert.Nil(t, err)
+	assert.Len(t, latest, 1)
+	assert.Equal(t, messages[1].ID, latest[0].ID)
+
+	assert.Nil(t, outbox.DeletePublishedBefore(ctx, time.Now().Add(time.Minute)))
+	var count int64
+	db.Model(&entity.OutboxMessage{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// dead messages are kept but no longer pending
+	assert.Nil(t, outbox.MarkDead(ctx, messages[0].ID, "bad payload", time.Now()))
+	pending, _ = outbox.FindPending(ctx, 0, 10)
+	assert.Empty(t, pending)
+	db.Model(&entity.OutboxMessage{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
@@ -1,12 +1,22 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"strings"
+
 	"github.com/leobelini-studies/go_expert_api/internal/entity"
 	"gorm.io/gorm"
 )
 
 type Product struct {
 	DB *gorm.DB
+	// Outbox, when set, stores an event for every product created, updated
+	// or deleted.
+	Outbox *Outbox
+	// inTx is set on the stores of Transaction, whose writes are committed
+	// by it.
+	inTx bool
 }
 
 func NewProduct(db *gorm.DB) *Product {
@@ -15,35 +25,99 @@ func NewProduct(db *gorm.DB) *Product {
 	}
 }
 
-func (p *Product) Create(product *entity.Product) error {
-	return p.DB.Create(product).Error
+func (p *Product) Create(ctx context.Context, product *entity.Product) error {
+	return p.write(ctx, func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		return p.Outbox.append(tx, entity.EventProductCreated, entity.AggregateProduct, product.ID.String(), product)
+	})
 }
 
-func (p *Product) FindByID(id string) (*entity.Product, error) {
+func (p *Product) FindByID(ctx context.Context, id string) (*entity.Product, error) {
 	var product entity.Product
-	if err := p.DB.First(&product, "id = ?", id).Error; err != nil {
+	if err := p.DB.WithContext(ctx).First(&product, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
-func (p *Product) Update(product *entity.Product) error {
-	_, err := p.FindByID(product.ID.String())
-	if err != nil {
+func (p *Product) FindBySKU(ctx context.Context, sku string) (*entity.Product, error) {
+	var product entity.Product
+	if err := p.DB.WithContext(ctx).First(&product, "sku = ?", sku).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// Update saves product. A change of price also stores a
+// product.price_changed event, after the product.updated one. The old price
+// is read in the same transaction as the write, so concurrent updates
+// report the price each one replaced.
+func (p *Product) Update(ctx context.Context, product *entity.Product) error {
+	return p.write(ctx, func(tx *gorm.DB) error {
+		var current entity.Product
+		if err := tx.First(&current, "id = ?", product.ID.String()).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(product).Error; err != nil {
+			return err
+		}
+		return p.appendUpdated(tx, product, current.Price)
+	})
+}
+
+func (p *Product) appendUpdated(tx *gorm.DB, product *entity.Product, oldPrice float64) error {
+	id := product.ID.String()
+	if err := p.Outbox.append(tx, entity.EventProductUpdated, entity.AggregateProduct, id, product); err != nil {
 		return err
 	}
-	return p.DB.Save(product).Error
+	if product.Price == oldPrice {
+		return nil
+	}
+	return p.Outbox.append(tx, entity.EventProductPriceChanged, entity.AggregateProduct, id,
+		entity.NewPriceChangedOutput(product, oldPrice))
 }
 
-func (p *Product) Delete(id string) error {
-	product, err := p.FindByID(id)
+func (p *Product) Delete(ctx context.Context, id string) error {
+	product, err := p.FindByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	return p.DB.Delete(product).Error
+	return p.write(ctx, func(tx *gorm.DB) error {
+		if err := tx.Delete(product).Error; err != nil {
+			return err
+		}
+		return p.Outbox.append(tx, entity.EventProductDeleted, entity.AggregateProduct, id, entity.DeletedOutput{ID: id})
+	})
+}
+
+// Transaction runs fn with products whose calls share one transaction,
+// committed when fn returns nil and rolled back otherwise.
+func (p *Product) Transaction(ctx context.Context, fn func(products ProductInterface) error) error {
+	err := p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Product{DB: tx, Outbox: p.Outbox, inTx: true})
+	})
+	if err == nil {
+		p.Outbox.committed()
+	}
+	return err
+}
+
+// write runs fn in a transaction when the change has events to store with
+// it, and signals the outbox once it was committed.
+func (p *Product) write(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	if p.Outbox == nil || p.inTx {
+		return fn(p.DB.WithContext(ctx))
+	}
+	if err := p.DB.WithContext(ctx).Transaction(fn); err != nil {
+		return err
+	}
+	p.Outbox.committed()
+	return nil
 }
 
-func (p *Product) FindAll(page, limit int, sort string) ([]*entity.Product, error) {
+func (p *Product) FindAll(ctx context.Context, page, limit int, sort string) ([]*entity.Product, error) {
 
 	if sort != "asc" && sort != "desc" && sort != "" {
 		sort = "asc"
@@ -54,10 +128,177 @@ func (p *Product) FindAll(page, limit int, sort string) ([]*entity.Product, erro
 
 	if page != 0 && limit != 0 {
 		offset := (page - 1) * limit
-		err = p.DB.Limit(limit).Offset(offset).Order("created_at " + sort).Find(&products).Error
+		err = p.DB.WithContext(ctx).Limit(limit).Offset(offset).Order("created_at " + sort).Find(&products).Error
 	} else {
-		err = p.DB.Order("created_at " + sort).Find(&products).Error
+		err = p.DB.WithContext(ctx).Order("created_at " + sort).Find(&products).Error
 	}
 
 	return products, err
 }
+
+// ProductFilter narrows Search. Zero fields do not filter.
+type ProductFilter struct {
+	// Name matches the products whose name contains it, regardless of
+	// case.
+	Name     string
+	Category string
+	MinPrice *float64
+	MaxPrice *float64
+}
+
+// Search returns a page of the products matching filter, ordered by
+// creation date, and how many match in total.
+func (p *Product) Search(ctx context.Context, filter ProductFilter, page, limit int, sort string) ([]*entity.Product, int64, error) {
+	if sort != "asc" && sort != "desc" {
+		sort = "asc"
+	}
+
+	query := p.DB.WithContext(ctx).Model(&entity.Product{})
+	if filter.Name != "" {
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(filter.Name))+"%")
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.MinPrice != nil {
+		query = query.Where("price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("price <= ?", *filter.MaxPrice)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var products []*entity.Product
+	err := query.Limit(limit).Offset((page - 1) * limit).Order("created_at " + sort).Find(&products).Error
+	return products, total, err
+}
+
+// escapeLike makes the wildcards of s match themselves in a LIKE pattern
+// escaped with '!'. A backslash would need escaping itself on MySQL.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// FindByIDs returns the products with the given IDs, in no particular
+// order. IDs without a product are left out.
+func (p *Product) FindByIDs(ctx context.Context, ids []string) ([]*entity.Product, error) {
+	var products []*entity.Product
+	if len(ids) == 0 {
+		return products, nil
+	}
+	err := p.DB.WithContext(ctx).Where("id IN ?", ids).Find(&products).Error
+	return products, err
+}
+
+// Stream calls fn for every product, ordered by creation date, reading one
+// row at a time so the whole table is never held in memory. It stops at the
+// first error returned by fn.
+func (p *Product) Stream(ctx context.Context, sort string, fn func(*entity.Product) error) error {
+	if sort != "asc" && sort != "desc" {
+		sort = "asc"
+	}
+
+	db := p.DB.WithContext(ctx)
+	rows, err := db.Model(&entity.Product{}).Order("created_at " + sort).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product entity.Product
+		if err := db.ScanRows(rows, &product); err != nil {
+			return err
+		}
+		if err := fn(&product); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ImportBatchResult tells what ImportBatch did with a batch.
+type ImportBatchResult struct {
+	Created int
+	Updated int
+	// Conflicts holds the indexes of the products whose SKU already exists.
+	// They are skipped unless upserting.
+	Conflicts []int
+}
+
+// errDryRun rolls back the transaction of a dry-run batch.
+var errDryRun = errors.New("dry run")
+
+// ImportBatch stores products in a single transaction. Products whose SKU
+// already exists update the stored product when upsert is set, keeping its
+// ID and creation date. With dryRun the transaction is rolled back, so the
+// result tells what would happen without changing anything.
+func (p *Product) ImportBatch(ctx context.Context, products []*entity.Product, upsert, dryRun bool) (ImportBatchResult, error) {
+	var result ImportBatchResult
+	err := p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var skus []string
+		for _, product := range products {
+			if product.SKU != "" {
+				skus = append(skus, product.SKU)
+			}
+		}
+		existing := make(map[string]*entity.Product)
+		if len(skus) > 0 {
+			var found []*entity.Product
+			if err := tx.Where("sku IN ?", skus).Find(&found).Error; err != nil {
+				return err
+			}
+			for _, product := range found {
+				existing[product.SKU] = product
+			}
+		}
+
+		var create []*entity.Product
+		for i, product := range products {
+			current, ok := existing[product.SKU]
+			if !ok {
+				create = append(create, product)
+				continue
+			}
+			if !upsert {
+				result.Conflicts = append(result.Conflicts, i)
+				continue
+			}
+			product.ID = current.ID
+			product.CreatedAt = current.CreatedAt
+			if err := tx.Save(product).Error; err != nil {
+				return err
+			}
+			if err := p.appendUpdated(tx, product, current.Price); err != nil {
+				return err
+			}
+			result.Updated++
+		}
+		if len(create) > 0 {
+			if err := tx.CreateInBatches(create, 100).Error; err != nil {
+				return err
+			}
+			for _, product := range create {
+				if err := p.Outbox.append(tx, entity.EventProductCreated, entity.AggregateProduct, product.ID.String(), product); err != nil {
+					return err
+				}
+			}
+		}
+		result.Created = len(create)
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return result, nil
+	}
+	if err == nil {
+		p.Outbox.committed()
+	}
+	return result, err
+}
@@ -1,9 +1,12 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/leobelini-studies/go_expert_api/internal/entity"
 	"github.com/stretchr/testify/assert"
@@ -29,7 +32,7 @@ func TestCreateNewProduct(t *testing.T) {
 	product, err := entity.NewProduct("Product 1", 10)
 	assert.NoError(t, err)
 	productDB := NewProduct(db)
-	err = productDB.Create(product)
+	err = productDB.Create(context.Background(), product)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, product.ID)
 }
@@ -46,25 +49,90 @@ func TestFindAllProducts(t *testing.T) {
 		db.Create(product)
 	}
 	productDB := NewProduct(db)
-	products, err := productDB.FindAll(1, 10, "asc")
+	products, err := productDB.FindAll(context.Background(), 1, 10, "asc")
 	assert.NoError(t, err)
 	assert.Len(t, products, 10)
 	assert.Equal(t, "Product 1", products[0].Name)
 	assert.Equal(t, "Product 10", products[9].Name)
 
-	products, err = productDB.FindAll(2, 10, "asc")
+	products, err = productDB.FindAll(context.Background(), 2, 10, "asc")
 	assert.NoError(t, err)
 	assert.Len(t, products, 10)
 	assert.Equal(t, "Product 11", products[0].Name)
 	assert.Equal(t, "Product 20", products[9].Name)
 
-	products, err = productDB.FindAll(3, 10, "asc")
+	products, err = productDB.FindAll(context.Background(), 3, 10, "asc")
 	assert.NoError(t, err)
 	assert.Len(t, products, 3)
 	assert.Equal(t, "Product 21", products[0].Name)
 	assert.Equal(t, "Product 23", products[2].Name)
 }
 
+func TestSearchProducts(t *testing.T) {
+	db, err := createDatabase()
+	if err != nil {
+		t.Error(err)
+	}
+
+	for i, name := range []string{"Cola", "Diet cola", "Bread", "100% juice", "Orange juice"} {
+		product, err := entity.NewProduct(name, float64(i+1))
+		assert.NoError(t, err)
+		if name != "Bread" {
+			product.Category = "drinks"
+		}
+		db.Create(product)
+	}
+	productDB := NewProduct(db)
+	ctx := context.Background()
+
+	products, total, err := productDB.Search(ctx, ProductFilter{Name: "COLA"}, 1, 10, "asc")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, products, 2)
+	assert.Equal(t, "Cola", products[0].Name)
+
+	// wildcards in the name match themselves
+	products, _, err = productDB.Search(ctx, ProductFilter{Name: "0%"}, 1, 10, "asc")
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "100% juice", products[0].Name)
+
+	minPrice, maxPrice := 2.0, 4.0
+	products, total, err = productDB.Search(ctx, ProductFilter{Category: "drinks", MinPrice: &minPrice, MaxPrice: &maxPrice}, 1, 1, "desc")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "100% juice", products[0].Name)
+
+	products, total, err = productDB.Search(ctx, ProductFilter{}, 2, 3, "asc")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Len(t, products, 2)
+	assert.Equal(t, "100% juice", products[0].Name)
+}
+
+func TestFindProductsByIDs(t *testing.T) {
+	db, err := createDatabase()
+	if err != nil {
+		t.Error(err)
+	}
+
+	first, _ := entity.NewProduct("Product 1", 10)
+	second, _ := entity.NewProduct("Product 2", 20)
+	db.Create(first)
+	db.Create(second)
+	productDB := NewProduct(db)
+
+	products, err := productDB.FindByIDs(context.Background(), []string{second.ID.String(), "missing"})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, second.ID, products[0].ID)
+
+	products, err = productDB.FindByIDs(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, products)
+}
+
 func TestFindProductByID(t *testing.T) {
 	db, err := createDatabase()
 	if err != nil {
@@ -76,7 +144,7 @@ func TestFindProductByID(t *testing.T) {
 
 	db.Create(product)
 	productDB := NewProduct(db)
-	productFound, err := productDB.FindByID(product.ID.String())
+	productFound, err := productDB.FindByID(context.Background(), product.ID.String())
 	assert.NoError(t, err)
 	assert.Equal(t, product.ID, productFound.ID)
 }
@@ -93,10 +161,10 @@ func TestUpdateProduct(t *testing.T) {
 	db.Create(product)
 	productDB := NewProduct(db)
 	product.Name = "Product 2"
-	err = productDB.Update(product)
+	err = productDB.Update(context.Background(), product)
 	assert.NoError(t, err)
 
-	productFound, err := productDB.FindByID(product.ID.String())
+	productFound, err := productDB.FindByID(context.Background(), product.ID.String())
 	assert.NoError(t, err)
 	assert.Equal(t, "Product 2", productFound.Name)
 }
@@ -114,9 +182,167 @@ func TestDeleteProduct(t *testing.T) {
 	db.Create(product)
 	productDB := NewProduct(db)
 
-	err = productDB.Delete(product.ID.String())
+	err = productDB.Delete(context.Background(), product.ID.String())
 	assert.NoError(t, err)
 
-	_, err = productDB.FindByID(product.ID.String())
+	_, err = productDB.FindByID(context.Background(), product.ID.String())
 	assert.Error(t, err)
 }
+
+func TestProductQueriesHonorContext(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Product{})
+
+	product, err := entity.NewProduct("Product 1", 10)
+	assert.NoError(t, err)
+	db.Create(product)
+	productDB := NewProduct(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = productDB.FindByID(ctx, product.ID.String())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err = productDB.FindAll(ctx, 1, 10, "asc")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestStreamProducts(t *testing.T) {
+	db, err := createDatabase()
+	if err != nil {
+		t.Error(err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		product, err := entity.NewProduct(fmt.Sprintf("Product %d", i), 10)
+		assert.NoError(t, err)
+		db.Create(product)
+	}
+	productDB := NewProduct(db)
+
+	var names []string
+	err = productDB.Stream(context.Background(), "desc", func(product *entity.Product) error {
+		names = append(names, product.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Product 5", "Product 4", "Product 3", "Product 2", "Product 1"}, names)
+
+	stop := errors.New("stop")
+	count := 0
+	err = productDB.Stream(context.Background(), "asc", func(product *entity.Product) error {
+		count++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, count)
+}
+
+func newSKUProduct(t *testing.T, name, sku string, price float64) *entity.Product {
+	product, err := entity.NewProduct(name, price)
+	assert.NoError(t, err)
+	product.SKU = sku
+	return product
+}
+
+func TestProductsWithoutSKUDoNotConflict(t *testing.T) {
+	db, err := createDatabase()
+	if err != nil {
+		t.Error(err)
+	}
+	productDB := NewProduct(db)
+
+	assert.NoError(t, productDB.Create(context.Background(), newSKUProduct(t, "Product 1", "", 10)))
+	assert.NoError(t, productDB.Create(context.Background(), newSKUProduct(t, "Product 2", "", 10)))
+	assert.NoError(t, productDB.Create(context.Background(), newSKUProduct(t, "Product 3", "SKU-1", 10)))
+	assert.Error(t, productDB.Create(context.Background(), newSKUProduct(t, "Product 4", "SKU-1", 10)))
+
+	found, err := productDB.FindBySKU(context.Background(), "SKU-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Product 3", found.Name)
+}
+
+func TestImportBatch(t *testing.T) {
+	db, err := createDatabase()
+	if err != nil {
+		t.Error(err)
+	}
+	productDB := NewProduct(db)
+	ctx := context.Background()
+	existing := newSKUProduct(t, "Old name", "SKU-1", 10)
+	productDB.Create(ctx, existing)
+
+	batch := func() []*entity.Product {
+		return []*entity.Product{
+			newSKUProduct(t, "New name", "SKU-1", 20),
+			newSKUProduct(t, "Product 2", "SKU-2", 30),
+			newSKUProduct(t, "Product 3", "", 40),
+		}
+	}
+
+	result, err := productDB.ImportBatch(ctx, batch(), false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportBatchResult{Created: 2, Conflicts: []int{0}}, result)
+
+	var count int64
+	db.Model(&entity.Product{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	products := batch()
+	products[1].SKU = "SKU-4"
+	result, err = productDB.ImportBatch(ctx, products, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportBatchResult{Created: 2, Updated: 1}, result)
+	// nothing changed in a dry run
+	db.Model(&entity.Product{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+	found, _ := productDB.FindBySKU(ctx, "SKU-1")
+	assert.Equal(t, "Old name", found.Name)
+
+	result, err = productDB.ImportBatch(ctx, batch()[:1], true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Updated)
+	found, _ = productDB.FindBySKU(ctx, "SKU-1")
+	assert.Equal(t, existing.ID, found.ID)
+	assert.Equal(t, "New name", found.Name)
+	assert.Equal(t, 20.0, found.Price)
+}
+
+func TestProductTransaction(t *testing.T) {
+	db, err := createDatabase()
+	if err != nil {
+		t.Error(err)
+	}
+	productDB := NewProduct(db)
+	ctx := context.Background()
+
+	kept := newSKUProduct(t, "Product 1", "SKU-1", 10)
+	err = productDB.Transaction(ctx, func(products ProductInterface) error {
+		return products.Create(ctx, kept)
+	})
+	assert.NoError(t, err)
+	_, err = productDB.FindByID(ctx, kept.ID.String())
+	assert.NoError(t, err)
+
+	rolledBack := newSKUProduct(t, "Product 2", "SKU-2", 10)
+	failure := errors.New("second operation failed")
+	err = productDB.Transaction(ctx, func(products ProductInterface) error {
+		if err := products.Create(ctx, rolledBack); err != nil {
+			return err
+		}
+		if err := products.Delete(ctx, kept.ID.String()); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	_, err = productDB.FindByID(ctx, rolledBack.ID.String())
+	assert.Error(t, err)
+	_, err = productDB.FindByID(ctx, kept.ID.String())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type RecoveryCode struct {
+	DB *gorm.DB
+}
+
+func NewRecoveryCode(db *gorm.DB) *RecoveryCode {
+	return &RecoveryCode{
+		DB: db,
+	}
+}
+
+// Replace deletes every code of the user and stores the new ones.
+func (c *RecoveryCode) Replace(ctx context.Context, userID string, codes []*entity.RecoveryCode) error {
+	return c.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(codes).Error
+	})
+}
+
+// Consume marks the matching unused code of the user as used.
+func (c *RecoveryCode) Consume(ctx context.Context, userID, hash string) error {
+	result := c.DB.WithContext(ctx).Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (c *RecoveryCode) DeleteByUser(ctx context.Context, userID string) error {
+	return c.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&entity.RecoveryCode{}).Error
+}
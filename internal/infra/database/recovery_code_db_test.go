@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestReplaceAndConsumeRecoveryCodes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.RecoveryCode{})
+
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	codeDB := NewRecoveryCode(db)
+
+	oldCodes, oldPlain, _ := entity.NewRecoveryCodes(user.ID, 2)
+	assert.Nil(t, codeDB.Replace(context.Background(), user.ID.String(), oldCodes))
+
+	codes, plain, _ := entity.NewRecoveryCodes(user.ID, 2)
+	assert.Nil(t, codeDB.Replace(context.Background(), user.ID.String(), codes))
+
+	assert.Error(t, codeDB.Consume(context.Background(), user.ID.String(), entity.HashRecoveryCode(oldPlain[0])))
+	assert.Nil(t, codeDB.Consume(context.Background(), user.ID.String(), entity.HashRecoveryCode(plain[0])))
+	assert.Error(t, codeDB.Consume(context.Background(), user.ID.String(), entity.HashRecoveryCode(plain[0])))
+
+	assert.Nil(t, codeDB.DeleteByUser(context.Background(), user.ID.String()))
+	assert.Error(t, codeDB.Consume(context.Background(), user.ID.String(), entity.HashRecoveryCode(plain[1])))
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevokedToken struct {
+	DB *gorm.DB
+}
+
+func NewRevokedToken(db *gorm.DB) *RevokedToken {
+	return &RevokedToken{
+		DB: db,
+	}
+}
+
+// Revoke is idempotent: revoking the same jti twice is not an error.
+func (t *RevokedToken) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	token := entity.RevokedToken{
+		JTI:       jti,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	}
+	return t.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&token).Error
+}
+
+func (t *RevokedToken) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := t.DB.WithContext(ctx).Model(&entity.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpired drops tokens that would be rejected for being expired anyway.
+func (t *RevokedToken) DeleteExpired(ctx context.Context, now time.Time) error {
+	return t.DB.WithContext(ctx).Where("expires_at < ?", now).Delete(&entity.RevokedToken{}).Error
+}
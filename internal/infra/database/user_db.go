@@ -1,12 +1,16 @@
 package database
 
 import (
+	"context"
+
 	"github.com/leobelini-studies/go_expert_api/internal/entity"
 	"gorm.io/gorm"
 )
 
 type User struct {
 	DB *gorm.DB
+	// Outbox, when set, stores an event for every user created.
+	Outbox *Outbox
 }
 
 func NewUser(db *gorm.DB) *User {
@@ -15,14 +19,57 @@ func NewUser(db *gorm.DB) *User {
 	}
 }
 
-func (u *User) Create(user *entity.User) error {
-	return u.DB.Create(user).Error
+func (u *User) Create(ctx context.Context, user *entity.User) error {
+	if u.Outbox == nil {
+		return u.DB.WithContext(ctx).Create(user).Error
+	}
+	err := u.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return u.Outbox.append(tx, entity.EventUserCreated, entity.AggregateUser, user.ID.String(), user)
+	})
+	if err == nil {
+		u.Outbox.committed()
+	}
+	return err
+}
+
+func (u *User) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
+	var user entity.User
+	if err := u.DB.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
-func (u *User) FindByEmail(email string) (*entity.User, error) {
+func (u *User) FindByID(ctx context.Context, id string) (*entity.User, error) {
 	var user entity.User
-	if err := u.DB.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := u.DB.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+func (u *User) Update(ctx context.Context, user *entity.User) error {
+	_, err := u.FindByID(ctx, user.ID.String())
+	if err != nil {
+		return err
+	}
+	return u.DB.WithContext(ctx).Save(user).Error
+}
+
+// UpdateMFALastStep persists the TOTP step of the last accepted code only if
+// no other request consumed a newer step in the meantime.
+func (u *User) UpdateMFALastStep(ctx context.Context, user *entity.User, previous int64) error {
+	result := u.DB.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ? AND mfa_last_step = ?", user.ID, previous).
+		Update("mfa_last_step", user.MFALastStep)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrMFACodeReused
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"testing"
 
 	"github.com/leobelini-studies/go_expert_api/internal/entity"
@@ -20,7 +21,7 @@ func TestCreateUser(t *testing.T) {
 	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
 	userDB := NewUser(db)
 
-	if err := userDB.Create(user); err != nil {
+	if err := userDB.Create(context.Background(), user); err != nil {
 		t.Error(err)
 	}
 
@@ -46,13 +47,72 @@ func TestFindByEmail(t *testing.T) {
 	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
 	dbUser := NewUser(db)
 
-	err = dbUser.Create(user)
+	err = dbUser.Create(context.Background(), user)
 	assert.Nil(t, err)
 
-	userFound, err := dbUser.FindByEmail("1y3t3@example.com")
+	userFound, err := dbUser.FindByEmail(context.Background(), "1y3t3@example.com")
 	assert.Nil(t, err)
 	assert.Equal(t, user.ID, userFound.ID)
 	assert.Equal(t, user.Name, userFound.Name)
 	assert.Equal(t, user.Email, userFound.Email)
 	assert.NotNil(t, userFound.Password)
 }
+
+func TestFindUserByID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	userDB := NewUser(db)
+	assert.Nil(t, userDB.Create(context.Background(), user))
+
+	userFound, err := userDB.FindByID(context.Background(), user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, userFound.ID)
+	assert.Equal(t, user.Email, userFound.Email)
+}
+
+func TestUpdateUser(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	userDB := NewUser(db)
+	assert.Nil(t, userDB.Create(context.Background(), user))
+
+	user.VerifyEmail()
+	assert.Nil(t, userDB.Update(context.Background(), user))
+
+	userFound, err := userDB.FindByID(context.Background(), user.ID.String())
+	assert.Nil(t, err)
+	assert.True(t, userFound.IsEmailVerified())
+}
+
+func TestUpdateUserMFALastStep(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.User{})
+
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	userDB := NewUser(db)
+	assert.Nil(t, userDB.Create(context.Background(), user))
+
+	user.MFALastStep = 10
+	assert.Nil(t, userDB.UpdateMFALastStep(context.Background(), user, 0))
+
+	// a concurrent request that read the old step must not win
+	user.MFALastStep = 11
+	assert.Equal(t, entity.ErrMFACodeReused, userDB.UpdateMFALastStep(context.Background(), user, 0))
+
+	userFound, err := userDB.FindByID(context.Background(), user.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), userFound.MFALastStep)
+}
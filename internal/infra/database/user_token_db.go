@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+)
+
+type UserToken struct {
+	DB *gorm.DB
+}
+
+func NewUserToken(db *gorm.DB) *UserToken {
+	return &UserToken{
+		DB: db,
+	}
+}
+
+func (t *UserToken) Create(ctx context.Context, token *entity.UserToken) error {
+	return t.DB.WithContext(ctx).Create(token).Error
+}
+
+func (t *UserToken) FindByHash(ctx context.Context, tokenType, hash string) (*entity.UserToken, error) {
+	var token entity.UserToken
+	if err := t.DB.WithContext(ctx).First(&token, "type = ? AND token_hash = ?", tokenType, hash).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes the token. The update is conditional so that two
+// concurrent requests can never both redeem the same token.
+func (t *UserToken) MarkUsed(ctx context.Context, token *entity.UserToken) error {
+	now := time.Now()
+	result := t.DB.WithContext(ctx).Model(&entity.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.ErrTokenAlreadyUsed
+	}
+	token.UsedAt = &now
+	return nil
+}
+
+func (t *UserToken) DeleteByUser(ctx context.Context, userID, tokenType string) error {
+	return t.DB.WithContext(ctx).Where("user_id = ? AND type = ?", userID, tokenType).Delete(&entity.UserToken{}).Error
+}
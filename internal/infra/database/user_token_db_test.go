@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func createUserTokenDatabase(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.UserToken{})
+	return db
+}
+
+func TestFindUserTokenByHash(t *testing.T) {
+	db := createUserTokenDatabase(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	token, secret, err := entity.NewUserToken(user.ID, entity.TokenTypeEmailVerification, time.Hour)
+	assert.Nil(t, err)
+
+	tokenDB := NewUserToken(db)
+	assert.Nil(t, tokenDB.Create(context.Background(), token))
+
+	tokenFound, err := tokenDB.FindByHash(context.Background(), entity.TokenTypeEmailVerification, entity.HashToken(secret))
+	assert.Nil(t, err)
+	assert.Equal(t, token.ID, tokenFound.ID)
+	assert.Equal(t, user.ID, tokenFound.UserID)
+
+	_, err = tokenDB.FindByHash(context.Background(), entity.TokenTypePasswordReset, entity.HashToken(secret))
+	assert.Error(t, err)
+}
+
+func TestMarkUserTokenUsed(t *testing.T) {
+	db := createUserTokenDatabase(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	token, secret, _ := entity.NewUserToken(user.ID, entity.TokenTypePasswordReset, time.Hour)
+
+	tokenDB := NewUserToken(db)
+	assert.Nil(t, tokenDB.Create(context.Background(), token))
+
+	assert.Nil(t, tokenDB.MarkUsed(context.Background(), token))
+	assert.NotNil(t, token.UsedAt)
+
+	tokenFound, err := tokenDB.FindByHash(context.Background(), entity.TokenTypePasswordReset, entity.HashToken(secret))
+	assert.Nil(t, err)
+	assert.Equal(t, entity.ErrTokenAlreadyUsed, tokenFound.Validate())
+	assert.Equal(t, entity.ErrTokenAlreadyUsed, tokenDB.MarkUsed(context.Background(), tokenFound))
+}
+
+func TestDeleteUserTokensByUser(t *testing.T) {
+	db := createUserTokenDatabase(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	reset, resetSecret, _ := entity.NewUserToken(user.ID, entity.TokenTypePasswordReset, time.Hour)
+	verify, verifySecret, _ := entity.NewUserToken(user.ID, entity.TokenTypeEmailVerification, time.Hour)
+
+	tokenDB := NewUserToken(db)
+	assert.Nil(t, tokenDB.Create(context.Background(), reset))
+	assert.Nil(t, tokenDB.Create(context.Background(), verify))
+
+	assert.Nil(t, tokenDB.DeleteByUser(context.Background(), user.ID.String(), entity.TokenTypePasswordReset))
+
+	_, err := tokenDB.FindByHash(context.Background(), entity.TokenTypePasswordReset, entity.HashToken(resetSecret))
+	assert.Error(t, err)
+	_, err = tokenDB.FindByHash(context.Background(), entity.TokenTypeEmailVerification, entity.HashToken(verifySecret))
+	assert.Nil(t, err)
+}
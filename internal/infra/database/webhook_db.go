@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Webhook struct {
+	DB *gorm.DB
+}
+
+func NewWebhook(db *gorm.DB) *Webhook {
+	return &Webhook{
+		DB: db,
+	}
+}
+
+func (h *Webhook) Create(ctx context.Context, webhook *entity.Webhook) error {
+	return h.DB.WithContext(ctx).Create(webhook).Error
+}
+
+func (h *Webhook) FindByID(ctx context.Context, id string) (*entity.Webhook, error) {
+	var webhook entity.Webhook
+	if err := h.DB.WithContext(ctx).First(&webhook, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (h *Webhook) FindByUser(ctx context.Context, userID string) ([]*entity.Webhook, error) {
+	var webhooks []*entity.Webhook
+	err := h.DB.WithContext(ctx).Where("user_id = ?", userID).Order("created_at desc").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// FindByEvent returns the webhooks subscribed to eventType. Events is a
+// comma separated list, so the type is matched between commas.
+func (h *Webhook) FindByEvent(ctx context.Context, eventType string) ([]*entity.Webhook, error) {
+	var webhooks []*entity.Webhook
+	pattern := "%," + likeEscaper.Replace(eventType) + ",%"
+	err := h.DB.WithContext(ctx).Where("',' || events || ',' LIKE ? ESCAPE '\\'", pattern).Find(&webhooks).Error
+	return webhooks, err
+}
+
+// likeEscaper escapes the wildcards of LIKE, as event types contain "_".
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Delete deletes the webhook, only if it belongs to the user, with its
+// deliveries.
+func (h *Webhook) Delete(ctx context.Context, userID, id string) error {
+	return h.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var webhook entity.Webhook
+		if err := tx.First(&webhook, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entity.WebhookDelivery{}, "webhook_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&webhook).Error
+	})
+}
+
+type WebhookDelivery struct {
+	DB *gorm.DB
+}
+
+func NewWebhookDelivery(db *gorm.DB) *WebhookDelivery {
+	return &WebhookDelivery{
+		DB: db,
+	}
+}
+
+// Create stores the deliveries, skipping those of an event already stored
+// for the same webhook, as when the outbox relay publishes a message again.
+func (d *WebhookDelivery) Create(ctx context.Context, deliveries []*entity.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return d.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(deliveries).Error
+}
+
+func (d *WebhookDelivery) FindByID(ctx context.Context, webhookID, id string) (*entity.WebhookDelivery, error) {
+	var delivery entity.WebhookDelivery
+	if err := d.DB.WithContext(ctx).First(&delivery, "id = ? AND webhook_id = ?", id, webhookID).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// FindByWebhook returns the latest deliveries of the webhook, only those
+// with status when it is not empty.
+func (d *WebhookDelivery) FindByWebhook(ctx context.Context, webhookID, status string, limit int) ([]*entity.WebhookDelivery, error) {
+	var deliveries []*entity.WebhookDelivery
+	query := d.DB.WithContext(ctx).Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at desc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// FindDue returns the pending deliveries whose next attempt is due at now,
+// oldest first.
+func (d *WebhookDelivery) FindDue(ctx context.Context, now time.Time, limit int) ([]*entity.WebhookDelivery, error) {
+	var deliveries []*entity.WebhookDelivery
+	err := d.DB.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", entity.DeliveryStatusPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (d *WebhookDelivery) Update(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	return d.DB.WithContext(ctx).Save(delivery).Error
+}
+
+// DeleteSucceededBefore deletes the deliveries that succeeded before t.
+func (d *WebhookDelivery) DeleteSucceededBefore(ctx context.Context, t time.Time) error {
+	return d.DB.WithContext(ctx).
+		Delete(&entity.WebhookDelivery{}, "status = ? AND delivered_at < ?", entity.DeliveryStatusSucceeded, t).Error
+}
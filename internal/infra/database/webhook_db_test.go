@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestFindWebhooksByEvent(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Webhook{}, &entity.WebhookDelivery{})
+	webhookDB := NewWebhook(db)
+	ctx := context.Background()
+
+	userID := entityPkg.NewID()
+	products, _, _ := entity.NewWebhook(userID, "https://example.com/products", []string{entity.EventProductCreated, entity.EventProductDeleted}, "")
+	users, _, _ := entity.NewWebhook(userID, "https://example.com/users", []string{entity.EventUserCreated}, "")
+	assert.Nil(t, webhookDB.Create(ctx, products))
+	assert.Nil(t, webhookDB.Create(ctx, users))
+
+	found, err := webhookDB.FindByEvent(ctx, entity.EventProductDeleted)
+	assert.Nil(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, products.ID, found[0].ID)
+	assert.Equal(t, products.Secret, found[0].Secret)
+
+	found, _ = webhookDB.FindByEvent(ctx, entity.EventProductUpdated)
+	assert.Empty(t, found)
+
+	found, _ = webhookDB.FindByEvent(ctx, entity.EventProductCreated)
+	assert.Len(t, found, 1)
+
+	// LIKE wildcards in the type are matched literally
+	found, _ = webhookDB.FindByEvent(ctx, "product_created")
+	assert.Empty(t, found)
+	found, _ = webhookDB.FindByEvent(ctx, "product.%")
+	assert.Empty(t, found)
+
+	found, _ = webhookDB.FindByUser(ctx, userID.String())
+	assert.Len(t, found, 2)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.Webhook{}, &entity.WebhookDelivery{})
+	webhookDB := NewWebhook(db)
+	deliveryDB := NewWebhookDelivery(db)
+	ctx := context.Background()
+
+	userID := entityPkg.NewID()
+	webhook, _, _ := entity.NewWebhook(userID, "https://example.com", []string{entity.EventUserCreated}, "")
+	webhookDB.Create(ctx, webhook)
+	event := entity.NewEvent(entity.EventUserCreated, nil)
+	deliveryDB.Create(ctx, []*entity.WebhookDelivery{entity.NewWebhookDelivery(webhook.ID, event, []byte(`{}`))})
+
+	err = webhookDB.Delete(ctx, entityPkg.NewID().String(), webhook.ID.String())
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	assert.Nil(t, webhookDB.Delete(ctx, userID.String(), webhook.ID.String()))
+	_, err = webhookDB.FindByID(ctx, webhook.ID.String())
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	deliveries, _ := deliveryDB.FindByWebhook(ctx, webhook.ID.String(), "", 10)
+	assert.Empty(t, deliveries)
+}
+
+func TestFindDueWebhookDeliveries(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.WebhookDelivery{})
+	deliveryDB := NewWebhookDelivery(db)
+	ctx := context.Background()
+
+	webhookID := entityPkg.NewID()
+	due := entity.NewWebhookDelivery(webhookID, entity.NewEvent(entity.EventProductCreated, nil), []byte(`{"a":1}`))
+	later := entity.NewWebhookDelivery(webhookID, entity.NewEvent(entity.EventProductCreated, nil), []byte(`{}`))
+	later.Fail(500, errors.New("server error"), 5, time.Hour)
+	dead := entity.NewWebhookDelivery(webhookID, entity.NewEvent(entity.EventProductCreated, nil), []byte(`{}`))
+	dead.Fail(500, errors.New("server error"), 1, time.Hour)
+	assert.Nil(t, deliveryDB.Create(ctx, []*entity.WebhookDelivery{due, later, dead}))
+
+	found, err := deliveryDB.FindDue(ctx, time.Now(), 10)
+	assert.Nil(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, due.ID, found[0].ID)
+	assert.JSONEq(t, `{"a":1}`, string(found[0].Payload))
+
+	found, _ = deliveryDB.FindByWebhook(ctx, webhookID.String(), entity.DeliveryStatusDead, 10)
+	assert.Len(t, found, 1)
+	assert.Equal(t, dead.ID, found[0].ID)
+
+	due.Succeed(200)
+	deliveryDB.Update(ctx, due)
+	assert.Nil(t, deliveryDB.DeleteSucceededBefore(ctx, time.Now().Add(time.Minute)))
+	found, _ = deliveryDB.FindByWebhook(ctx, webhookID.String(), "", 10)
+	assert.Len(t, found, 2)
+}
+
+func TestCreateWebhookDeliverySkipsRepublishedEvent(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.WebhookDelivery{})
+	deliveryDB := NewWebhookDelivery(db)
+	ctx := context.Background()
+
+	webhookID := entityPkg.NewID()
+	event := entity.NewEvent(entity.EventProductCreated, nil)
+	first := entity.NewWebhookDelivery(webhookID, event, []byte(`{}`))
+	assert.Nil(t, deliveryDB.Create(ctx, []*entity.WebhookDelivery{first}))
+	// the outbox relay publishes the message again after another publisher failed
+	again := entity.NewWebhookDelivery(webhookID, event, []byte(`{}`))
+	other := entity.NewWebhookDelivery(entityPkg.NewID(), event, []byte(`{}`))
+	assert.Nil(t, deliveryDB.Create(ctx, []*entity.WebhookDelivery{again, other}))
+
+	found, _ := deliveryDB.FindByWebhook(ctx, webhookID.String(), "", 10)
+	assert.Len(t, found, 1)
+	assert.Equal(t, first.ID, found[0].ID)
+	found, _ = deliveryDB.FindByWebhook(ctx, other.WebhookID.String(), "", 10)
+	assert.Len(t, found, 1)
+}
@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+)
+
+// Column is a product field that can be exported. Value returns a string,
+// a float64 or a time.Time, which each format writes in its own way.
+type Column struct {
+	Name  string
+	Value func(p *entity.Product) interface{}
+}
+
+// Columns are the exportable columns, in their default order.
+var Columns = []Column{
+	{Name: "id", Value: func(p *entity.Product) interface{} { return p.ID.String() }},
+	{Name: "name", Value: func(p *entity.Product) interface{} { return p.Name }},
+	{Name: "price", Value: func(p *entity.Product) interface{} { return p.Price }},
+	{Name: "sku", Value: func(p *entity.Product) interface{} { return p.SKU }},
+	{Name: "category", Value: func(p *entity.Product) interface{} { return p.Category }},
+	{Name: "created_at", Value: func(p *entity.Product) interface{} { return p.CreatedAt.UTC() }},
+}
+
+// ParseColumns reads a comma separated list of column names. An empty list
+// selects every column.
+func ParseColumns(list string) ([]Column, error) {
+	if strings.TrimSpace(list) == "" {
+		return Columns, nil
+	}
+	var columns []Column
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		column, ok := findColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", name, strings.Join(ColumnNames(Columns), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %q selected twice", name)
+		}
+		seen[name] = true
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func ColumnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func findColumn(name string) (Column, bool) {
+	for _, c := range Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
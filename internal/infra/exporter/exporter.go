@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"context"
+	"io"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+)
+
+const DefaultFlushEvery = 100
+
+// Source streams the products in the order of the listing.
+type Source interface {
+	Stream(ctx context.Context, sort string, fn func(*entity.Product) error) error
+}
+
+// Exporter writes the products of Source to a file, one row at a time.
+type Exporter struct {
+	Products Source
+	// FlushEvery is how many rows are written between progress reports.
+	FlushEvery int
+}
+
+func New(products Source) *Exporter {
+	return &Exporter{
+		Products:   products,
+		FlushEvery: DefaultFlushEvery,
+	}
+}
+
+// Options select what an export contains.
+type Options struct {
+	Format  string
+	Columns []Column
+	Sort    string
+}
+
+// Run writes the products to w and returns how many rows were written.
+// progress, when not nil, is called with that count every FlushEvery rows,
+// after the rows were flushed to w.
+func (e *Exporter) Run(ctx context.Context, w io.Writer, opts Options, progress func(rows int)) (int, error) {
+	out, err := NewWriter(opts.Format, w, ColumnNames(opts.Columns))
+	if err != nil {
+		return 0, err
+	}
+	var rows int
+	values := make([]interface{}, len(opts.Columns))
+	err = e.Products.Stream(ctx, opts.Sort, func(p *entity.Product) error {
+		for i, c := range opts.Columns {
+			values[i] = c.Value(p)
+		}
+		if err := out.Write(values); err != nil {
+			return err
+		}
+		rows++
+		if e.FlushEvery > 0 && rows%e.FlushEvery == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			if progress != nil {
+				progress(rows)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	return rows, out.Close()
+}
@@ -0,0 +1,157 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	return testutil.NewSQLite(t, &entity.Product{}, &entity.ExportJob{})
+}
+
+func createProducts(t *testing.T, productDB *database.Product, names ...string) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, name := range names {
+		p, err := entity.NewProduct(name, float64(i+1)+0.5)
+		if !assert.Nil(t, err) {
+			return
+		}
+		p.SKU = "SKU-" + string(rune('A'+i))
+		p.CreatedAt = created.Add(time.Duration(i) * time.Hour)
+		productDB.Create(context.Background(), p)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "name", "price", "sku", "category", "created_at"}, ColumnNames(columns))
+
+	columns, err = ParseColumns(" SKU, price ")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sku", "price"}, ColumnNames(columns))
+
+	_, err = ParseColumns("name,cost")
+	assert.ErrorContains(t, err, `unknown column "cost"`)
+	_, err = ParseColumns("name,name")
+	assert.ErrorContains(t, err, "selected twice")
+}
+
+func TestExportCSV(t *testing.T) {
+	productDB := database.NewProduct(newTestDB(t))
+	createProducts(t, productDB, "Product 1", "=cmd()", "Product, 3")
+	columns, _ := ParseColumns("name,price,sku,created_at")
+
+	var buf bytes.Buffer
+	rows, err := New(productDB).Run(context.Background(), &buf, Options{Format: entity.ExportFormatCSV, Columns: columns, Sort: "desc"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, rows)
+	assert.Equal(t, "name,price,sku,created_at\n"+
+		"\"Product, 3\",3.5,SKU-C,2026-01-02T05:04:05Z\n"+
+		"'=cmd(),2.5,SKU-B,2026-01-02T04:04:05Z\n"+
+		"Product 1,1.5,SKU-A,2026-01-02T03:04:05Z\n", buf.String())
+}
+
+func TestExportNDJSON(t *testing.T) {
+	productDB := database.NewProduct(newTestDB(t))
+	createProducts(t, productDB, "Product 1", "Product 2")
+	columns, _ := ParseColumns("sku,name,price")
+
+	var buf bytes.Buffer
+	_, err := New(productDB).Run(context.Background(), &buf, Options{Format: entity.ExportFormatNDJSON, Columns: columns}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"sku\":\"SKU-A\",\"name\":\"Product 1\",\"price\":1.5}\n"+
+		"{\"sku\":\"SKU-B\",\"name\":\"Product 2\",\"price\":2.5}\n", buf.String())
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Type  string `xml:"t,attr"`
+			Style string `xml:"s,attr"`
+			Value string `xml:"v"`
+			Text  string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readSheet(t *testing.T, data []byte) xlsxSheet {
+	var sheet xlsxSheet
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.Nil(t, err) {
+		return sheet
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Nil(t, xml.Unmarshal(content, &sheet))
+	}
+	assert.Contains(t, names, "[Content_Types].xml")
+	assert.Contains(t, names, "xl/workbook.xml")
+	return sheet
+}
+
+func TestExportXLSX(t *testing.T) {
+	productDB := database.NewProduct(newTestDB(t))
+	createProducts(t, productDB, "Product <1> & co", "Product 2")
+	columns, _ := ParseColumns("name,price,created_at")
+
+	var buf bytes.Buffer
+	exporter := New(productDB)
+	exporter.FlushEvery = 1
+	var progress []int
+	rows, err := exporter.Run(context.Background(), &buf, Options{Format: entity.ExportFormatXLSX, Columns: columns}, func(rows int) {
+		progress = append(progress, rows)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, []int{1, 2}, progress)
+
+	sheet := readSheet(t, buf.Bytes())
+	if !assert.Len(t, sheet.Rows, 3) {
+		return
+	}
+	header := sheet.Rows[0].Cells
+	assert.Equal(t, "name", header[0].Text)
+	assert.Equal(t, "1", header[0].Style)
+	first := sheet.Rows[1].Cells
+	assert.Equal(t, "inlineStr", first[0].Type)
+	assert.Equal(t, "Product <1> & co", first[0].Text)
+	assert.Equal(t, "1.5", first[1].Value)
+	// 2026-01-02 03:04:05 counted in days from 1899-12-30
+	assert.True(t, strings.HasPrefix(first[2].Value, "46024.12783"), first[2].Value)
+	assert.Equal(t, "2", first[2].Style)
+}
+
+func TestExportEmptyWritesHeader(t *testing.T) {
+	productDB := database.NewProduct(newTestDB(t))
+	columns, _ := ParseColumns("name,price")
+
+	var buf bytes.Buffer
+	_, err := New(productDB).Run(context.Background(), &buf, Options{Format: entity.ExportFormatCSV, Columns: columns}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "name,price\n", buf.String())
+
+	buf.Reset()
+	_, err = New(productDB).Run(context.Background(), &buf, Options{Format: entity.ExportFormatXLSX, Columns: columns}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, readSheet(t, buf.Bytes()).Rows, 1)
+}
@@ -0,0 +1,192 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+)
+
+var ErrQueueFull = errors.New("too many exports waiting, try again later")
+
+// Runner runs exports in the background. Each export is written to a file
+// in Dir, which is kept for its owner to download until the job expires.
+type Runner struct {
+	Exporter *Exporter
+	Jobs     database.ExportJobInterface
+	Dir      string
+	Workers  int
+
+	queue chan string
+}
+
+func NewRunner(exporter *Exporter, jobs database.ExportJobInterface, dir string, workers, queueSize int) (*Runner, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Runner{
+		Exporter: exporter,
+		Jobs:     jobs,
+		Dir:      dir,
+		Workers:  workers,
+		queue:    make(chan string, queueSize),
+	}, nil
+}
+
+// Recover fails the jobs a previous process left unfinished and removes
+// their partial files. It must run before jobs are submitted.
+func (r *Runner) Recover(ctx context.Context) error {
+	if err := r.Jobs.FailUnfinished(ctx, "export interrupted by a server restart"); err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(r.Dir, "*.part"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		os.Remove(f)
+	}
+	return nil
+}
+
+// Submit stores job and queues it.
+func (r *Runner) Submit(ctx context.Context, job *entity.ExportJob) error {
+	if err := r.Jobs.Create(ctx, job); err != nil {
+		return err
+	}
+	select {
+	case r.queue <- job.ID.String():
+		return nil
+	default:
+		job.Finish(ErrQueueFull)
+		r.Jobs.Update(context.WithoutCancel(ctx), job)
+		return ErrQueueFull
+	}
+}
+
+// Open opens the file of a completed job.
+func (r *Runner) Open(job *entity.ExportJob) (*os.File, error) {
+	return os.Open(r.path(job))
+}
+
+// Run processes queued jobs with Workers goroutines until ctx is cancelled.
+// Jobs still queued then stay pending and are failed by the next Recover.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-r.queue:
+					r.process(ctx, id)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Runner) process(ctx context.Context, id string) {
+	log := slog.Default().With("export_job", id)
+	job, err := r.Jobs.FindByID(ctx, id)
+	if err != nil {
+		log.Error("failed to load export job", "error", err)
+		return
+	}
+
+	err = r.run(ctx, job)
+	job.Finish(err)
+	// the final state is saved even when shutting down
+	if err := r.Jobs.Update(context.WithoutCancel(ctx), job); err != nil {
+		log.Error("failed to save export job", "error", err)
+		return
+	}
+	log.Info("export finished", "status", job.Status, "rows", job.Rows)
+}
+
+func (r *Runner) run(ctx context.Context, job *entity.ExportJob) error {
+	columns, err := ParseColumns(job.Columns)
+	if err != nil {
+		return err
+	}
+	job.Start()
+	if err := r.Jobs.Update(ctx, job); err != nil {
+		return err
+	}
+
+	// the file is renamed once complete, so a download never sees a
+	// partial export
+	part := r.path(job) + ".part"
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(part)
+
+	// the rows are counted only at the end: sqlite cannot commit the
+	// progress while the products are still being read
+	rows, err := r.Exporter.Run(ctx, f, Options{Format: job.Format, Columns: columns, Sort: job.Sort}, nil)
+	job.Rows = rows
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(part, r.path(job))
+}
+
+// Cleanup removes the expired jobs and their files every interval until ctx
+// is cancelled.
+func (r *Runner) Cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.RemoveExpired(ctx, time.Now()); err != nil {
+				slog.Error("failed to remove expired exports", "error", err)
+			}
+		}
+	}
+}
+
+// RemoveExpired removes the jobs expired at now and their files.
+func (r *Runner) RemoveExpired(ctx context.Context, now time.Time) error {
+	jobs, err := r.Jobs.FindExpired(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		// a job still running keeps its file until it finishes
+		if job.Status == entity.ExportStatusRunning {
+			continue
+		}
+		if err := os.Remove(r.path(job)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := r.Jobs.Delete(ctx, job.ID.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) path(job *entity.ExportJob) string {
+	return filepath.Join(r.Dir, job.ID.String()+"."+job.Format)
+}
@@ -0,0 +1,118 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerProcessesSubmittedJob(t *testing.T) {
+	db := newTestDB(t)
+	productDB := database.NewProduct(db)
+	createProducts(t, productDB, "Product 1", "Product 2", "Product 3")
+	jobDB := database.NewExportJob(db)
+	runner, err := NewRunner(New(productDB), jobDB, t.TempDir(), 1, 10)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	job, _ := entity.NewExportJob("user-1", entity.ExportFormatCSV, "sku", "asc", time.Hour)
+	assert.Nil(t, runner.Submit(context.Background(), job))
+
+	var found *entity.ExportJob
+	assert.Eventually(t, func() bool {
+		found, _ = jobDB.FindByID(context.Background(), job.ID.String())
+		return found != nil && found.FinishedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	if found == nil {
+		return
+	}
+
+	assert.Equal(t, entity.ExportStatusCompleted, found.Status)
+	assert.Equal(t, 3, found.Rows)
+	f, err := runner.Open(found)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer f.Close()
+	content, _ := os.ReadFile(f.Name())
+	assert.Equal(t, "sku\nSKU-A\nSKU-B\nSKU-C\n", string(content))
+	parts, _ := filepath.Glob(filepath.Join(runner.Dir, "*.part"))
+	assert.Empty(t, parts)
+}
+
+func TestRunnerFailsInvalidColumns(t *testing.T) {
+	db := newTestDB(t)
+	jobDB := database.NewExportJob(db)
+	runner, _ := NewRunner(New(database.NewProduct(db)), jobDB, t.TempDir(), 1, 10)
+
+	job, _ := entity.NewExportJob("user-1", entity.ExportFormatCSV, "cost", "", time.Hour)
+	jobDB.Create(context.Background(), job)
+	runner.process(context.Background(), job.ID.String())
+
+	found, _ := jobDB.FindByID(context.Background(), job.ID.String())
+	assert.Equal(t, entity.ExportStatusFailed, found.Status)
+	assert.Contains(t, found.Error, "unknown column")
+}
+
+func TestRunnerSubmitWhenQueueFull(t *testing.T) {
+	db := newTestDB(t)
+	jobDB := database.NewExportJob(db)
+	runner, _ := NewRunner(New(database.NewProduct(db)), jobDB, t.TempDir(), 1, 0)
+
+	job, _ := entity.NewExportJob("user-1", entity.ExportFormatCSV, "", "", time.Hour)
+	assert.Equal(t, ErrQueueFull, runner.Submit(context.Background(), job))
+
+	found, _ := jobDB.FindByID(context.Background(), job.ID.String())
+	assert.Equal(t, entity.ExportStatusFailed, found.Status)
+}
+
+func TestRunnerRecoverAndRemoveExpired(t *testing.T) {
+	db := newTestDB(t)
+	jobDB := database.NewExportJob(db)
+	runner, _ := NewRunner(New(database.NewProduct(db)), jobDB, t.TempDir(), 1, 10)
+	ctx := context.Background()
+
+	running, _ := entity.NewExportJob("user-1", entity.ExportFormatCSV, "", "", time.Hour)
+	running.Start()
+	jobDB.Create(ctx, running)
+	os.WriteFile(runner.path(running)+".part", []byte("id\n"), 0o600)
+
+	assert.Nil(t, runner.Recover(ctx))
+	found, _ := jobDB.FindByID(ctx, running.ID.String())
+	assert.Equal(t, entity.ExportStatusFailed, found.Status)
+	parts, _ := filepath.Glob(filepath.Join(runner.Dir, "*.part"))
+	assert.Empty(t, parts)
+
+	expired, _ := entity.NewExportJob("user-1", entity.ExportFormatCSV, "", "", time.Hour)
+	expired.Finish(nil)
+	jobDB.Create(ctx, expired)
+	os.WriteFile(runner.path(expired), []byte("id\n"), 0o600)
+
+	assert.Nil(t, runner.RemoveExpired(ctx, time.Now()))
+	_, err := os.Stat(runner.path(expired))
+	assert.Nil(t, err)
+
+	assert.Nil(t, runner.RemoveExpired(ctx, time.Now().Add(2*time.Hour)))
+	_, err = os.Stat(runner.path(expired))
+	assert.True(t, os.IsNotExist(err))
+	_, err = jobDB.FindByID(ctx, expired.ID.String())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,153 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+)
+
+// RowWriter writes exported rows in a file format. The header, when the
+// format has one, is written with the first row or by Close, and nothing
+// reaches the underlying writer before that.
+type RowWriter interface {
+	Write(values []interface{}) error
+	// Flush sends the buffered rows to the underlying writer.
+	Flush() error
+	// Close ends the file. It does not close the underlying writer.
+	Close() error
+}
+
+// ContentType is the media type of a file in format.
+func ContentType(format string) string {
+	switch format {
+	case entity.ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case entity.ExportFormatNDJSON:
+		return "application/x-ndjson"
+	case entity.ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+func NewWriter(format string, w io.Writer, columns []string) (RowWriter, error) {
+	switch format {
+	case entity.ExportFormatCSV:
+		return NewCSVWriter(w, columns), nil
+	case entity.ExportFormatNDJSON:
+		return NewNDJSONWriter(w, columns), nil
+	case entity.ExportFormatXLSX:
+		return NewXLSXWriter(w, columns), nil
+	}
+	return nil, entity.ErrInvalidExportFormat
+}
+
+// CSVWriter writes a header line with the column names, then a line per
+// row.
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []string
+	started bool
+	record  []string
+}
+
+func NewCSVWriter(w io.Writer, columns []string) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+}
+
+func (c *CSVWriter) start() error {
+	c.started = true
+	return c.w.Write(c.columns)
+}
+
+func (c *CSVWriter) Write(values []interface{}) error {
+	if !c.started {
+		if err := c.start(); err != nil {
+			return err
+		}
+	}
+	for i, v := range values {
+		switch v := v.(type) {
+		case float64:
+			c.record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case time.Time:
+			c.record[i] = formatTime(v)
+		case string:
+			c.record[i] = escapeFormula(v)
+		}
+	}
+	return c.w.Write(c.record)
+}
+
+// escapeFormula keeps spreadsheets from evaluating text that looks like a
+// formula, e.g. a product named "=HYPERLINK(...)".
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSVWriter) Close() error {
+	if !c.started {
+		if err := c.start(); err != nil {
+			return err
+		}
+	}
+	return c.Flush()
+}
+
+// NDJSONWriter writes a JSON object per line, with the keys in the order of
+// the columns.
+type NDJSONWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func NewNDJSONWriter(w io.Writer, columns []string) *NDJSONWriter {
+	keys := make([][]byte, len(columns))
+	for i, name := range columns {
+		key, _ := json.Marshal(name)
+		keys[i] = append(key, ':')
+	}
+	return &NDJSONWriter{w: bufio.NewWriter(w), keys: keys}
+}
+
+func (n *NDJSONWriter) Write(values []interface{}) error {
+	n.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		n.w.Write(n.keys[i])
+		if t, ok := v.(time.Time); ok {
+			v = formatTime(t)
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		n.w.Write(value)
+	}
+	_, err := n.w.WriteString("}\n")
+	return err
+}
+
+func (n *NDJSONWriter) Flush() error {
+	return n.w.Flush()
+}
+
+func (n *NDJSONWriter) Close() error {
+	return n.w.Flush()
+}
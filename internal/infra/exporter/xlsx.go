@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Products" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+	// cell style 1 is the bold header and style 2 a date and time
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font/><font><b/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border/></borders><cellStyleXfs count="1"><xf/></cellStyleXfs><cellXfs count="3"><xf/><xf fontId="1" applyFont="1"/><xf numFmtId="22" applyNumberFormat="1"/></cellXfs></styleSheet>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// excelEpoch is day zero of the dates stored in spreadsheets.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// XLSXWriter writes a workbook with a single sheet. The sheet is the last
+// part of the zip file and is written row by row, so the workbook is
+// streamed without being held in memory. Text is stored inline instead of
+// in a shared strings table for the same reason.
+type XLSXWriter struct {
+	zip     *zip.Writer
+	sheet   *bufio.Writer
+	columns []string
+	started bool
+	row     int
+}
+
+func NewXLSXWriter(w io.Writer, columns []string) *XLSXWriter {
+	return &XLSXWriter{zip: zip.NewWriter(w), columns: columns}
+}
+
+func (x *XLSXWriter) start() error {
+	x.started = true
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := x.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	f, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(f)
+	x.sheet.WriteString(xlsxSheetStart)
+
+	header := make([]interface{}, len(x.columns))
+	for i, name := range x.columns {
+		header[i] = name
+	}
+	return x.writeRow(header, "1")
+}
+
+func (x *XLSXWriter) Write(values []interface{}) error {
+	if !x.started {
+		if err := x.start(); err != nil {
+			return err
+		}
+	}
+	return x.writeRow(values, "")
+}
+
+// writeRow writes a row whose text cells use textStyle.
+func (x *XLSXWriter) writeRow(values []interface{}, textStyle string) error {
+	x.row++
+	x.sheet.WriteString(`<row r="`)
+	x.sheet.WriteString(strconv.Itoa(x.row))
+	x.sheet.WriteString(`">`)
+	for _, v := range values {
+		switch v := v.(type) {
+		case float64:
+			x.sheet.WriteString(`<c><v>`)
+			x.sheet.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+			x.sheet.WriteString(`</v></c>`)
+		case time.Time:
+			days := float64(v.Sub(excelEpoch)) / float64(24*time.Hour)
+			x.sheet.WriteString(`<c s="2"><v>`)
+			x.sheet.WriteString(strconv.FormatFloat(days, 'f', -1, 64))
+			x.sheet.WriteString(`</v></c>`)
+		case string:
+			if textStyle != "" {
+				x.sheet.WriteString(`<c t="inlineStr" s="` + textStyle + `"><is><t xml:space="preserve">`)
+			} else {
+				x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			}
+			if err := xml.EscapeText(x.sheet, []byte(v)); err != nil {
+				return err
+			}
+			x.sheet.WriteString(`</t></is></c>`)
+		default:
+			x.sheet.WriteString(`<c/>`)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (x *XLSXWriter) Flush() error {
+	if !x.started {
+		return nil
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Flush()
+}
+
+func (x *XLSXWriter) Close() error {
+	if !x.started {
+		if err := x.start(); err != nil {
+			return err
+		}
+	}
+	x.sheet.WriteString(xlsxSheetEnd)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"net/http"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// Codes sent in the extensions of the errors, so clients can tell them
+// apart without parsing the messages.
+const (
+	CodeBadRequest       = "BAD_REQUEST"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeTimeout          = "TIMEOUT"
+	CodeInternal         = "INTERNAL"
+	CodeQueryTooComplex  = "QUERY_TOO_COMPLEX"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+)
+
+// Error is an error returned by a resolver, with its code.
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// statusCode maps the status of the product endpoints to a code.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	return CodeInternal
+}
+
+// requestError is an error of the whole request, reported before running
+// it.
+func requestError(message, code string) gqlerrors.FormattedError {
+	err := gqlerrors.NewFormattedError(message)
+	err.Extensions = map[string]interface{}{"code": code}
+	return err
+}
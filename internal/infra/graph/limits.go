@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// cost measures an operation before it runs.
+type cost struct {
+	// Depth is how deeply the fields are nested; the root fields are at
+	// depth 1.
+	Depth int
+	// Complexity counts the fields resolved, the fields under a paginated
+	// one counting once per item.
+	Complexity int
+}
+
+// measure returns the cost of operation, expanding its fragments.
+// Introspection fields are not counted, as the schema bounds them.
+func measure(doc *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) cost {
+	m := &measurer{fragments: map[string]*ast.FragmentDefinition{}, variables: variables, visiting: map[string]bool{}}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			m.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	depth, complexity := m.selectionSet(operation.SelectionSet, 1)
+	return cost{Depth: depth, Complexity: complexity}
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	// visiting guards against fragments spreading themselves, which
+	// validation rejects anyway
+	visiting map[string]bool
+}
+
+// selectionSet returns the deepest level reached from a set whose fields
+// are at depth, and the complexity of the set.
+func (m *measurer) selectionSet(set *ast.SelectionSet, depth int) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+	maxDepth, complexity := 0, 0
+	add := func(d, c int) {
+		if d > maxDepth {
+			maxDepth = d
+		}
+		complexity += c
+	}
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			d, c := depth, 1
+			if s.SelectionSet != nil {
+				var children int
+				d, children = m.selectionSet(s.SelectionSet, depth+1)
+				c += children * m.multiplier(s)
+			}
+			add(d, c)
+		case *ast.InlineFragment:
+			add(m.selectionSet(s.SelectionSet, depth))
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			fragment, ok := m.fragments[name]
+			if !ok || m.visiting[name] {
+				continue
+			}
+			m.visiting[name] = true
+			add(m.selectionSet(fragment.SelectionSet, depth))
+			m.visiting[name] = false
+		}
+	}
+	return maxDepth, complexity
+}
+
+// paginated are the fields returning a page of items, whose selections
+// are resolved once per item.
+var paginated = map[string]bool{"products": true}
+
+// multiplier is the limit argument of a paginated field, or 1 for the
+// other fields.
+func (m *measurer) multiplier(field *ast.Field) int {
+	if !paginated[field.Name.Value] {
+		return 1
+	}
+	limit := defaultLimit
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			limit, _ = strconv.Atoi(v.Value)
+		case *ast.Variable:
+			switch n := m.variables[v.Name.Value].(type) {
+			case float64:
+				limit = int(n)
+			case int:
+				limit = n
+			}
+		}
+	}
+	if limit < 1 {
+		return 1
+	}
+	if limit > maxLimit {
+		return maxLimit
+	}
+	return limit
+}
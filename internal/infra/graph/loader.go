@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+)
+
+type loaderKey struct{}
+
+// productLoader batches the products loaded by ID during a request. The
+// resolvers queue the IDs and return thunks; the executor calls the thunks
+// once every field of a level was resolved, and the first one fetches the
+// whole batch with one query. Products are cached for the request.
+type productLoader struct {
+	ctx      context.Context
+	products database.ProductInterface
+
+	mu      sync.Mutex
+	pending []string
+	results map[string]*loaded
+}
+
+type loaded struct {
+	done    bool
+	product *entity.Product
+	err     error
+}
+
+func newProductLoader(ctx context.Context, products database.ProductInterface) *productLoader {
+	return &productLoader{ctx: ctx, products: products, results: map[string]*loaded{}}
+}
+
+func withLoader(ctx context.Context, l *productLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+func loaderFromContext(ctx context.Context) *productLoader {
+	l, _ := ctx.Value(loaderKey{}).(*productLoader)
+	return l
+}
+
+// load queues id and returns a thunk returning its product, nil when it
+// does not exist.
+func (l *productLoader) load(id string) func() (*entity.Product, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.results[id]; !ok {
+		l.results[id] = &loaded{}
+		l.pending = append(l.pending, id)
+	}
+	return func() (*entity.Product, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		r := l.results[id]
+		if !r.done {
+			l.fetch()
+		}
+		return r.product, r.err
+	}
+}
+
+// fetch loads the pending IDs. It runs with l locked.
+func (l *productLoader) fetch() {
+	ids := l.pending
+	l.pending = nil
+	products, err := l.products.FindByIDs(l.ctx, ids)
+	found := make(map[string]*entity.Product, len(products))
+	for _, p := range products {
+		found[p.ID.String()] = p
+	}
+	for _, id := range ids {
+		r := l.results[id]
+		r.done = true
+		r.product = found[id]
+		r.err = err
+	}
+}
@@ -0,0 +1,169 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/batch"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Resolver resolves the fields of the schema. The product fields require
+// the same scopes as the product endpoints, and the mutations apply the
+// same rules.
+type Resolver struct {
+	Products database.ProductInterface
+	Users    database.UserInterface
+	// Batch applies the mutations, each as a single batch operation.
+	Batch *batch.Executor
+}
+
+func NewResolver(products database.ProductInterface, users database.UserInterface) *Resolver {
+	return &Resolver{
+		Products: products,
+		Users:    users,
+		Batch:    batch.New(products, 1),
+	}
+}
+
+func requireScope(ctx context.Context, scope string) error {
+	p, ok := middlewares.PrincipalFromContext(ctx)
+	if !ok || !p.HasScope(scope) {
+		return &Error{Message: "missing scope " + scope, Code: CodeForbidden}
+	}
+	return nil
+}
+
+func (r *Resolver) products(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireScope(p.Context, entity.ScopeProductsRead); err != nil {
+		return nil, err
+	}
+	page, _ := p.Args["page"].(int)
+	limit, _ := p.Args["limit"].(int)
+	sort, _ := p.Args["sort"].(string)
+	if page < 1 {
+		return nil, &Error{Message: "page must be positive", Code: CodeBadRequest}
+	}
+	if limit < 1 || limit > maxLimit {
+		return nil, &Error{Message: fmt.Sprintf("limit must be between 1 and %d", maxLimit), Code: CodeBadRequest}
+	}
+
+	var filter database.ProductFilter
+	if f, ok := p.Args["filter"].(map[string]interface{}); ok {
+		filter.Name, _ = f["name"].(string)
+		filter.Category, _ = f["category"].(string)
+		if v, ok := f["minPrice"].(float64); ok {
+			filter.MinPrice = &v
+		}
+		if v, ok := f["maxPrice"].(float64); ok {
+			filter.MaxPrice = &v
+		}
+	}
+
+	products, total, err := r.Products.Search(p.Context, filter, page, limit, sort)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return &productPage{Items: products, Page: page, Limit: limit, Total: total}, nil
+}
+
+// product loads the product through the request loader, so the product
+// fields of a query are fetched with one query.
+func (r *Resolver) product(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireScope(p.Context, entity.ScopeProductsRead); err != nil {
+		return nil, err
+	}
+	id, _ := p.Args["id"].(string)
+	if _, err := entityPkg.ParseID(id); err != nil {
+		return nil, &Error{Message: entity.ErrInvalidID.Error(), Code: CodeBadRequest}
+	}
+	l := loaderFromContext(p.Context)
+	if l == nil {
+		l = newProductLoader(p.Context, r.Products)
+	}
+	thunk := l.load(id)
+	return func() (interface{}, error) {
+		product, err := thunk()
+		if err != nil {
+			return nil, internalError(err)
+		}
+		if product == nil {
+			return nil, nil
+		}
+		return product, nil
+	}, nil
+}
+
+func (r *Resolver) me(p graphql.ResolveParams) (interface{}, error) {
+	principal, ok := middlewares.PrincipalFromContext(p.Context)
+	if !ok {
+		return nil, nil
+	}
+	user, err := r.Users.FindByID(p.Context, principal.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return user, nil
+}
+
+func (r *Resolver) createProduct(p graphql.ResolveParams) (interface{}, error) {
+	return r.apply(p, dto.BatchOperation{Op: batch.OpCreate})
+}
+
+func (r *Resolver) updateProduct(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	return r.apply(p, dto.BatchOperation{Op: batch.OpUpdate, ID: id})
+}
+
+func (r *Resolver) deleteProduct(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	if _, err := r.apply(p, dto.BatchOperation{Op: batch.OpDelete, ID: id}); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// apply runs a mutation as a batch operation, with the product of the
+// input argument.
+func (r *Resolver) apply(p graphql.ResolveParams, op dto.BatchOperation) (interface{}, error) {
+	if err := requireScope(p.Context, entity.ScopeProductsWrite); err != nil {
+		return nil, err
+	}
+	if input, ok := p.Args["input"].(map[string]interface{}); ok {
+		op.Product = &dto.CreateProductInput{}
+		op.Product.Name, _ = input["name"].(string)
+		op.Product.Price, _ = input["price"].(float64)
+		op.Product.SKU, _ = input["sku"].(string)
+		op.Product.Category, _ = input["category"].(string)
+	}
+
+	result := r.Batch.Apply(p.Context, op)
+	if result.Status >= http.StatusBadRequest {
+		message := http.StatusText(result.Status)
+		if out, ok := result.Body.(dto.ErrorOutput); ok {
+			message = out.Message
+		}
+		return nil, &Error{Message: message, Code: statusCode(result.Status)}
+	}
+	return result.Body, nil
+}
+
+// internalError keeps the cause of unexpected failures, such as database
+// errors, out of the response.
+func internalError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Message: "request timed out", Code: CodeTimeout}
+	}
+	return &Error{Message: "internal error", Code: CodeInternal}
+}
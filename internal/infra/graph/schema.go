@@ -0,0 +1,221 @@
+package graph
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// productPage is a page of products and how many match the filter.
+type productPage struct {
+	Items []*entity.Product
+	Page  int
+	Limit int
+	Total int64
+}
+
+// optional resolves empty strings to null.
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+var productType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Product",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*entity.Product).ID.String(), nil
+			},
+		},
+		"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"price": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"sku": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return optional(p.Source.(*entity.Product).SKU), nil
+			},
+		},
+		"category": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return optional(p.Source.(*entity.Product).Category), nil
+			},
+		},
+		"createdAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.DateTime),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*entity.Product).CreatedAt, nil
+			},
+		},
+	},
+})
+
+var productPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ProductPage",
+	Fields: graphql.Fields{
+		"items": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*productPage).Items, nil
+			},
+		},
+		"page": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*productPage).Page, nil
+			},
+		},
+		"limit": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*productPage).Limit, nil
+			},
+		},
+		"total": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*productPage).Total, nil
+			},
+		},
+		"hasNextPage": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				page := p.Source.(*productPage)
+				return int64(page.Page*page.Limit) < page.Total, nil
+			},
+		},
+	},
+})
+
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*entity.User).ID.String(), nil
+			},
+		},
+		"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"email": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"role":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"emailVerified": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*entity.User).EmailVerifiedAt != nil, nil
+			},
+		},
+		"mfaEnabled": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*entity.User).MFAEnabled, nil
+			},
+		},
+	},
+})
+
+var sortOrderType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SortOrder",
+	Values: graphql.EnumValueConfigMap{
+		"ASC":  &graphql.EnumValueConfig{Value: "asc"},
+		"DESC": &graphql.EnumValueConfig{Value: "desc"},
+	},
+})
+
+var productFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ProductFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "part of the name, regardless of case",
+		},
+		"category": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"minPrice": &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		"maxPrice": &graphql.InputObjectFieldConfig{Type: graphql.Float},
+	},
+})
+
+var productInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ProductInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"price":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+		"sku":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"category": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+// NewSchema builds the schema served by r.
+func NewSchema(r *Resolver) (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"products": &graphql.Field{
+				Type:        graphql.NewNonNull(productPageType),
+				Description: "Products matching the filter, ordered by creation date.",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: productFilterType},
+					"page":   &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					"limit": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: defaultLimit,
+						Description:  "at most 100",
+					},
+					"sort": &graphql.ArgumentConfig{Type: sortOrderType, DefaultValue: "asc"},
+				},
+				Resolve: r.products,
+			},
+			"product": &graphql.Field{
+				Type: productType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.product,
+			},
+			"me": &graphql.Field{
+				Type:        userType,
+				Description: "The user of the token; null for clients acting on their own behalf.",
+				Resolve:     r.me,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createProduct": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(productInputType)},
+				},
+				Resolve: r.createProduct,
+			},
+			"updateProduct": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(productInputType)},
+				},
+				Resolve: r.updateProduct,
+			},
+			"deleteProduct": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Deletes a product and returns its ID.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.deleteProduct,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
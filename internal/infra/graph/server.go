@@ -0,0 +1,109 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+)
+
+const (
+	DefaultMaxDepth      = 10
+	DefaultMaxComplexity = 1000
+)
+
+// Request is a GraphQL request, as sent in the body of a POST or in the
+// query string of a GET.
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// Server runs the GraphQL requests, refusing the operations nested deeper
+// than MaxDepth or more complex than MaxComplexity before running them.
+type Server struct {
+	Schema        graphql.Schema
+	Products      database.ProductInterface
+	MaxDepth      int
+	MaxComplexity int
+}
+
+func NewServer(r *Resolver) (*Server, error) {
+	schema, err := NewSchema(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		Schema:        schema,
+		Products:      r.Products,
+		MaxDepth:      DefaultMaxDepth,
+		MaxComplexity: DefaultMaxComplexity,
+	}, nil
+}
+
+// Execute runs req. With readOnly, as for GET requests, mutations are
+// refused.
+func (s *Server) Execute(ctx context.Context, req Request, readOnly bool) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if validation := graphql.ValidateDocument(&s.Schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	if operation := findOperation(doc, req.OperationName); operation != nil {
+		if readOnly && operation.Operation == ast.OperationTypeMutation {
+			return failed(requestError("mutations must be sent with POST", CodeMethodNotAllowed))
+		}
+		c := measure(doc, operation, req.Variables)
+		if s.MaxDepth > 0 && c.Depth > s.MaxDepth {
+			return failed(requestError(fmt.Sprintf("query depth %d exceeds the limit of %d", c.Depth, s.MaxDepth), CodeQueryTooComplex))
+		}
+		if s.MaxComplexity > 0 && c.Complexity > s.MaxComplexity {
+			return failed(requestError(fmt.Sprintf("query complexity %d exceeds the limit of %d", c.Complexity, s.MaxComplexity), CodeQueryTooComplex))
+		}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.Schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoader(ctx, newProductLoader(ctx, s.Products)),
+	})
+}
+
+// findOperation returns the operation to run, or nil when there is none,
+// which the executor reports.
+func findOperation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				// several operations need a name
+				return nil
+			}
+			found = operation
+		} else if operation.Name != nil && operation.Name.Value == name {
+			return operation
+		}
+	}
+	return found
+}
+
+func failed(err gqlerrors.FormattedError) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{err}}
+}
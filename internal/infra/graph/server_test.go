@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingProducts counts the queries loading products by ID.
+type countingProducts struct {
+	database.ProductInterface
+	findByIDs int
+}
+
+func (c *countingProducts) FindByIDs(ctx context.Context, ids []string) ([]*entity.Product, error) {
+	c.findByIDs++
+	return c.ProductInterface.FindByIDs(ctx, ids)
+}
+
+type fixture struct {
+	server   *Server
+	products *countingProducts
+	user     *entity.User
+	ctx      context.Context
+}
+
+func newFixture(t *testing.T) *fixture {
+	db := testutil.NewSQLite(t, &entity.Product{}, &entity.User{})
+
+	user, _ := entity.NewUser("John", "john@example.com", "secret")
+	db.Create(user)
+	products := &countingProducts{ProductInterface: database.NewProduct(db)}
+	server, err := NewServer(NewResolver(products, database.NewUser(db)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := middlewares.WithPrincipal(context.Background(), &middlewares.Principal{
+		UserID:     user.ID.String(),
+		AuthMethod: middlewares.AuthMethodJWT,
+	})
+	return &fixture{server: server, products: products, user: user, ctx: ctx}
+}
+
+func (f *fixture) addProduct(t *testing.T, name string, price float64, category string) *entity.Product {
+	product, _ := entity.NewProduct(name, price)
+	product.Category = category
+	if err := f.products.Create(context.Background(), product); err != nil {
+		t.Fatal(err)
+	}
+	return product
+}
+
+// run executes query and returns its data as JSON.
+func (f *fixture) run(t *testing.T, query string, variables map[string]interface{}) (string, *graphql.Result) {
+	result := f.server.Execute(f.ctx, Request{Query: query, Variables: variables}, false)
+	data, _ := json.Marshal(result.Data)
+	return string(data), result
+}
+
+func errorCode(result *graphql.Result) interface{} {
+	if len(result.Errors) == 0 {
+		return nil
+	}
+	return result.Errors[0].Extensions["code"]
+}
+
+func TestQueryProducts(t *testing.T) {
+	f := newFixture(t)
+	f.addProduct(t, "Cola", 5, "drinks")
+	f.addProduct(t, "Bread", 3, "food")
+	f.addProduct(t, "Diet cola", 6, "drinks")
+
+	data, result := f.run(t, `query($filter: ProductFilter) {
+		products(filter: $filter, limit: 1, sort: DESC) { items { name price category } page limit total hasNextPage }
+	}`, map[string]interface{}{"filter": map[string]interface{}{"name": "cola", "minPrice": 5.0}})
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"products":{"items":[{"name":"Diet cola","price":6,"category":"drinks"}],"page":1,"limit":1,"total":2,"hasNextPage":true}}`, data)
+
+	data, result = f.run(t, `{ products(filter: {category: "food"}) { items { name } total hasNextPage } }`, nil)
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"products":{"items":[{"name":"Bread"}],"total":1,"hasNextPage":false}}`, data)
+
+	_, result = f.run(t, `{ products(limit: 101) { total } }`, nil)
+	assert.Equal(t, CodeBadRequest, errorCode(result))
+}
+
+func TestProductsAreLoadedInOneQuery(t *testing.T) {
+	f := newFixture(t)
+	cola := f.addProduct(t, "Cola", 5, "drinks")
+	bread := f.addProduct(t, "Bread", 3, "food")
+
+	data, result := f.run(t, fmt.Sprintf(`{
+		a: product(id: %q) { name }
+		b: product(id: %q) { name }
+		again: product(id: %q) { price }
+		missing: product(id: "5f0f3b4e-8d3c-4d3a-9b1e-0c6d2f7a1b2c") { name }
+	}`, cola.ID, bread.ID, cola.ID), nil)
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"a":{"name":"Cola"},"b":{"name":"Bread"},"again":{"price":5},"missing":null}`, data)
+	assert.Equal(t, 1, f.products.findByIDs)
+}
+
+func TestQueryMe(t *testing.T) {
+	f := newFixture(t)
+	data, result := f.run(t, `{ me { id name email role emailVerified mfaEnabled } }`, nil)
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, fmt.Sprintf(`{"me":{"id":%q,"name":"John","email":"john@example.com","role":"user","emailVerified":false,"mfaEnabled":false}}`, f.user.ID), data)
+}
+
+func TestProductMutations(t *testing.T) {
+	f := newFixture(t)
+
+	data, result := f.run(t, `mutation { createProduct(input: {name: "Cola", price: 5, sku: "COLA", category: "drinks"}) { id name sku } }`, nil)
+	assert.Empty(t, result.Errors)
+	var created struct {
+		CreateProduct struct{ ID string } `json:"createProduct"`
+	}
+	json.Unmarshal([]byte(data), &created)
+	id := created.CreateProduct.ID
+
+	_, result = f.run(t, `mutation { createProduct(input: {name: "Other", price: 1, sku: "COLA"}) { id } }`, nil)
+	assert.Equal(t, CodeConflict, errorCode(result))
+	_, result = f.run(t, `mutation { createProduct(input: {name: "Free", price: -1}) { id } }`, nil)
+	assert.Equal(t, CodeBadRequest, errorCode(result))
+
+	data, result = f.run(t, `mutation($id: ID!) { updateProduct(id: $id, input: {name: "Cola", price: 6.5}) { price sku } }`,
+		map[string]interface{}{"id": id})
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, `{"updateProduct":{"price":6.5,"sku":null}}`, data)
+
+	data, result = f.run(t, `mutation($id: ID!) { deleteProduct(id: $id) }`, map[string]interface{}{"id": id})
+	assert.Empty(t, result.Errors)
+	assert.JSONEq(t, fmt.Sprintf(`{"deleteProduct":%q}`, id), data)
+	_, result = f.run(t, `mutation($id: ID!) { deleteProduct(id: $id) }`, map[string]interface{}{"id": id})
+	assert.Equal(t, CodeNotFound, errorCode(result))
+
+	result = f.server.Execute(f.ctx, Request{Query: `mutation { deleteProduct(id: "x") }`}, true)
+	assert.Equal(t, CodeMethodNotAllowed, errorCode(result))
+}
+
+func TestScopesAreRequired(t *testing.T) {
+	f := newFixture(t)
+	f.ctx = middlewares.WithPrincipal(context.Background(), &middlewares.Principal{
+		UserID:     f.user.ID.String(),
+		AuthMethod: middlewares.AuthMethodAPIKey,
+		Scopes:     []string{entity.ScopeProductsRead},
+	})
+
+	_, result := f.run(t, `{ products { total } me { name } }`, nil)
+	assert.Empty(t, result.Errors)
+	_, result = f.run(t, `mutation { createProduct(input: {name: "Cola", price: 5}) { id } }`, nil)
+	assert.Equal(t, CodeForbidden, errorCode(result))
+}
+
+func TestQueryLimits(t *testing.T) {
+	f := newFixture(t)
+	f.server.MaxDepth = 3
+	f.server.MaxComplexity = 50
+
+	// introspection is not counted
+	_, result := f.run(t, `{ __schema { types { fields { type { ofType { name } } } } } }`, nil)
+	assert.Empty(t, result.Errors)
+
+	_, result = f.run(t, `{ products { items { name } } }`, nil)
+	assert.Empty(t, result.Errors)
+
+	// 1 + 20 items * (1 + 2 fields) > 50
+	_, result = f.run(t, `{ products { items { id name } } }`, nil)
+	assert.Equal(t, CodeQueryTooComplex, errorCode(result))
+	_, result = f.run(t, `query($limit: Int) { products(limit: $limit) { items { id name } } }`, map[string]interface{}{"limit": 5.0})
+	assert.Empty(t, result.Errors)
+
+	_, result = f.run(t, `fragment page on ProductPage { items { id } } { products(limit: 1) { ...page } }`, nil)
+	assert.Empty(t, result.Errors)
+	f.server.MaxDepth = 2
+	_, result = f.run(t, `fragment page on ProductPage { items { id } } { products(limit: 1) { ...page } }`, nil)
+	assert.Equal(t, CodeQueryTooComplex, errorCode(result))
+}
+
+func TestMeasure(t *testing.T) {
+	doc, err := parser.Parse(parser.ParseParams{Source: `query($n: Int) {
+		me { name }
+		products(limit: $n) { total items { ...product } }
+	}
+	fragment product on Product { id name }`})
+	if !assert.Nil(t, err) {
+		return
+	}
+	operation := findOperation(doc, "")
+
+	// me and name, then products and 2 * (total, items, id and name)
+	assert.Equal(t, cost{Depth: 3, Complexity: 2 + 1 + 2*4}, measure(doc, operation, map[string]interface{}{"n": 2.0}))
+	// the limit defaults to 20
+	assert.Equal(t, cost{Depth: 3, Complexity: 2 + 1 + 20*4}, measure(doc, operation, nil))
+}
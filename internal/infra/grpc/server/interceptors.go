@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/ratelimit"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	authorizationKey = "authorization"
+	apiKeyKey        = "x-api-key"
+)
+
+// Authenticator checks the credentials of the calls the way the REST API
+// does: an "authorization: Bearer <jwt>" or an "x-api-key" metadata entry.
+// The methods of the public services are let through.
+type Authenticator struct {
+	TokenAuth *jwtauth.JWTAuth
+	APIKeys   database.APIKeyInterface
+	Revoked   database.RevokedTokenInterface
+	// Public are the full names of the services that need no credentials.
+	Public map[string]bool
+}
+
+func (a *Authenticator) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authenticator) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if a.Public[serviceName(fullMethod)] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if key := first(md, apiKeyKey); key != "" && a.APIKeys != nil {
+		ctx, err := middlewares.AuthenticateAPIKey(ctx, a.APIKeys, key)
+		if err != nil {
+			return ctx, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return ctx, nil
+	}
+
+	ctx, err := middlewares.AuthenticateToken(ctx, a.TokenAuth, a.Revoked, bearer(first(md, authorizationKey)))
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return ctx, nil
+}
+
+// Logging stores a logger carrying the method and peer in the call context
+// and writes one entry per unary call with its code and latency.
+func Logging(base *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		l := base.With(slog.String("grpc_method", info.FullMethod))
+		if p, ok := peer.FromContext(ctx); ok {
+			l = l.With(slog.String("remote_addr", p.Addr.String()))
+		}
+
+		resp, err := handler(logger.WithContext(ctx, l), req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown {
+			level = slog.LevelError
+		}
+		l.LogAttrs(ctx, level, "call completed",
+			slog.String("code", code.String()),
+			slog.Duration("latency", time.Since(start)),
+		)
+		return resp, err
+	}
+}
+
+// Recover turns a panic in a unary call into an Internal error, so it does
+// not bring down the process.
+func Recover(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.FromContext(ctx).Error("panic in grpc call", "method", info.FullMethod, "panic", rec, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// RecoverStream is Recover for streaming calls.
+func RecoverStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.FromContext(ss.Context()).Error("panic in grpc call", "method", info.FullMethod, "panic", rec, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// RateLimit is the limit of the calls to a service. Name separates its
+// buckets from the other groups, as in middlewares.RateLimit.
+type RateLimit struct {
+	Name  string
+	Limit ratelimit.Limit
+}
+
+// KeyFunc identifies the caller whose bucket a call takes from. An empty key
+// leaves the call out of the limit.
+type KeyFunc func(ctx context.Context) string
+
+// KeyByPeer counts calls per peer IP. It needs no credentials, so it is meant
+// to run before the Authenticator, where calls with bad credentials count too.
+func KeyByPeer(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "ip:" + host
+		}
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:"
+}
+
+// KeyBySubject counts authenticated calls per user, as
+// middlewares.KeyBySubject does, and skips the calls of public services. It
+// must run after the Authenticator.
+func KeyBySubject(ctx context.Context) string {
+	if p, ok := middlewares.PrincipalFromContext(ctx); ok && p.UserID != "" {
+		return "sub:" + p.UserID
+	}
+	return ""
+}
+
+// RateLimiter limits the unary calls of the services in limits, keyed by
+// full service name, with the store the REST API uses. Rejected calls get
+// ResourceExhausted with RetryInfo; when the store fails the call is let
+// through.
+func RateLimiter(store ratelimit.Store, limits map[string]RateLimit, key KeyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		limit, ok := limits[serviceName(info.FullMethod)]
+		if !ok || !limit.Limit.Enabled() {
+			return handler(ctx, req)
+		}
+		k := key(ctx)
+		if k == "" {
+			return handler(ctx, req)
+		}
+
+		res, err := store.Take(ctx, limit.Name+":"+k, limit.Limit, time.Now())
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to check rate limit", "error", err)
+			return handler(ctx, req)
+		}
+		if !res.Allowed {
+			st := status.New(codes.ResourceExhausted, "rate limit exceeded")
+			if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)}); err == nil {
+				st = withInfo
+			}
+			return nil, st.Err()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Deadline bounds each unary call by timeout, as middlewares.Deadline does
+// for the HTTP requests. A shorter deadline set by the client is kept.
+func Deadline(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// serverStream replaces the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// serviceName returns the service of a full method name,
+// "/package.Service/Method".
+func serviceName(fullMethod string) string {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// bearer returns the token of an "authorization: Bearer <token>" value.
+func bearer(value string) string {
+	if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+		return value[7:]
+	}
+	return ""
+}
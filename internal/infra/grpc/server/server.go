@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/grpc/pb"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+type Config struct {
+	TokenAuth *jwtauth.JWTAuth
+	APIKeys   database.APIKeyInterface
+	Revoked   database.RevokedTokenInterface
+	Logger    *slog.Logger
+	// Timeout bounds each unary call; zero means no limit.
+	Timeout time.Duration
+	// Reflection lets clients such as grpcurl list the services.
+	Reflection bool
+	// TLSConfig makes the server accept TLS connections only.
+	TLSConfig *tls.Config
+	// RateLimitStore and RateLimits limit the calls per service, per peer IP
+	// and per user; a nil store disables the limits.
+	RateLimitStore ratelimit.Store
+	RateLimits     map[string]RateLimit
+}
+
+// Server serves the ProductService and AuthService together with the
+// standard health service. It implements lifecycle.Server.
+type Server struct {
+	*grpc.Server
+	Health *health.Server
+}
+
+func New(cfg Config, products pb.ProductServiceServer, auth pb.AuthServiceServer) *Server {
+	authenticator := &Authenticator{
+		TokenAuth: cfg.TokenAuth,
+		APIKeys:   cfg.APIKeys,
+		Revoked:   cfg.Revoked,
+		Public: map[string]bool{
+			pb.AuthService_ServiceDesc.ServiceName:                       true,
+			healthpb.Health_ServiceDesc.ServiceName:                      true,
+			reflectionpb.ServerReflection_ServiceDesc.ServiceName:        true,
+			reflectionv1alphapb.ServerReflection_ServiceDesc.ServiceName: true,
+		},
+	}
+
+	unary := []grpc.UnaryServerInterceptor{
+		Recover,
+		Logging(cfg.Logger),
+		Deadline(cfg.Timeout),
+	}
+	if cfg.RateLimitStore != nil {
+		// every call counts against its peer IP before the credentials are
+		// checked, so guessing them is limited too; authenticated calls then
+		// also count against the buckets of their user
+		unary = append(unary,
+			RateLimiter(cfg.RateLimitStore, cfg.RateLimits, KeyByPeer),
+			authenticator.Unary(),
+			RateLimiter(cfg.RateLimitStore, cfg.RateLimits, KeyBySubject))
+	} else {
+		unary = append(unary, authenticator.Unary())
+	}
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(RecoverStream, authenticator.Stream()),
+	}
+	if cfg.TLSConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg.TLSConfig)))
+	}
+
+	s := &Server{
+		Server: grpc.NewServer(opts...),
+		Health: health.NewServer(),
+	}
+	pb.RegisterProductServiceServer(s.Server, products)
+	pb.RegisterAuthServiceServer(s.Server, auth)
+	healthpb.RegisterHealthServer(s.Server, s.Health)
+	for name := range s.GetServiceInfo() {
+		s.Health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	if cfg.Reflection {
+		reflection.Register(s.Server)
+	}
+	return s
+}
+
+// Shutdown reports the services as not serving, stops accepting calls and
+// waits for the running ones until ctx is done, when they are cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Health.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) Close() error {
+	s.Stop()
+	return nil
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/grpc/pb"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/grpc/service"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/lockout"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/mail"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/ratelimit"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/handlers"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fixture struct {
+	products pb.ProductServiceClient
+	auth     pb.AuthServiceClient
+	health   healthpb.HealthClient
+	users    *handlers.UserHandler
+}
+
+func newFixture(t *testing.T, limits ...map[string]RateLimit) *fixture {
+	db := testutil.NewSQLite(t, &entity.Product{}, &entity.User{}, &entity.UserToken{})
+
+	ja := jwtauth.New("HS256", []byte("secret"), nil)
+	users := handlers.NewUserHandler(database.NewUser(db), database.NewUserToken(db), mail.NewFileMailer(io.Discard, "api@example.com"), ja, 300)
+	cfg := Config{
+		TokenAuth: ja,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Timeout:   5 * time.Second,
+	}
+	if len(limits) > 0 {
+		cfg.RateLimitStore = ratelimit.NewMemoryStore()
+		cfg.RateLimits = limits[0]
+	}
+	s := New(cfg, service.NewProductService(database.NewProduct(db)), service.NewAuthService(users))
+
+	ln := bufconn.Listen(1 << 20)
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Close() })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &fixture{
+		products: pb.NewProductServiceClient(conn),
+		auth:     pb.NewAuthServiceClient(conn),
+		health:   healthpb.NewHealthClient(conn),
+		users:    users,
+	}
+}
+
+// login signs a user up and returns a context sending its token.
+func (f *fixture) login(t *testing.T) context.Context {
+	ctx := context.Background()
+	_, err := f.auth.CreateUser(ctx, &pb.CreateUserRequest{Name: "John", Email: "john@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := f.auth.GenerateToken(ctx, &pb.GenerateTokenRequest{Email: "john@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token.AccessToken)
+}
+
+func TestProductServiceRequiresCredentials(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.products.ListProducts(context.Background(), &pb.ListProductsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+	_, err = f.products.ListProducts(ctx, &pb.ListProductsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestProductService(t *testing.T) {
+	f := newFixture(t)
+	ctx := f.login(t)
+
+	created, err := f.products.CreateProduct(ctx, &pb.CreateProductRequest{Name: "Cola", Price: 5, Sku: "COLA", Category: "drinks"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.NotEmpty(t, created.Id)
+	assert.Equal(t, "drinks", created.Category)
+
+	_, err = f.products.CreateProduct(ctx, &pb.CreateProductRequest{Name: "Other", Price: 1, Sku: "COLA"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = f.products.CreateProduct(ctx, &pb.CreateProductRequest{Name: "Free", Price: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	updated, err := f.products.UpdateProduct(ctx, &pb.UpdateProductRequest{Id: created.Id, Name: "Cola", Price: 6.5})
+	if assert.Nil(t, err) {
+		assert.Equal(t, 6.5, updated.Price)
+		assert.Empty(t, updated.Sku)
+	}
+
+	got, err := f.products.GetProduct(ctx, &pb.GetProductRequest{Id: created.Id})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "Cola", got.Name)
+	}
+
+	list, err := f.products.ListProducts(ctx, &pb.ListProductsRequest{Page: 1, Limit: 10})
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(1), list.Total)
+		assert.Len(t, list.Products, 1)
+	}
+	_, err = f.products.ListProducts(ctx, &pb.ListProductsRequest{Limit: 101})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = f.products.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: created.Id})
+	assert.Nil(t, err)
+	_, err = f.products.GetProduct(ctx, &pb.GetProductRequest{Id: created.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = f.products.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: "invalid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAuthService(t *testing.T) {
+	f := newFixture(t)
+	f.users.LoginGuard = lockout.NewGuard(lockout.NewMemoryStore(),
+		lockout.Policy{Threshold: 1, BaseDelay: time.Minute, MaxDelay: time.Minute, Window: time.Minute},
+		lockout.Policy{Threshold: 100, BaseDelay: time.Minute, MaxDelay: time.Minute, Window: time.Minute},
+	)
+	ctx := context.Background()
+
+	user, err := f.auth.CreateUser(ctx, &pb.CreateUserRequest{Name: "John", Email: "john@example.com", Password: "secret"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "john@example.com", user.Email)
+
+	_, err = f.auth.GenerateToken(ctx, &pb.GenerateTokenRequest{Email: "john@example.com", Password: "wrong"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// the account is locked out after the failed attempt
+	_, err = f.auth.GenerateToken(ctx, &pb.GenerateTokenRequest{Email: "john@example.com", Password: "secret"})
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		assert.IsType(t, &errdetails.RetryInfo{}, st.Details()[0])
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	f := newFixture(t, map[string]RateLimit{
+		pb.AuthService_ServiceDesc.ServiceName:    {Name: "auth", Limit: ratelimit.Limit{Rate: 1.0 / 60, Burst: 3}},
+		pb.ProductService_ServiceDesc.ServiceName: {Name: "products", Limit: ratelimit.Limit{Rate: 1.0 / 60, Burst: 1}},
+	})
+	// signing up and logging in take two of the peer's calls
+	ctx := f.login(t)
+
+	_, err := f.products.ListProducts(ctx, &pb.ListProductsRequest{})
+	assert.Nil(t, err)
+	_, err = f.products.ListProducts(ctx, &pb.ListProductsRequest{})
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		assert.IsType(t, &errdetails.RetryInfo{}, st.Details()[0])
+	}
+
+	// the public service has its own buckets
+	_, err = f.auth.GenerateToken(context.Background(), &pb.GenerateTokenRequest{Email: "john@example.com", Password: "secret"})
+	assert.Nil(t, err)
+	_, err = f.auth.GenerateToken(context.Background(), &pb.GenerateTokenRequest{Email: "john@example.com", Password: "secret"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// health is not limited
+	_, err = f.health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+}
+
+func TestRateLimitCountsFailedAuthentication(t *testing.T) {
+	f := newFixture(t, map[string]RateLimit{
+		pb.ProductService_ServiceDesc.ServiceName: {Name: "products", Limit: ratelimit.Limit{Rate: 1.0 / 60, Burst: 2}},
+	})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+
+	for i := 0; i < 2; i++ {
+		_, err := f.products.ListProducts(ctx, &pb.ListProductsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+	_, err := f.products.ListProducts(ctx, &pb.ListProductsRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestRecoverStream(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: "/pb.ProductService/Watch"}
+	err := RecoverStream(nil, &serverStream{ctx: context.Background()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		panic("boom")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestHealth(t *testing.T) {
+	f := newFixture(t)
+
+	res, err := f.health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: pb.ProductService_ServiceDesc.ServiceName})
+	if assert.Nil(t, err) {
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+	}
+}
+
+func TestBearer(t *testing.T) {
+	assert.Equal(t, "abc", bearer("Bearer abc"))
+	assert.Equal(t, "abc", bearer("bearer abc"))
+	assert.Equal(t, "", bearer("Basic abc"))
+	assert.Equal(t, "", bearer(""))
+	assert.Equal(t, "pb.ProductService", serviceName("/pb.ProductService/GetProduct"))
+}
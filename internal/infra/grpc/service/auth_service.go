@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/grpc/pb"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/handlers"
+	"google.golang.org/grpc/peer"
+)
+
+// AuthService signs users up and logs them in over gRPC through the
+// UserHandler, so the login lockout, metrics and MFA apply as on the REST
+// endpoints.
+type AuthService struct {
+	pb.UnimplementedAuthServiceServer
+	Users *handlers.UserHandler
+}
+
+func NewAuthService(users *handlers.UserHandler) *AuthService {
+	return &AuthService{Users: users}
+}
+
+func (s *AuthService) CreateUser(ctx context.Context, in *pb.CreateUserRequest) (*pb.User, error) {
+	u, err := s.Users.Register(ctx, dto.CreateUserInput{
+		Name:     in.Name,
+		Email:    in.Email,
+		Password: in.Password,
+	})
+	if err != nil {
+		return nil, fromStatusError(err)
+	}
+	return &pb.User{Id: u.ID.String(), Name: u.Name, Email: u.Email}, nil
+}
+
+func (s *AuthService) GenerateToken(ctx context.Context, in *pb.GenerateTokenRequest) (*pb.TokenResponse, error) {
+	output, err := s.Users.Login(ctx, in.Email, in.Password, peerIP(ctx))
+	if err != nil {
+		return nil, fromStatusError(err)
+	}
+	return newTokenResponse(output), nil
+}
+
+func (s *AuthService) VerifyMFA(ctx context.Context, in *pb.VerifyMFARequest) (*pb.TokenResponse, error) {
+	output, err := s.Users.VerifyMFALogin(ctx, in.MfaToken, in.Code, in.RecoveryCode, peerIP(ctx))
+	if err != nil {
+		return nil, fromStatusError(err)
+	}
+	return newTokenResponse(output), nil
+}
+
+func newTokenResponse(output dto.GetJWTOutput) *pb.TokenResponse {
+	return &pb.TokenResponse{
+		AccessToken: output.AccessToken,
+		MfaRequired: output.MFARequired,
+		MfaToken:    output.MFAToken,
+	}
+}
+
+func fromStatusError(err error) error {
+	var statusErr *handlers.StatusError
+	if errors.As(err, &statusErr) {
+		return statusError(statusErr.Status, statusErr.Error(), statusErr.RetryAfter)
+	}
+	return internalError(err)
+}
+
+// peerIP is the address the login lockout counts the attempts of.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
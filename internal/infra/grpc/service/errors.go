@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// statusCode maps the status of the REST endpoints to a gRPC code.
+func statusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// statusError is the gRPC error for a call that failed with httpStatus. The
+// message of internal errors, such as database errors, is not sent.
+// retryAfter is sent as RetryInfo.
+func statusError(httpStatus int, message string, retryAfter time.Duration) error {
+	code := statusCode(httpStatus)
+	if code == codes.Internal {
+		message = "internal error"
+	}
+	st := status.New(code, message)
+	if retryAfter > 0 {
+		if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+			st = withInfo
+		}
+	}
+	return st.Err()
+}
+
+// internalError hides the cause of unexpected failures.
+func internalError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func requireScope(ctx context.Context, scope string) error {
+	p, ok := middlewares.PrincipalFromContext(ctx)
+	if !ok || !p.HasScope(scope) {
+		return status.Error(codes.PermissionDenied, "missing scope "+scope)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/batch"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/grpc/pb"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+)
+
+// ProductService serves the products over gRPC with the scopes and rules of
+// the /products endpoints.
+type ProductService struct {
+	pb.UnimplementedProductServiceServer
+	ProductDB database.ProductInterface
+	// Batch applies the writes, each as a single batch operation.
+	Batch *batch.Executor
+}
+
+func NewProductService(db database.ProductInterface) *ProductService {
+	return &ProductService{
+		ProductDB: db,
+		Batch:     batch.New(db, 1),
+	}
+}
+
+func (s *ProductService) CreateProduct(ctx context.Context, in *pb.CreateProductRequest) (*pb.Product, error) {
+	return s.apply(ctx, dto.BatchOperation{
+		Op: batch.OpCreate,
+		Product: &dto.CreateProductInput{
+			Name:     in.Name,
+			Price:    in.Price,
+			SKU:      in.Sku,
+			Category: in.Category,
+		},
+	})
+}
+
+func (s *ProductService) GetProduct(ctx context.Context, in *pb.GetProductRequest) (*pb.Product, error) {
+	if err := requireScope(ctx, entity.ScopeProductsRead); err != nil {
+		return nil, err
+	}
+	if _, err := entityPkg.ParseID(in.Id); err != nil {
+		return nil, status.Error(codes.InvalidArgument, entity.ErrInvalidID.Error())
+	}
+	product, err := s.ProductDB.FindByID(ctx, in.Id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, status.Error(codes.NotFound, "product not found")
+	}
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return newProduct(product), nil
+}
+
+func (s *ProductService) ListProducts(ctx context.Context, in *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	if err := requireScope(ctx, entity.ScopeProductsRead); err != nil {
+		return nil, err
+	}
+	page, limit := int(in.Page), int(in.Limit)
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if page < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page must be positive")
+	}
+	if limit < 0 || limit > maxLimit {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+	}
+
+	products, total, err := s.ProductDB.Search(ctx, database.ProductFilter{}, page, limit, in.Sort)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	out := &pb.ListProductsResponse{Total: total}
+	for _, p := range products {
+		out.Products = append(out.Products, newProduct(p))
+	}
+	return out, nil
+}
+
+func (s *ProductService) UpdateProduct(ctx context.Context, in *pb.UpdateProductRequest) (*pb.Product, error) {
+	return s.apply(ctx, dto.BatchOperation{
+		Op: batch.OpUpdate,
+		ID: in.Id,
+		Product: &dto.CreateProductInput{
+			Name:     in.Name,
+			Price:    in.Price,
+			SKU:      in.Sku,
+			Category: in.Category,
+		},
+	})
+}
+
+func (s *ProductService) DeleteProduct(ctx context.Context, in *pb.DeleteProductRequest) (*pb.DeleteProductResponse, error) {
+	if _, err := s.apply(ctx, dto.BatchOperation{Op: batch.OpDelete, ID: in.Id}); err != nil {
+		return nil, err
+	}
+	return &pb.DeleteProductResponse{}, nil
+}
+
+// apply runs a write as a batch operation, so it follows the rules of the
+// REST endpoints.
+func (s *ProductService) apply(ctx context.Context, op dto.BatchOperation) (*pb.Product, error) {
+	if err := requireScope(ctx, entity.ScopeProductsWrite); err != nil {
+		return nil, err
+	}
+	result := s.Batch.Apply(ctx, op)
+	if result.Status >= http.StatusBadRequest {
+		message := http.StatusText(result.Status)
+		if out, ok := result.Body.(dto.ErrorOutput); ok {
+			message = out.Message
+		}
+		return nil, statusError(result.Status, message, 0)
+	}
+	if product, ok := result.Body.(*entity.Product); ok {
+		return newProduct(product), nil
+	}
+	return nil, nil
+}
+
+func newProduct(p *entity.Product) *pb.Product {
+	return &pb.Product{
+		Id:        p.ID.String(),
+		Name:      p.Name,
+		Price:     p.Price,
+		Sku:       p.SKU,
+		Category:  p.Category,
+		CreatedAt: timestamppb.New(p.CreatedAt),
+	}
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// DBPing checks that a connection to the database can be established.
+func DBPing(db *gorm.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// Migrations checks that the tables of models exist.
+func Migrations(db *gorm.DB, models ...interface{}) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		migrator := db.WithContext(ctx).Migrator()
+		for _, model := range models {
+			if !migrator.HasTable(model) {
+				stmt := &gorm.Statement{DB: db}
+				if err := stmt.Parse(model); err != nil {
+					return err
+				}
+				return fmt.Errorf("table %s is missing", stmt.Schema.Table)
+			}
+		}
+		return nil
+	})
+}
+
+// Config reports the error returned by validate, such as a configuration
+// validation function.
+func Config(validate func() error) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return validate()
+	})
+}
@@ -0,0 +1,169 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Checker reports whether a dependency is usable. Check must honor the
+// context deadline.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type CheckResult struct {
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type check struct {
+	name    string
+	checker Checker
+	timeout time.Duration
+}
+
+// Registry holds the readiness checks. Checks run concurrently, each with its
+// own timeout, so one slow dependency does not hide the state of the others.
+type Registry struct {
+	mu             sync.RWMutex
+	checks         []check
+	defaultTimeout time.Duration
+	shuttingDown   atomic.Bool
+}
+
+func NewRegistry(defaultTimeout time.Duration) *Registry {
+	return &Registry{defaultTimeout: defaultTimeout}
+}
+
+// Register adds a readiness check. A zero timeout uses the registry default.
+func (r *Registry) Register(name string, c Checker, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = r.defaultTimeout
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check{name: name, checker: c, timeout: timeout})
+}
+
+// SetShuttingDown makes readiness fail so load balancers stop sending new
+// requests while the server drains.
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Run executes every check and aggregates the results.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make([]check, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	if r.ShuttingDown() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, c check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:     StatusOK,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler answers 200 as long as the process can serve HTTP. It
+// never checks dependencies, so a database outage does not get the process
+// restarted.
+func (r *Registry) LivenessHandler(w http.ResponseWriter, req *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// ReadinessHandler answers 200 when every check passes and 503 otherwise,
+// including while the server is shutting down.
+func (r *Registry) ReadinessHandler(w http.ResponseWriter, req *http.Request) {
+	if r.ShuttingDown() {
+		writeReport(w, http.StatusServiceUnavailable, Report{Status: StatusShuttingDown})
+		return
+	}
+
+	report := r.Run(req.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type table struct {
+	ID int
+}
+
+type missing struct {
+	ID int
+}
+
+func readiness(t *testing.T, r *Registry) (int, Report) {
+	rec := httptest.NewRecorder()
+	r.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report Report
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&report))
+	return rec.Code, report
+}
+
+func TestReadinessAllChecksPass(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("a", CheckerFunc(func(ctx context.Context) error { return nil }), 0)
+	r.Register("b", CheckerFunc(func(ctx context.Context) error { return nil }), 0)
+
+	code, report := readiness(t, r)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["a"].Status)
+	assert.Equal(t, StatusOK, report.Checks["b"].Status)
+}
+
+func TestReadinessReportsFailingCheck(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("ok", CheckerFunc(func(ctx context.Context) error { return nil }), 0)
+	r.Register("broken", CheckerFunc(func(ctx context.Context) error { return errors.New("boom") }), 0)
+
+	code, report := readiness(t, r)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusOK, report.Checks["ok"].Status)
+	assert.Equal(t, "boom", report.Checks["broken"].Error)
+}
+
+func TestReadinessTimesOutSlowCheck(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}), 20*time.Millisecond)
+
+	start := time.Now()
+	code, report := readiness(t, r)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+}
+
+func TestReadinessFailsWhileShuttingDown(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("ok", CheckerFunc(func(ctx context.Context) error { return nil }), 0)
+	r.SetShuttingDown()
+
+	code, report := readiness(t, r)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusShuttingDown, report.Status)
+}
+
+func TestLivenessIgnoresChecks(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("broken", CheckerFunc(func(ctx context.Context) error { return errors.New("boom") }), 0)
+
+	rec := httptest.NewRecorder()
+	r.LivenessHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestDatabaseChecks(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&table{})
+
+	ctx := context.Background()
+	assert.Nil(t, DBPing(db).Check(ctx))
+	assert.Nil(t, Migrations(db, &table{}).Check(ctx))
+	assert.EqualError(t, Migrations(db, &table{}, &missing{}).Check(ctx), "table missings is missing")
+}
@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+)
+
+const DefaultBatchSize = 500
+
+// BatchStore stores a batch of products in one transaction.
+type BatchStore interface {
+	ImportBatch(ctx context.Context, products []*entity.Product, upsert, dryRun bool) (database.ImportBatchResult, error)
+}
+
+// Importer validates the rows of an import file and stores the valid ones
+// in batches, one transaction per batch.
+type Importer struct {
+	Products  BatchStore
+	BatchSize int
+}
+
+func New(products BatchStore, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Importer{
+		Products:  products,
+		BatchSize: batchSize,
+	}
+}
+
+type pendingRow struct {
+	line    int
+	product *entity.Product
+}
+
+// Run imports every row of rows into report, whose DryRun and Upsert fields
+// select the mode. Invalid rows are recorded in the report and skipped.
+// progress, when not nil, is called after each batch. An error is returned
+// only when the import could not go on, e.g. the file is unreadable or the
+// database failed; batches stored before it are kept.
+func (i *Importer) Run(ctx context.Context, rows RowReader, report *entity.ImportReport, progress func(*entity.ImportReport)) error {
+	if report.Errors == nil {
+		report.Errors = []entity.ImportRowError{}
+	}
+	// SKUs must be unique, so a SKU seen twice in the file is rejected
+	// instead of failing the whole batch on the unique index
+	seen := make(map[string]int)
+	batch := make([]pendingRow, 0, i.BatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		products := make([]*entity.Product, len(batch))
+		for j, row := range batch {
+			products[j] = row.product
+		}
+		result, err := i.Products.ImportBatch(ctx, products, report.Upsert, report.DryRun)
+		if err != nil {
+			return fmt.Errorf("import rows %d to %d: %w", batch[0].line, batch[len(batch)-1].line, err)
+		}
+		report.Created += result.Created
+		report.Updated += result.Updated
+		for _, j := range result.Conflicts {
+			report.AddError(batch[j].line, batch[j].product.SKU, entity.ErrSKUAlreadyUsed)
+		}
+		batch = batch[:0]
+		if progress != nil {
+			progress(report)
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		report.Processed++
+
+		product, err := validate(row)
+		if err != nil {
+			report.AddError(row.Line, row.SKU, err)
+			continue
+		}
+		if product.SKU != "" {
+			if line, ok := seen[product.SKU]; ok {
+				report.AddError(row.Line, row.SKU, fmt.Errorf("sku already used on line %d", line))
+				continue
+			}
+			seen[product.SKU] = row.Line
+		}
+
+		batch = append(batch, pendingRow{line: row.Line, product: product})
+		if len(batch) == i.BatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		return flush()
+	}
+	// the last rows were all rejected, so no batch reported them
+	if progress != nil {
+		progress(report)
+	}
+	return nil
+}
+
+func validate(row Row) (*entity.Product, error) {
+	if row.Err != nil {
+		return nil, row.Err
+	}
+	product, err := entity.NewProduct(row.Name, row.Price)
+	if err != nil {
+		return nil, err
+	}
+	product.SKU = row.SKU
+	product.Category = row.Category
+	if err := product.Validate(); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	return testutil.NewSQLite(t, &entity.Product{}, &entity.ImportJob{})
+}
+
+func readAll(t *testing.T, r RowReader) []Row {
+	var rows []Row
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			return rows
+		}
+		if !assert.Nil(t, err) {
+			return rows
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	r, err := NewCSVReader(strings.NewReader("SKU,Price,Name\nA-1,10.5,Product 1\n,abc,Product 2\n\"B-2\",3,\"Product, 3\"\n"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	rows := readAll(t, r)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, Row{Line: 2, Name: "Product 1", Price: 10.5, SKU: "A-1"}, rows[0])
+	assert.Equal(t, 3, rows[1].Line)
+	assert.NotNil(t, rows[1].Err)
+	assert.Equal(t, Row{Line: 4, Name: "Product, 3", Price: 3, SKU: "B-2"}, rows[2])
+}
+
+func TestCSVReaderReadsCategory(t *testing.T) {
+	r, err := NewCSVReader(strings.NewReader("name,price,category\nProduct 1,10,drinks\nProduct 2,2,\n"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	rows := readAll(t, r)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, Row{Line: 2, Name: "Product 1", Price: 10, Category: "drinks"}, rows[0])
+	assert.Equal(t, Row{Line: 3, Name: "Product 2", Price: 2}, rows[1])
+}
+
+func TestCSVReaderRequiresHeader(t *testing.T) {
+	_, err := NewCSVReader(strings.NewReader("name,sku\nProduct 1,A-1\n"))
+	assert.NotNil(t, err)
+
+	_, err = NewCSVReader(strings.NewReader(""))
+	assert.NotNil(t, err)
+}
+
+func TestNDJSONReader(t *testing.T) {
+	r := NewNDJSONReader(strings.NewReader("{\"name\":\"Product 1\",\"price\":10,\"sku\":\"A-1\"}\n\n{invalid\n{\"name\":\"Product 2\",\"price\":2}\n"))
+	rows := readAll(t, r)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, Row{Line: 1, Name: "Product 1", Price: 10, SKU: "A-1"}, rows[0])
+	assert.Equal(t, 3, rows[1].Line)
+	assert.NotNil(t, rows[1].Err)
+	assert.Equal(t, Row{Line: 4, Name: "Product 2", Price: 2}, rows[2])
+}
+
+const importCSV = `name,price,sku
+Product 1,10,A-1
+,5,A-2
+Product 3,-1,A-3
+Product 4,4,A-1
+Product 5,5,
+Product 6,6,A-6
+`
+
+func TestImporterRun(t *testing.T) {
+	db := newTestDB(t)
+	productDB := database.NewProduct(db)
+	existing, _ := entity.NewProduct("Existing", 1)
+	existing.SKU = "A-6"
+	productDB.Create(context.Background(), existing)
+
+	rows, _ := NewCSVReader(strings.NewReader(importCSV))
+	report := &entity.ImportReport{}
+	var batches int
+	err := New(productDB, 2).Run(context.Background(), rows, report, func(*entity.ImportReport) { batches++ })
+	assert.Nil(t, err)
+
+	assert.Equal(t, 6, report.Processed)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 0, report.Updated)
+	assert.Equal(t, 4, report.Failed)
+	assert.Equal(t, []entity.ImportRowError{
+		{Line: 3, SKU: "A-2", Error: entity.ErrNameIsRequired.Error()},
+		{Line: 4, SKU: "A-3", Error: entity.ErrInvalidPrice.Error()},
+		{Line: 5, SKU: "A-1", Error: "sku already used on line 2"},
+		{Line: 7, SKU: "A-6", Error: entity.ErrSKUAlreadyUsed.Error()},
+	}, report.Errors)
+	assert.Equal(t, 2, batches)
+
+	var count int64
+	db.Model(&entity.Product{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestImporterUpsert(t *testing.T) {
+	db := newTestDB(t)
+	productDB := database.NewProduct(db)
+	existing, _ := entity.NewProduct("Existing", 1)
+	existing.SKU = "A-6"
+	productDB.Create(context.Background(), existing)
+
+	rows, _ := NewCSVReader(strings.NewReader(importCSV))
+	report := &entity.ImportReport{Upsert: true}
+	assert.Nil(t, New(productDB, 10).Run(context.Background(), rows, report, nil))
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Updated)
+
+	found, _ := productDB.FindBySKU(context.Background(), "A-6")
+	assert.Equal(t, "Product 6", found.Name)
+	assert.Equal(t, existing.ID, found.ID)
+}
+
+func TestImporterDryRun(t *testing.T) {
+	db := newTestDB(t)
+	productDB := database.NewProduct(db)
+
+	rows, _ := NewCSVReader(strings.NewReader(importCSV))
+	report := &entity.ImportReport{DryRun: true}
+	assert.Nil(t, New(productDB, 2).Run(context.Background(), rows, report, nil))
+	assert.Equal(t, 3, report.Created)
+	assert.Equal(t, 3, report.Failed)
+
+	var count int64
+	db.Model(&entity.Product{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+type failingStore struct{}
+
+func (failingStore) ImportBatch(context.Context, []*entity.Product, bool, bool) (database.ImportBatchResult, error) {
+	return database.ImportBatchResult{}, errors.New("database is down")
+}
+
+func TestImporterStopsOnStoreError(t *testing.T) {
+	rows, _ := NewCSVReader(strings.NewReader(importCSV))
+	err := New(failingStore{}, 2).Run(context.Background(), rows, &entity.ImportReport{}, nil)
+	assert.ErrorContains(t, err, "database is down")
+}
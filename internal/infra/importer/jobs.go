@@ -0,0 +1,182 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+)
+
+var ErrQueueFull = errors.New("too many imports waiting, try again later")
+
+// Runner runs imports in the background. The uploaded file is kept in Dir
+// until its job finishes, and the job is stored so its owner can poll the
+// progress.
+type Runner struct {
+	Importer *Importer
+	Jobs     database.ImportJobInterface
+	Dir      string
+	Workers  int
+
+	queue chan string
+}
+
+func NewRunner(importer *Importer, jobs database.ImportJobInterface, dir string, workers, queueSize int) (*Runner, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Runner{
+		Importer: importer,
+		Jobs:     jobs,
+		Dir:      dir,
+		Workers:  workers,
+		queue:    make(chan string, queueSize),
+	}, nil
+}
+
+// Recover fails the jobs a previous process left unfinished and removes
+// their files. It must run before jobs are submitted.
+func (r *Runner) Recover(ctx context.Context) error {
+	if err := r.Jobs.FailUnfinished(ctx, "import interrupted by a server restart"); err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(r.Dir, "*.import"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		os.Remove(f)
+	}
+	return nil
+}
+
+// Submit stores job and copies the file from src, then queues the job.
+func (r *Runner) Submit(ctx context.Context, job *entity.ImportJob, src io.Reader) error {
+	path := r.path(job)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	if err := r.Jobs.Create(ctx, job); err != nil {
+		os.Remove(path)
+		return err
+	}
+	select {
+	case r.queue <- job.ID.String():
+		return nil
+	default:
+		os.Remove(path)
+		job.Finish(ErrQueueFull)
+		r.Jobs.Update(context.WithoutCancel(ctx), job)
+		return ErrQueueFull
+	}
+}
+
+// Run processes queued jobs with Workers goroutines until ctx is cancelled.
+// Jobs still queued then stay pending and are failed by the next Recover.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-r.queue:
+					r.process(ctx, id)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Runner) process(ctx context.Context, id string) {
+	log := slog.Default().With("import_job", id)
+	job, err := r.Jobs.FindByID(ctx, id)
+	if err != nil {
+		log.Error("failed to load import job", "error", err)
+		return
+	}
+	path := r.path(job)
+	defer os.Remove(path)
+
+	err = r.run(ctx, job, path)
+	job.Finish(err)
+	// the final state is saved even when shutting down
+	if err := r.Jobs.Update(context.WithoutCancel(ctx), job); err != nil {
+		log.Error("failed to save import job", "error", err)
+		return
+	}
+	log.Info("import finished", "status", job.Status, "processed", job.Report.Processed,
+		"created", job.Report.Created, "updated", job.Report.Updated, "failed", job.Report.Failed)
+}
+
+func (r *Runner) run(ctx context.Context, job *entity.ImportJob, path string) error {
+	total, err := r.count(job, path)
+	if err != nil {
+		return err
+	}
+	job.Start(total)
+	if err := r.Jobs.Update(ctx, job); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, err := NewReader(job.Format, f)
+	if err != nil {
+		return err
+	}
+	return r.Importer.Run(ctx, rows, &job.Report, func(*entity.ImportReport) {
+		if err := r.Jobs.Update(ctx, job); err != nil {
+			slog.Error("failed to save import progress", "import_job", job.ID.String(), "error", err)
+		}
+	})
+}
+
+// count reads the file once so the progress can be shown as a fraction.
+func (r *Runner) count(job *entity.ImportJob, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	rows, err := NewReader(job.Format, f)
+	if err != nil {
+		return 0, err
+	}
+	total, err := Count(rows)
+	if err != nil {
+		return 0, fmt.Errorf("read import file: %w", err)
+	}
+	return total, nil
+}
+
+func (r *Runner) path(job *entity.ImportJob) string {
+	return filepath.Join(r.Dir, job.ID.String()+".import")
+}
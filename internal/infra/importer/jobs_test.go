@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerProcessesSubmittedJob(t *testing.T) {
+	db := newTestDB(t)
+	jobDB := database.NewImportJob(db)
+	dir := t.TempDir()
+	runner, err := NewRunner(New(database.NewProduct(db), 2), jobDB, dir, 1, 10)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	job, _ := entity.NewImportJob("user-1", entity.ImportFormatCSV, false, false)
+	assert.Nil(t, runner.Submit(context.Background(), job, strings.NewReader(importCSV)))
+
+	var found *entity.ImportJob
+	assert.Eventually(t, func() bool {
+		found, _ = jobDB.FindByID(context.Background(), job.ID.String())
+		return found != nil && found.IsFinished()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, entity.ImportStatusCompleted, found.Status)
+	assert.Equal(t, 6, found.Total)
+	assert.Equal(t, 6, found.Report.Processed)
+	assert.Equal(t, 3, found.Report.Created)
+	assert.Equal(t, 3, found.Report.Failed)
+
+	// the uploaded file is removed once the job finished
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, files)
+}
+
+func TestRunnerRecover(t *testing.T) {
+	db := newTestDB(t)
+	jobDB := database.NewImportJob(db)
+	dir := t.TempDir()
+	runner, _ := NewRunner(New(database.NewProduct(db), 2), jobDB, dir, 1, 10)
+
+	job, _ := entity.NewImportJob("user-1", entity.ImportFormatCSV, false, false)
+	assert.Nil(t, runner.Submit(context.Background(), job, strings.NewReader(importCSV)))
+
+	assert.Nil(t, runner.Recover(context.Background()))
+	found, _ := jobDB.FindByID(context.Background(), job.ID.String())
+	assert.Equal(t, entity.ImportStatusFailed, found.Status)
+	_, err := os.Stat(runner.path(job))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunnerRejectsWhenQueueIsFull(t *testing.T) {
+	db := newTestDB(t)
+	jobDB := database.NewImportJob(db)
+	runner, _ := NewRunner(New(database.NewProduct(db), 2), jobDB, t.TempDir(), 1, 1)
+
+	first, _ := entity.NewImportJob("user-1", entity.ImportFormatCSV, false, false)
+	assert.Nil(t, runner.Submit(context.Background(), first, strings.NewReader(importCSV)))
+
+	second, _ := entity.NewImportJob("user-1", entity.ImportFormatCSV, false, false)
+	assert.Equal(t, ErrQueueFull, runner.Submit(context.Background(), second, strings.NewReader(importCSV)))
+	found, _ := jobDB.FindByID(context.Background(), second.ID.String())
+	assert.Equal(t, entity.ImportStatusFailed, found.Status)
+}
@@ -0,0 +1,178 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+)
+
+// maxNDJSONLine bounds a single NDJSON line, so a file without line breaks
+// cannot exhaust the memory.
+const maxNDJSONLine = 1 << 20
+
+// Row is a product read from an import file. Err is set when the row could
+// not be parsed; the import goes on with the next row.
+type Row struct {
+	Line     int
+	Name     string
+	Price    float64
+	SKU      string
+	Category string
+	Err      error
+}
+
+// RowReader returns the rows of an import file one at a time, and io.EOF
+// after the last one.
+type RowReader interface {
+	Next() (Row, error)
+}
+
+// NewReader returns a RowReader for an import file in format.
+func NewReader(format string, r io.Reader) (RowReader, error) {
+	switch format {
+	case entity.ImportFormatCSV:
+		return NewCSVReader(r)
+	case entity.ImportFormatNDJSON:
+		return NewNDJSONReader(r), nil
+	}
+	return nil, entity.ErrInvalidImportFormat
+}
+
+// CSVReader reads products from CSV with a header row naming the name,
+// price and, optionally, sku and category columns in any order.
+type CSVReader struct {
+	r        *csv.Reader
+	name     int
+	price    int
+	sku      int
+	category int
+}
+
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	reader := &CSVReader{r: cr, name: -1, price: -1, sku: -1, category: -1}
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))) {
+		case "name":
+			reader.name = i
+		case "price":
+			reader.price = i
+		case "sku":
+			reader.sku = i
+		case "category":
+			reader.category = i
+		}
+	}
+	if reader.name < 0 || reader.price < 0 {
+		return nil, errors.New("csv header must have name and price columns")
+	}
+	return reader, nil
+}
+
+func (c *CSVReader) Next() (Row, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Row{Line: parseErr.StartLine, Err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	row := Row{Line: line, Name: field(record, c.name), SKU: field(record, c.sku), Category: field(record, c.category)}
+	if price := field(record, c.price); price != "" {
+		row.Price, err = strconv.ParseFloat(price, 64)
+		if err != nil {
+			row.Err = fmt.Errorf("invalid price %q", price)
+		}
+	}
+	return row, nil
+}
+
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// NDJSONReader reads one product object per line. Blank lines are skipped.
+type NDJSONReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+	return &NDJSONReader{s: s}
+}
+
+type ndjsonProduct struct {
+	Name     string  `json:"name"`
+	Price    float64 `json:"price"`
+	SKU      string  `json:"sku"`
+	Category string  `json:"category"`
+}
+
+func (n *NDJSONReader) Next() (Row, error) {
+	for n.s.Scan() {
+		n.line++
+		line := strings.TrimSpace(n.s.Text())
+		if line == "" {
+			continue
+		}
+		var p ndjsonProduct
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			return Row{Line: n.line, Err: fmt.Errorf("invalid json: %w", err)}, nil
+		}
+		return Row{
+			Line:     n.line,
+			Name:     strings.TrimSpace(p.Name),
+			Price:    p.Price,
+			SKU:      strings.TrimSpace(p.SKU),
+			Category: strings.TrimSpace(p.Category),
+		}, nil
+	}
+	if err := n.s.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
+
+// Count returns how many rows r holds.
+func Count(r RowReader) (int, error) {
+	count := 0
+	for {
+		if _, err := r.Next(); err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return count, err
+		}
+		count++
+	}
+}
@@ -0,0 +1,308 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Timeouts bounds how long a client may take on each phase of a request so
+// slow or idle connections cannot pin the server resources.
+type Timeouts struct {
+	Read       time.Duration
+	ReadHeader time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// NewHTTPServer builds an http.Server listening on addr with timeouts applied.
+func NewHTTPServer(addr string, handler http.Handler, t Timeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       t.Read,
+		ReadHeaderTimeout: t.ReadHeader,
+		WriteTimeout:      t.Write,
+		IdleTimeout:       t.Idle,
+	}
+}
+
+// Server is a non-HTTP server run by Lifecycle, such as the gRPC server.
+// Shutdown stops accepting calls and waits for the running ones until ctx is
+// done; Close stops right away.
+type Server interface {
+	Serve(ln net.Listener) error
+	Shutdown(ctx context.Context) error
+	Close() error
+}
+
+type namedServer struct {
+	name string
+	// server is nil for the servers added with AddOther, which listen on
+	// addr
+	server   *http.Server
+	other    Server
+	addr     string
+	listener net.Listener
+}
+
+func (s *namedServer) address() string {
+	if s.server != nil {
+		return s.server.Addr
+	}
+	return s.addr
+}
+
+func (s *namedServer) serve() error {
+	switch {
+	case s.other != nil:
+		return s.other.Serve(s.listener)
+	case s.server.TLSConfig != nil:
+		// the certificates come from TLSConfig
+		return s.server.ServeTLS(s.listener, "", "")
+	default:
+		return s.server.Serve(s.listener)
+	}
+}
+
+func (s *namedServer) shutdown(ctx context.Context) error {
+	if s.other != nil {
+		return s.other.Shutdown(ctx)
+	}
+	return s.server.Shutdown(ctx)
+}
+
+func (s *namedServer) close() error {
+	if s.other != nil {
+		return s.other.Close()
+	}
+	return s.server.Close()
+}
+
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// Lifecycle runs the servers and background workers of the process and
+// stops them in order when a termination signal arrives:
+//
+//  1. the OnShutdown hooks run, e.g. to fail readiness checks;
+//  2. it waits DrainPeriod so load balancers stop routing new requests;
+//  3. the servers stop accepting connections and in-flight requests finish,
+//     bounded by ShutdownTimeout;
+//  4. the workers are cancelled and awaited;
+//  5. the closers run in reverse registration order, bounded by another
+//     ShutdownTimeout.
+type Lifecycle struct {
+	ShutdownTimeout time.Duration
+	DrainPeriod     time.Duration
+	Signals         []os.Signal
+	Logger          *slog.Logger
+
+	servers    []*namedServer
+	workers    []worker
+	onShutdown []func()
+	closers    []closer
+}
+
+func New(shutdownTimeout, drainPeriod time.Duration, logger *slog.Logger) *Lifecycle {
+	return &Lifecycle{
+		ShutdownTimeout: shutdownTimeout,
+		DrainPeriod:     drainPeriod,
+		Signals:         []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		Logger:          logger,
+	}
+}
+
+// AddServer registers a server. It serves HTTPS when server.TLSConfig is set.
+func (l *Lifecycle) AddServer(name string, server *http.Server) {
+	l.servers = append(l.servers, &namedServer{name: name, server: server})
+}
+
+// AddOther registers a non-HTTP server listening on addr. It is started and
+// stopped along with the HTTP servers.
+func (l *Lifecycle) AddOther(name, addr string, server Server) {
+	l.servers = append(l.servers, &namedServer{name: name, other: server, addr: addr})
+}
+
+// AddWorker registers a background function. Its context is cancelled on
+// shutdown, after the servers stopped, and Run waits for it to return.
+func (l *Lifecycle) AddWorker(name string, run func(ctx context.Context)) {
+	l.workers = append(l.workers, worker{name: name, run: run})
+}
+
+// OnShutdown registers a hook run as soon as shutdown starts.
+func (l *Lifecycle) OnShutdown(fn func()) {
+	l.onShutdown = append(l.onShutdown, fn)
+}
+
+// AddCloser registers a resource released after servers and workers stopped,
+// such as the database pool.
+func (l *Lifecycle) AddCloser(name string, close func(ctx context.Context) error) {
+	l.closers = append(l.closers, closer{name: name, close: close})
+}
+
+// Listen binds the servers that are not listening yet, so a port already in
+// use is reported before anything else starts.
+func (l *Lifecycle) Listen() error {
+	for _, s := range l.servers {
+		if s.listener != nil {
+			continue
+		}
+		ln, err := net.Listen("tcp", s.address())
+		if err != nil {
+			for _, bound := range l.servers {
+				if bound.listener != nil {
+					bound.listener.Close()
+					bound.listener = nil
+				}
+			}
+			return fmt.Errorf("%s server: %w", s.name, err)
+		}
+		s.listener = ln
+	}
+	return nil
+}
+
+// Addr returns the address the named server listens on, once Listen ran.
+func (l *Lifecycle) Addr(name string) net.Addr {
+	for _, s := range l.servers {
+		if s.name == name && s.listener != nil {
+			return s.listener.Addr()
+		}
+	}
+	return nil
+}
+
+// Run serves until ctx is cancelled, a termination signal arrives or a server
+// fails, then shuts everything down. It returns the first error found.
+func (l *Lifecycle) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, l.Signals...)
+	defer stop()
+
+	if err := l.Listen(); err != nil {
+		l.close()
+		return err
+	}
+
+	serveErr := make(chan error, len(l.servers))
+	for _, s := range l.servers {
+		go func(s *namedServer) {
+			l.Logger.Info("starting server", "server", s.name, "addr", s.listener.Addr().String())
+			if err := s.serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("%s server: %w", s.name, err)
+			}
+		}(s)
+	}
+
+	workersCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	var wg sync.WaitGroup
+	for _, w := range l.workers {
+		wg.Add(1)
+		go func(w worker) {
+			defer wg.Done()
+			w.run(workersCtx)
+		}(w)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		l.Logger.Info("shutdown started")
+	case runErr = <-serveErr:
+		l.Logger.Error("server failed, shutting down", "error", runErr)
+	}
+	// a second signal falls back to the default behavior and kills the
+	// process right away
+	stop()
+
+	for _, fn := range l.onShutdown {
+		fn()
+	}
+
+	if runErr == nil && l.DrainPeriod > 0 {
+		l.Logger.Info("draining", "period", l.DrainPeriod.String())
+		time.Sleep(l.DrainPeriod)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), l.ShutdownTimeout)
+	defer cancel()
+
+	var serversWG sync.WaitGroup
+	shutdownErrs := make([]error, len(l.servers))
+	for i, s := range l.servers {
+		serversWG.Add(1)
+		go func(i int, s *namedServer) {
+			defer serversWG.Done()
+			if err := s.shutdown(shutdownCtx); err != nil {
+				shutdownErrs[i] = fmt.Errorf("%s server: %w", s.name, err)
+				s.close()
+			}
+		}(i, s)
+	}
+	serversWG.Wait()
+	for _, err := range shutdownErrs {
+		if err != nil {
+			l.Logger.Error("server did not stop gracefully", "error", err)
+			if runErr == nil {
+				runErr = err
+			}
+		}
+	}
+
+	cancelWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		l.Logger.Error("background workers did not stop in time")
+	}
+
+	// the closers get a budget of their own, as the servers may have used up
+	// shutdownCtx
+	if err := l.close(); err != nil && runErr == nil {
+		runErr = err
+	}
+
+	l.Logger.Info("shutdown complete")
+	return runErr
+}
+
+func (l *Lifecycle) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.ShutdownTimeout)
+	defer cancel()
+	return l.closeWith(ctx)
+}
+
+func (l *Lifecycle) closeWith(ctx context.Context) error {
+	var firstErr error
+	for i := len(l.closers) - 1; i >= 0; i-- {
+		c := l.closers[i]
+		if err := c.close(ctx); err != nil {
+			l.Logger.Error("failed to close", "resource", c.name, "error", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", c.name, err)
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,220 @@
+package lifecycle
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLifecycle(handler http.Handler) *Lifecycle {
+	l := New(5*time.Second, 50*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	l.AddServer("api", NewHTTPServer("127.0.0.1:0", handler, Timeouts{
+		Read:       5 * time.Second,
+		ReadHeader: time.Second,
+		Write:      5 * time.Second,
+		Idle:       5 * time.Second,
+	}))
+	return l
+}
+
+func TestSIGTERMDrainsInFlightRequest(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	l := newTestLifecycle(handler)
+
+	var shutdownHook, workerStopped, closed atomic.Bool
+	l.OnShutdown(func() { shutdownHook.Store(true) })
+	l.AddWorker("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		workerStopped.Store(true)
+	})
+	l.AddCloser("db", func(ctx context.Context) error {
+		closed.Store(true)
+		return nil
+	})
+
+	assert.Nil(t, l.Listen())
+	url := "http://" + l.Addr("api").String()
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- l.Run(context.Background()) }()
+
+	type response struct {
+		status int
+		body   string
+		err    error
+	}
+	slow := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			slow <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- response{status: resp.StatusCode, body: string(body)}
+	}()
+
+	<-started
+	assert.Nil(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	res := <-slow
+	assert.Nil(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "done", res.body)
+
+	select {
+	case err := <-runErr:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lifecycle did not stop")
+	}
+
+	assert.True(t, shutdownHook.Load())
+	assert.True(t, workerStopped.Load())
+	assert.True(t, closed.Load())
+
+	_, err := http.Get(url + "/slow")
+	assert.NotNil(t, err)
+}
+
+func TestShutdownTimeoutAbortsSlowRequest(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	defer close(release)
+
+	l := newTestLifecycle(handler)
+	l.ShutdownTimeout = 100 * time.Millisecond
+	l.DrainPeriod = 0
+	// the slow request uses up the servers' budget, not the closers' one
+	var closerCtxErr error
+	l.AddCloser("db", func(ctx context.Context) error {
+		closerCtxErr = ctx.Err()
+		return nil
+	})
+	assert.Nil(t, l.Listen())
+	url := "http://" + l.Addr("api").String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- l.Run(ctx) }()
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	select {
+	case err := <-runErr:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lifecycle did not stop")
+	}
+	assert.Nil(t, closerCtxErr)
+}
+
+func TestListenReportsPortInUse(t *testing.T) {
+	first := newTestLifecycle(http.NotFoundHandler())
+	assert.Nil(t, first.Listen())
+	ln := first.servers[0].listener
+	defer ln.Close()
+
+	second := newTestLifecycle(http.NotFoundHandler())
+	second.servers[0].server.Addr = first.Addr("api").String()
+	assert.NotNil(t, second.Run(context.Background()))
+}
+
+func TestServesTLSWhenConfigured(t *testing.T) {
+	// borrow the self-signed certificate of httptest and the client that
+	// trusts it
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsConfig := ts.TLS.Clone()
+	client := ts.Client()
+	ts.Close()
+
+	l := newTestLifecycle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotNil(t, r.TLS)
+	}))
+	l.servers[0].server.TLSConfig = tlsConfig
+	assert.Nil(t, l.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.Run(ctx) }()
+
+	res, err := client.Get("https://" + l.Addr("api").String())
+	if assert.Nil(t, err) {
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	cancel()
+	assert.Nil(t, <-done)
+}
+
+// fakeServer accepts connections until it is shut down.
+type fakeServer struct {
+	stop     chan struct{}
+	shutdown atomic.Bool
+}
+
+func (s *fakeServer) Serve(ln net.Listener) error {
+	go func() {
+		<-s.stop
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil
+		}
+		conn.Close()
+	}
+}
+
+func (s *fakeServer) Shutdown(ctx context.Context) error {
+	s.shutdown.Store(true)
+	close(s.stop)
+	return nil
+}
+
+func (s *fakeServer) Close() error {
+	return nil
+}
+
+func TestRunsOtherServers(t *testing.T) {
+	l := newTestLifecycle(http.NotFoundHandler())
+	other := &fakeServer{stop: make(chan struct{})}
+	l.AddOther("other", "127.0.0.1:0", other)
+	assert.Nil(t, l.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.Run(ctx) }()
+
+	conn, err := net.Dial("tcp", l.Addr("other").String())
+	if assert.Nil(t, err) {
+		conn.Close()
+	}
+
+	cancel()
+	assert.Nil(t, <-done)
+	assert.True(t, other.shutdown.Load())
+}
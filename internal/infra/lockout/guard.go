@@ -0,0 +1,139 @@
+package lockout
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+)
+
+// Policy configures when a key gets locked. After Threshold consecutive
+// failures the key is locked for BaseDelay, doubling on every further failure
+// up to MaxDelay. Failures are forgotten after Window without new attempts.
+type Policy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// Delay returns how long a key with the given failures stays locked. A zero
+// MaxDelay means no cap.
+func (p Policy) Delay(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		if delay > math.MaxInt64/2 {
+			// doubling again would overflow
+			return time.Duration(math.MaxInt64)
+		}
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// Guard tracks failed logins per account and per client IP.
+type Guard struct {
+	Store         Store
+	AccountPolicy Policy
+	IPPolicy      Policy
+	Now           func() time.Time
+}
+
+func NewGuard(store Store, accountPolicy, ipPolicy Policy) *Guard {
+	return &Guard{
+		Store:         store,
+		AccountPolicy: accountPolicy,
+		IPPolicy:      ipPolicy,
+		Now:           time.Now,
+	}
+}
+
+func AccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Attempt reserves a login attempt for the account and the IP and returns
+// zero, or returns how long the caller must wait when either is locked. The
+// attempt counts as failed until Succeed or Release, so the decision and the
+// count are one step and parallel attempts cannot slip past the threshold.
+// The email is tracked whether it belongs to a user or not, so locking does
+// not reveal which accounts exist.
+func (g *Guard) Attempt(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := g.Now()
+	accountWait, err := g.Store.Reserve(ctx, AccountKey(email), now, g.AccountPolicy)
+	if err != nil {
+		return 0, err
+	}
+	var ipWait time.Duration
+	if ip != "" {
+		if ipWait, err = g.Store.Reserve(ctx, IPKey(ip), now, g.IPPolicy); err != nil {
+			return 0, err
+		}
+	}
+	if accountWait == 0 && ipWait == 0 {
+		return 0, nil
+	}
+
+	// a locked attempt is not made, so the reservation that got through is
+	// taken back
+	if accountWait == 0 {
+		err = g.Store.Release(ctx, AccountKey(email))
+	} else if ip != "" && ipWait == 0 {
+		err = g.Store.Release(ctx, IPKey(ip))
+	}
+	if ipWait > accountWait {
+		return ipWait, err
+	}
+	return accountWait, err
+}
+
+// Release takes back an attempt that did not fail, such as a right password
+// still waiting for its second factor or a check cut short by a timeout.
+func (g *Guard) Release(ctx context.Context, email, ip string) error {
+	if err := g.Store.Release(ctx, AccountKey(email)); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return g.Store.Release(ctx, IPKey(ip))
+}
+
+// Succeed clears the account counter and takes back the attempt of the IP.
+// The earlier IP failures are kept so that an attacker cannot reset them by
+// logging into an account of their own.
+func (g *Guard) Succeed(ctx context.Context, email, ip string) error {
+	if err := g.Store.Reset(ctx, AccountKey(email)); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return g.Store.Release(ctx, IPKey(ip))
+}
+
+// Unlock clears the counters of the account and, when given, of the IP.
+func (g *Guard) Unlock(ctx context.Context, email, ip string) error {
+	if email != "" {
+		if err := g.Store.Reset(ctx, AccountKey(email)); err != nil {
+			return err
+		}
+	}
+	if ip != "" {
+		return g.Store.Reset(ctx, IPKey(ip))
+	}
+	return nil
+}
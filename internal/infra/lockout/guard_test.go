@@ -0,0 +1,168 @@
+package lockout
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestGuard(now *time.Time) *Guard {
+	guard := NewGuard(NewMemoryStore(),
+		Policy{Threshold: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Window: time.Minute},
+		Policy{Threshold: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Window: time.Minute},
+	)
+	guard.Now = func() time.Time { return *now }
+	return guard
+}
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{Threshold: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	assert.Equal(t, time.Duration(0), policy.Delay(2))
+	assert.Equal(t, time.Second, policy.Delay(3))
+	assert.Equal(t, 2*time.Second, policy.Delay(4))
+	assert.Equal(t, 8*time.Second, policy.Delay(6))
+	assert.Equal(t, 10*time.Second, policy.Delay(7))
+	assert.Equal(t, 10*time.Second, policy.Delay(100))
+}
+
+func TestUncappedPolicyDelay(t *testing.T) {
+	policy := Policy{Threshold: 3, BaseDelay: time.Second}
+	assert.Equal(t, time.Second, policy.Delay(3))
+	assert.Equal(t, 2*time.Second, policy.Delay(4))
+	assert.Equal(t, 32*time.Second, policy.Delay(8))
+	// doubling stops before it overflows
+	assert.Equal(t, time.Duration(math.MaxInt64), policy.Delay(1000))
+}
+
+// fail makes an attempt that fails.
+func fail(t *testing.T, guard *Guard, email, ip string) {
+	wait, err := guard.Attempt(context.Background(), email, ip)
+	assert.Nil(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestGuardLocksAccount(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	guard := newTestGuard(&now)
+
+	for i := 0; i < 2; i++ {
+		fail(t, guard, "1y3t3@example.com", "10.0.0.1")
+	}
+	fail(t, guard, "1Y3T3@example.com", "10.0.0.2")
+	wait, err := guard.Attempt(ctx, "1y3t3@example.com", "10.0.0.3")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	now = now.Add(time.Second)
+	fail(t, guard, "1y3t3@example.com", "10.0.0.3")
+	wait, _ = guard.Attempt(ctx, "1y3t3@example.com", "10.0.0.3")
+	assert.Equal(t, 2*time.Second, wait)
+
+	// a locked attempt is not counted against the IP
+	entry, _ := guard.Store.Get(ctx, IPKey("10.0.0.3"))
+	assert.Equal(t, 1, entry.Failures)
+
+	assert.Nil(t, guard.Succeed(ctx, "1y3t3@example.com", "10.0.0.3"))
+	wait, _ = guard.Attempt(ctx, "1y3t3@example.com", "10.0.0.3")
+	assert.Zero(t, wait)
+}
+
+func TestGuardReleasesAttempt(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	guard := newTestGuard(&now)
+
+	// right passwords waiting for the second factor do not lock the account
+	for i := 0; i < 5; i++ {
+		fail(t, guard, "a@example.com", "10.0.0.1")
+		assert.Nil(t, guard.Release(ctx, "a@example.com", "10.0.0.1"))
+	}
+	entry, _ := guard.Store.Get(ctx, AccountKey("a@example.com"))
+	assert.Zero(t, entry.Failures)
+	entry, _ = guard.Store.Get(ctx, IPKey("10.0.0.1"))
+	assert.Zero(t, entry.Failures)
+}
+
+func TestGuardLocksParallelAttempts(t *testing.T) {
+	now := time.Now()
+	guard := newTestGuard(&now)
+
+	var wg sync.WaitGroup
+	var allowed int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if wait, _ := guard.Attempt(context.Background(), "a@example.com", "10.0.0.1"); wait == 0 {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(guard.AccountPolicy.Threshold), allowed)
+}
+
+func TestGuardLocksLongerThanWindow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	guard := NewGuard(NewMemoryStore(),
+		Policy{Threshold: 2, BaseDelay: 10 * time.Minute, Window: time.Minute},
+		Policy{},
+	)
+	guard.Now = func() time.Time { return now }
+
+	fail(t, guard, "a@example.com", "")
+	fail(t, guard, "a@example.com", "")
+	// the lock outlives the window the failures are counted in
+	now = now.Add(5 * time.Minute)
+	wait, _ := guard.Attempt(ctx, "a@example.com", "")
+	assert.Equal(t, 5*time.Minute, wait)
+}
+
+func TestGuardLocksIP(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	guard := newTestGuard(&now)
+
+	emails := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}
+	for _, email := range emails {
+		fail(t, guard, email, "10.0.0.1")
+	}
+
+	wait, _ := guard.Attempt(ctx, "f@example.com", "10.0.0.1")
+	assert.Equal(t, time.Second, wait)
+	wait, _ = guard.Attempt(ctx, "f@example.com", "10.0.0.2")
+	assert.Zero(t, wait)
+
+	assert.Nil(t, guard.Unlock(ctx, "", "10.0.0.1"))
+	wait, _ = guard.Attempt(ctx, "g@example.com", "10.0.0.1")
+	assert.Zero(t, wait)
+}
+
+func TestMemoryStoreExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+	policy := Policy{Threshold: 10, BaseDelay: time.Second, Window: time.Minute}
+
+	store.Reserve(ctx, "key", now, policy)
+	store.Reserve(ctx, "key", now.Add(30*time.Second), policy)
+	entry, _ := store.Get(ctx, "key")
+	assert.Equal(t, 2, entry.Failures)
+
+	store.Reserve(ctx, "key", now.Add(2*time.Minute), policy)
+	assert.Nil(t, store.Release(ctx, "key"))
+	entry, _ = store.Get(ctx, "key")
+	assert.Zero(t, entry.Failures)
+
+	store.Reserve(ctx, "key", now.Add(2*time.Minute), policy)
+	assert.Nil(t, store.Reset(ctx, "key"))
+	entry, _ = store.Get(ctx, "key")
+	assert.Zero(t, entry.Failures)
+}
@@ -0,0 +1,121 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Entry holds the consecutive failed attempts registered for a key.
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps the failure counters. MemoryStore works for a single process;
+// a shared backend (redis, database) can be plugged in to share counters
+// between instances.
+type Store interface {
+	Get(ctx context.Context, key string) (Entry, error)
+	// Reserve atomically registers an attempt for key, unless policy still
+	// locks it, in which case nothing is registered and the time left is
+	// returned. The attempt counts as a failure until it is released, so a
+	// burst of concurrent attempts cannot all get past the threshold. The
+	// entry is kept for policy.Window after the last attempt, or longer while
+	// it still locks the key.
+	Reserve(ctx context.Context, key string, now time.Time, policy Policy) (time.Duration, error)
+	// Release takes back a reserved attempt that did not fail.
+	Release(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+}
+
+type memoryEntry struct {
+	Entry
+	expiresAt time.Time
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		return Entry{}, nil
+	}
+	return e.Entry, nil
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, key string, now time.Time, policy Policy) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now, policy.Window)
+
+	e, ok := s.entries[key]
+	if !ok || now.After(e.expiresAt) {
+		e = memoryEntry{}
+	}
+	if wait := e.LastFailure.Add(policy.Delay(e.Failures)).Sub(now); wait > 0 {
+		return wait, nil
+	}
+	e.Failures++
+	e.LastFailure = now
+	e.expiresAt = now.Add(entryTTL(policy, e.Failures))
+	s.entries[key] = e
+
+	return 0, nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.Failures > 0 {
+		e.Failures--
+		s.entries[key] = e
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops expired entries at most once per interval so the map does not
+// grow forever with keys that stopped failing.
+func (s *MemoryStore) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(s.lastSweep) < interval {
+		return
+	}
+	for key, e := range s.entries {
+		if now.After(e.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// entryTTL keeps an entry while the failures lock the key, which with no
+// MaxDelay may be much longer than the window, and for the window after.
+func entryTTL(policy Policy, failures int) time.Duration {
+	if delay := policy.Delay(failures); delay > policy.Window {
+		return delay
+	}
+	return policy.Window
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values never reach the output,
+// compared case-insensitively.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"x-api-key":     true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"password":      true,
+	"new_password":  true,
+	"client_secret": true,
+	"recovery_code": true,
+	"mfa_secret":    true,
+	"access_token":  true,
+	"mfa_token":     true,
+}
+
+type loggerKey struct{}
+
+// New builds a logger writing to w. level is one of debug, info, warn or
+// error and format is json or text; unknown values fall back to info and
+// json.
+func New(w io.Writer, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       ParseLevel(level),
+		ReplaceAttr: redact,
+	}
+	if strings.EqualFold(format, FormatText) {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// WithContext stores l in ctx so code down the call chain logs with the
+// request attributes.
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx or slog.Default.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// Headers turns h into a log group with sensitive headers redacted.
+func Headers(h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(h))
+	for name, values := range h {
+		attrs = append(attrs, slog.String(strings.ToLower(name), strings.Join(values, ", ")))
+	}
+	return slog.Group("headers", attrs...)
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
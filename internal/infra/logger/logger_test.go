@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRedactsSensitiveAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "info", FormatJSON)
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc")
+	h.Set("X-Api-Key", "gea_prefix_secret")
+	h.Set("Accept", "application/json")
+	l.Info("request", Headers(h), slog.String("password", "123456"), slog.String("email", "john@example.com"))
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+	headers := entry["headers"].(map[string]interface{})
+	assert.Equal(t, redacted, headers["authorization"])
+	assert.Equal(t, redacted, headers["x-api-key"])
+	assert.Equal(t, "application/json", headers["accept"])
+	assert.Equal(t, redacted, entry["password"])
+	assert.Equal(t, "john@example.com", entry["email"])
+	assert.NotContains(t, buf.String(), "abc")
+	assert.NotContains(t, buf.String(), "123456")
+}
+
+func TestNewHonorsLevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "warn", FormatText)
+
+	l.Info("hidden")
+	assert.Empty(t, buf.String())
+
+	l.Warn("shown", "key", "value")
+	assert.Contains(t, buf.String(), "level=WARN")
+	assert.Contains(t, buf.String(), "key=value")
+}
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelDebug, ParseLevel("debug"))
+	assert.Equal(t, slog.LevelError, ParseLevel("ERROR"))
+	assert.Equal(t, slog.LevelInfo, ParseLevel("verbose"))
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, slog.Default(), FromContext(context.Background()))
+
+	l := New(&bytes.Buffer{}, "info", FormatJSON)
+	ctx := WithContext(context.Background(), l)
+	assert.Equal(t, l, FromContext(ctx))
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileMailer writes every message to an io.Writer instead of sending it. It is
+// meant for local development and tests.
+type FileMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	From string
+}
+
+func NewFileMailer(w io.Writer, from string) *FileMailer {
+	return &FileMailer{
+		w:    w,
+		From: from,
+	}
+}
+
+func NewStdoutMailer(from string) *FileMailer {
+	return NewFileMailer(os.Stdout, from)
+}
+
+// OpenFileMailer appends messages to the file at path, creating it if needed.
+func OpenFileMailer(path, from string) (*FileMailer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileMailer(f, from), nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "Date: %s\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n\n---\n",
+		time.Now().Format(time.RFC1123Z), m.From, msg.To, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewFileMailer(&buf, "no-reply@example.com")
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "1y3t3@example.com",
+		Subject: "Hello",
+		Body:    "token: abc",
+	})
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "From: no-reply@example.com")
+	assert.Contains(t, buf.String(), "To: 1y3t3@example.com")
+	assert.Contains(t, buf.String(), "Subject: Hello")
+	assert.Contains(t, buf.String(), "token: abc")
+}
+
+func TestFileMailerSendWhenContextIsCanceled(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewFileMailer(&buf, "no-reply@example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, mailer.Send(ctx, Message{To: "1y3t3@example.com"}))
+	assert.Empty(t, buf.String())
+}
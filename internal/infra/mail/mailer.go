@@ -0,0 +1,15 @@
+package mail
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails such as verification and password
+// reset messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
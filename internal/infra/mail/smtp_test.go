@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMTPMailerSendStopsAtDeadline(t *testing.T) {
+	// a server that accepts the connection and never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			<-done
+			conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	mailer := NewSMTPMailer(host, port, "", "", "no-reply@example.com")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = mailer.Send(ctx, Message{To: "1y3t3@example.com", Subject: "Hello", Body: "token: abc"})
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
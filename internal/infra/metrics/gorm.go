@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+const (
+	gormPluginName = "metrics"
+	startedAtKey   = "metrics:started_at"
+)
+
+// InstrumentDB records the duration of every statement executed through db
+// and exposes the connection pool statistics.
+func (m *Metrics) InstrumentDB(db *gorm.DB, name string) error {
+	if err := db.Use(&gormPlugin{metrics: m}); err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return m.Registry.Register(collectors.NewDBStatsCollector(sqlDB, name))
+}
+
+type gormPlugin struct {
+	metrics *Metrics
+}
+
+func (p *gormPlugin) Name() string {
+	return gormPluginName
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before(gormPluginName+":before_"+h.operation, p.before); err != nil {
+			return err
+		}
+		if err := h.after(gormPluginName+":after_"+h.operation, p.after(h.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *gormPlugin) before(db *gorm.DB) {
+	db.InstanceSet(startedAtKey, time.Now())
+}
+
+func (p *gormPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(startedAtKey)
+		if !ok {
+			return
+		}
+		startedAt, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		p.metrics.ObserveDBQuery(operation, table, time.Since(startedAt), err)
+	}
+}
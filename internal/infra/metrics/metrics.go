@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "go_expert_api"
+
+// Login outcomes recorded by ObserveLogin.
+const (
+	LoginSuccess     = "success"
+	LoginBadPassword = "bad_password"
+	LoginUnknownUser = "unknown_user"
+	LoginLocked      = "locked"
+	LoginUnverified  = "unverified"
+	LoginMFARequired = "mfa_required"
+	LoginError       = "error"
+)
+
+// Metrics owns a dedicated registry so only the collectors of this API are
+// exposed. All methods are safe to call on a nil *Metrics, which disables
+// instrumentation.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	httpRequests    *prometheus.CounterVec
+	httpErrors      *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	loginAttempts   *prometheus.CounterVec
+	dbQueryDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_request_errors_total",
+			Help:      "HTTP requests answered with a 5xx status by method and route pattern.",
+		}, []string{"method", "route"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		loginAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_login_attempts_total",
+			Help:      "Login attempts at /users/generate_token by outcome.",
+		}, []string{"outcome"}),
+		dbQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Database statement latency by operation, table and result.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"operation", "table", "result"}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpErrors,
+		m.httpDuration,
+		m.loginAttempts,
+		m.dbQueryDuration,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	if status >= http.StatusInternalServerError {
+		m.httpErrors.WithLabelValues(method, route).Inc()
+	}
+	m.httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+func (m *Metrics) ObserveLogin(outcome string) {
+	if m == nil {
+		return
+	}
+	m.loginAttempts.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) ObserveDBQuery(operation, table string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.dbQueryDuration.WithLabelValues(operation, table, result).Observe(elapsed.Seconds())
+}
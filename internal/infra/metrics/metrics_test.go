@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestObserveHTTPRequest(t *testing.T) {
+	m := New()
+	m.ObserveHTTPRequest(http.MethodGet, "/products/{id}", http.StatusOK, time.Millisecond)
+	m.ObserveHTTPRequest(http.MethodGet, "/products/{id}", http.StatusInternalServerError, time.Millisecond)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues(http.MethodGet, "/products/{id}", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues(http.MethodGet, "/products/{id}", "500")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpErrors.WithLabelValues(http.MethodGet, "/products/{id}")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.httpDuration))
+}
+
+func TestObserveLogin(t *testing.T) {
+	m := New()
+	m.ObserveLogin(LoginSuccess)
+	m.ObserveLogin(LoginBadPassword)
+	m.ObserveLogin(LoginBadPassword)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.loginAttempts.WithLabelValues(LoginSuccess)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.loginAttempts.WithLabelValues(LoginBadPassword)))
+}
+
+func TestNilMetricsIsNoop(t *testing.T) {
+	var m *Metrics
+	m.ObserveHTTPRequest(http.MethodGet, "/", http.StatusOK, time.Millisecond)
+	m.ObserveLogin(LoginSuccess)
+	m.ObserveDBQuery("query", "users", time.Millisecond, errors.New("boom"))
+}
+
+type record struct {
+	ID   int
+	Name string
+}
+
+func TestInstrumentDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&record{})
+
+	m := New()
+	assert.Nil(t, m.InstrumentDB(db, "main"))
+
+	db.Create(&record{ID: 1, Name: "a"})
+	var found, missing record
+	db.First(&found, 1)
+	db.First(&missing, 2)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	assert.Contains(t, string(body), `go_expert_api_db_query_duration_seconds_count{operation="create",result="ok",table="records"} 1`)
+	assert.Contains(t, string(body), `go_expert_api_db_query_duration_seconds_count{operation="query",result="ok",table="records"} 2`)
+	assert.Contains(t, string(body), `go_sql_open_connections{db_name="main"}`)
+}
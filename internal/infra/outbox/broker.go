@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderEventID       = "Event-ID"
+	HeaderEventType     = "Event-Type"
+	HeaderAggregateType = "Aggregate-Type"
+	HeaderAggregateID   = "Aggregate-ID"
+)
+
+// NATS publishes to JetStream on subject prefix.<event type>, waiting for
+// the ack of the stream. The event ID is the message ID, so the stream
+// drops the copies a retry sends within its duplicate window. A stream must
+// capture the subjects.
+type NATS struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	prefix string
+}
+
+func NewNATS(url, prefix string) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.Name("go_expert_api outbox"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATS{conn: conn, js: js, prefix: prefix}, nil
+}
+
+func (p *NATS) Publish(ctx context.Context, msg *entity.OutboxMessage) error {
+	m := nats.NewMsg(p.prefix + "." + msg.EventType)
+	m.Data = msg.Payload
+	m.Header.Set(nats.MsgIdHdr, msg.EventID.String())
+	m.Header.Set(HeaderEventType, msg.EventType)
+	m.Header.Set(HeaderAggregateType, msg.AggregateType)
+	m.Header.Set(HeaderAggregateID, msg.AggregateID)
+	_, err := p.js.PublishMsg(m, nats.Context(ctx))
+	return err
+}
+
+func (p *NATS) Close() error {
+	return p.conn.Drain()
+}
+
+// Kafka publishes to a topic keyed by aggregate, so the events of an
+// aggregate land on the same partition in order. It waits for every
+// in-sync replica to ack.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+func NewKafka(brokers, topic string) (*Kafka, error) {
+	var addrs []string
+	for _, b := range strings.Split(brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			addrs = append(addrs, b)
+		}
+	}
+	if len(addrs) == 0 || topic == "" {
+		return nil, errors.New("kafka brokers and topic are required")
+	}
+	return &Kafka{writer: &kafka.Writer{
+		Addr:         kafka.TCP(addrs...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// the relay publishes one message at a time
+		BatchTimeout: 10 * time.Millisecond,
+	}}, nil
+}
+
+func (p *Kafka) Publish(ctx context.Context, msg *entity.OutboxMessage) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(msg.AggregateType + ":" + msg.AggregateID),
+		Value: msg.Payload,
+		Headers: []kafka.Header{
+			{Key: HeaderEventID, Value: []byte(msg.EventID.String())},
+			{Key: HeaderEventType, Value: []byte(msg.EventType)},
+			{Key: HeaderAggregateType, Value: []byte(msg.AggregateType)},
+			{Key: HeaderAggregateID, Value: []byte(msg.AggregateID)},
+		},
+	})
+}
+
+func (p *Kafka) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	PublisherNone  = "none"
+	PublisherFile  = "file"
+	PublisherNATS  = "nats"
+	PublisherKafka = "kafka"
+)
+
+type Config struct {
+	Publisher    string
+	FilePath     string
+	NATSURL      string
+	NATSSubject  string
+	KafkaBrokers string
+	KafkaTopic   string
+}
+
+// NewPublisher returns the publisher of cfg and what closes it, both nil
+// when no publisher is configured.
+func NewPublisher(cfg Config) (Publisher, io.Closer, error) {
+	switch cfg.Publisher {
+	case PublisherNone, "":
+		return nil, nil, nil
+	case PublisherFile:
+		p, err := NewFile(cfg.FilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, p, nil
+	case PublisherNATS:
+		p, err := NewNATS(cfg.NATSURL, cfg.NATSSubject)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, p, nil
+	case PublisherKafka:
+		p, err := NewKafka(cfg.KafkaBrokers, cfg.KafkaTopic)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, p, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown outbox publisher %q", cfg.Publisher)
+	}
+}
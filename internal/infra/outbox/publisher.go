@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+)
+
+// Multi publishes every message to each of publishers in turn. A failure
+// stops at that publisher, and the next attempt publishes to all of them
+// again.
+func Multi(publishers ...Publisher) Publisher {
+	return multi(publishers)
+}
+
+type multi []Publisher
+
+func (m multi) Publish(ctx context.Context, msg *entity.OutboxMessage) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Memory keeps the messages published, for tests and development.
+type Memory struct {
+	mu       sync.Mutex
+	messages []*entity.OutboxMessage
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Publish(_ context.Context, msg *entity.OutboxMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages published so far.
+func (m *Memory) Messages() []*entity.OutboxMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*entity.OutboxMessage(nil), m.messages...)
+}
+
+// File appends every message as a JSON line to a file, synced before the
+// message counts as published.
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f}, nil
+}
+
+func (p *File) Publish(_ context.Context, msg *entity.OutboxMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return p.f.Sync()
+}
+
+func (p *File) Close() error {
+	return p.f.Close()
+}
@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+)
+
+// Publisher sends outbox messages to the systems interested in them. A nil
+// error means the message was accepted and will not be sent again.
+type Publisher interface {
+	Publish(ctx context.Context, msg *entity.OutboxMessage) error
+}
+
+// Relay publishes the messages of the outbox. Delivery is at least once: a
+// message published right before a crash, and not yet marked, is published
+// again after the restart, so consumers must ignore the event IDs they
+// already handled. The messages of an aggregate are published in the order
+// they were stored; a failed message holds back the later ones of its
+// aggregate until it is published, or until it failed MaxAttempts times and
+// is marked dead. A single relay must run at a time.
+type Relay struct {
+	Store        database.OutboxInterface
+	Publisher    Publisher
+	BatchSize    int
+	PollInterval time.Duration
+	// MaxAttempts is how many times a message is tried before it is marked
+	// dead; zero means no limit.
+	MaxAttempts int
+}
+
+func NewRelay(store database.OutboxInterface, publisher Publisher, batchSize int, pollInterval time.Duration) *Relay {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	return &Relay{
+		Store:        store,
+		Publisher:    publisher,
+		BatchSize:    batchSize,
+		PollInterval: pollInterval,
+	}
+}
+
+// Run publishes the pending messages until ctx is cancelled, as soon as
+// they are committed and every PollInterval for the retries.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.PublishPending(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("failed to relay outbox messages", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.Store.Appended():
+		}
+	}
+}
+
+// PublishPending makes one pass over the pending messages, BatchSize at a
+// time. The pages go past the messages held back by a failure, so a failing
+// aggregate with many pending messages does not hold back the others.
+func (r *Relay) PublishPending(ctx context.Context) error {
+	failed := map[string]bool{}
+	var after uint64
+	for {
+		messages, err := r.Store.FindPending(ctx, after, r.BatchSize)
+		if err != nil {
+			return err
+		}
+		if err := r.publish(ctx, messages, failed); err != nil {
+			return err
+		}
+		if len(messages) < r.BatchSize {
+			return nil
+		}
+		after = messages[len(messages)-1].ID
+	}
+}
+
+// publish publishes messages, skipping the aggregates in failed and adding
+// the ones that fail.
+func (r *Relay) publish(ctx context.Context, messages []*entity.OutboxMessage, failed map[string]bool) error {
+	for _, msg := range messages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		aggregate := msg.AggregateType + ":" + msg.AggregateID
+		if failed[aggregate] {
+			continue
+		}
+		if err := r.Publisher.Publish(ctx, msg); err != nil {
+			if err := r.fail(ctx, msg, err, failed); err != nil {
+				return err
+			}
+			continue
+		}
+		// marked one at a time, so a crash republishes one message at most
+		if err := r.Store.MarkPublished(context.WithoutCancel(ctx), msg.ID, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail records a failed attempt. The last allowed attempt marks the message
+// dead, which lets the rest of its aggregate go on.
+func (r *Relay) fail(ctx context.Context, msg *entity.OutboxMessage, cause error, failed map[string]bool) error {
+	ctx = context.WithoutCancel(ctx)
+	attempts := msg.Attempts + 1
+	if r.MaxAttempts > 0 && attempts >= r.MaxAttempts {
+		slog.Error("giving up on outbox message", "outbox_message", msg.ID,
+			"event", msg.EventType, "attempts", attempts, "error", cause)
+		return r.Store.MarkDead(ctx, msg.ID, cause.Error(), time.Now())
+	}
+	failed[msg.AggregateType+":"+msg.AggregateID] = true
+	slog.Warn("failed to publish outbox message", "outbox_message", msg.ID,
+		"event", msg.EventType, "attempts", attempts, "error", cause)
+	return r.Store.MarkFailed(ctx, msg.ID, cause.Error())
+}
+
+// Cleanup deletes the messages published more than retention ago, every
+// interval until ctx is cancelled.
+func (r *Relay) Cleanup(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Store.DeletePublishedBefore(ctx, time.Now().Add(-retention)); err != nil {
+				slog.Error("failed to delete published outbox messages", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStores(t *testing.T) (*database.Outbox, *database.Product) {
+	db := testutil.NewSQLite(t, &entity.Product{}, &entity.OutboxMessage{})
+	outbox := database.NewOutbox(db)
+	products := database.NewProduct(db)
+	products.Outbox = outbox
+	return outbox, products
+}
+
+// failing fails the messages of an aggregate while down is set.
+type failing struct {
+	Memory
+	down map[string]bool
+}
+
+func (f *failing) Publish(ctx context.Context, msg *entity.OutboxMessage) error {
+	if f.down[msg.AggregateID] {
+		return errors.New("broker unavailable")
+	}
+	return f.Memory.Publish(ctx, msg)
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	store, products := newStores(t)
+	ctx := context.Background()
+
+	product, _ := entity.NewProduct("Product", 10)
+	products.Create(ctx, product)
+	product.Name = "Renamed"
+	products.Update(ctx, product)
+	products.Delete(ctx, product.ID.String())
+
+	memory := NewMemory()
+	relay := NewRelay(store, memory, 2, 0)
+	assert.Nil(t, relay.PublishPending(ctx))
+
+	messages := memory.Messages()
+	assert.Len(t, messages, 3)
+	for i, eventType := range []string{entity.EventProductCreated, entity.EventProductUpdated, entity.EventProductDeleted} {
+		assert.Equal(t, eventType, messages[i].EventType)
+	}
+	pending, _ := store.FindPending(ctx, 0, 10)
+	assert.Empty(t, pending)
+
+	// published messages are not sent again
+	assert.Nil(t, relay.PublishPending(ctx))
+	assert.Len(t, memory.Messages(), 3)
+}
+
+func TestRelayHoldsBackFailedAggregate(t *testing.T) {
+	store, products := newStores(t)
+	ctx := context.Background()
+
+	first, _ := entity.NewProduct("First", 10)
+	second, _ := entity.NewProduct("Second", 10)
+	products.Create(ctx, first)
+	products.Create(ctx, second)
+	first.Name = "Renamed"
+	products.Update(ctx, first)
+
+	publisher := &failing{down: map[string]bool{first.ID.String(): true}}
+	relay := NewRelay(store, publisher, 10, 0)
+	assert.Nil(t, relay.PublishPending(ctx))
+
+	// the other aggregate is not held back, the update waits for the create
+	messages := publisher.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, second.ID.String(), messages[0].AggregateID)
+	pending, _ := store.FindPending(ctx, 0, 10)
+	assert.Len(t, pending, 2)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "broker unavailable", pending[0].LastError)
+	assert.Equal(t, 0, pending[1].Attempts)
+
+	publisher.down = nil
+	assert.Nil(t, relay.PublishPending(ctx))
+	messages = publisher.Messages()
+	assert.Len(t, messages, 3)
+	assert.Equal(t, entity.EventProductCreated, messages[1].EventType)
+	assert.Equal(t, entity.EventProductUpdated, messages[2].EventType)
+}
+
+func TestRelayPagesPastFailedAggregate(t *testing.T) {
+	store, products := newStores(t)
+	ctx := context.Background()
+
+	// the failing aggregate alone fills more than a batch
+	broken, _ := entity.NewProduct("Broken", 10)
+	products.Create(ctx, broken)
+	for i := 0; i < 5; i++ {
+		products.Update(ctx, broken)
+	}
+	other, _ := entity.NewProduct("Other", 10)
+	products.Create(ctx, other)
+
+	publisher := &failing{down: map[string]bool{broken.ID.String(): true}}
+	relay := NewRelay(store, publisher, 2, 0)
+	assert.Nil(t, relay.PublishPending(ctx))
+
+	messages := publisher.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, other.ID.String(), messages[0].AggregateID)
+	}
+	// only the first message of the failing aggregate was tried
+	pending, _ := store.FindPending(ctx, 0, 10)
+	assert.Len(t, pending, 6)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, 0, pending[1].Attempts)
+}
+
+func TestRelayGivesUpAfterMaxAttempts(t *testing.T) {
+	store, products := newStores(t)
+	ctx := context.Background()
+
+	product, _ := entity.NewProduct("Product", 10)
+	products.Create(ctx, product)
+	products.Update(ctx, product)
+
+	publisher := &failing{}
+	relay := NewRelay(store, publisher, 10, 0)
+	relay.MaxAttempts = 2
+	publisher.down = map[string]bool{product.ID.String(): true}
+	assert.Nil(t, relay.PublishPending(ctx))
+	assert.Nil(t, relay.PublishPending(ctx))
+
+	// the created event is dead and the update was tried in the same pass
+	pending, _ := store.FindPending(ctx, 0, 10)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, entity.EventProductUpdated, pending[0].EventType)
+		assert.Equal(t, 1, pending[0].Attempts)
+	}
+
+	publisher.down = nil
+	assert.Nil(t, relay.PublishPending(ctx))
+	messages := publisher.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, entity.EventProductUpdated, messages[0].EventType)
+	}
+}
+
+func TestFilePublisher(t *testing.T) {
+	store, products := newStores(t)
+	ctx := context.Background()
+	product, _ := entity.NewProduct("Product", 10)
+	products.Create(ctx, product)
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	file, err := NewFile(path)
+	assert.Nil(t, err)
+	assert.Nil(t, NewRelay(store, Multi(NewMemory(), file), 10, 0).PublishPending(ctx))
+	assert.Nil(t, file.Close())
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	assert.True(t, scanner.Scan())
+	var msg entity.OutboxMessage
+	assert.Nil(t, json.Unmarshal(scanner.Bytes(), &msg))
+	assert.Equal(t, entity.EventProductCreated, msg.EventType)
+	assert.Equal(t, product.ID.String(), msg.AggregateID)
+	assert.False(t, scanner.Scan())
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit configures a token bucket: Rate tokens are added per second up to
+// Burst, and every request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns a limit allowing n requests per minute on average with
+// bursts of up to burst requests.
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a token is available, zero when allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps the buckets. MemoryStore works for a single process; a shared
+// backend (redis, database) can be plugged in to share limits between
+// instances.
+type Store interface {
+	// Take atomically refills the bucket of key and takes a token if one is
+	// available.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// idle is how long the bucket takes to refill, after which it can be
+	// forgotten since a new bucket would be identical.
+	idle time.Duration
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fillTime := time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+	s.sweep(now, fillTime)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	b.idle = fillTime
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed.Seconds()*limit.Rate)
+		b.last = now
+	}
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = secondsToDuration((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return result, nil
+}
+
+// sweep drops buckets that are full again at most once per interval so the
+// map does not grow forever with clients that went away.
+func (s *MemoryStore) sweep(now time.Time, interval time.Duration) {
+	if now.Sub(s.lastSweep) < interval {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.idle {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreAllowsBurstThenLimits(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 3}
+	now := time.Now()
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		res, err := store.Take(ctx, "k", limit, now)
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+		assert.Equal(t, 3, res.Limit)
+	}
+
+	res, _ := store.Take(ctx, "k", limit, now)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.Reset)
+}
+
+func TestMemoryStoreRefills(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 2, Burst: 1}
+	now := time.Now()
+	ctx := context.Background()
+
+	res, _ := store.Take(ctx, "k", limit, now)
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(ctx, "k", limit, now.Add(100*time.Millisecond))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 400*time.Millisecond, res.RetryAfter)
+
+	res, _ = store.Take(ctx, "k", limit, now.Add(500*time.Millisecond))
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 1}
+	now := time.Now()
+	ctx := context.Background()
+
+	res, _ := store.Take(ctx, "a", limit, now)
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(ctx, "b", limit, now)
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(ctx, "a", limit, now)
+	assert.False(t, res.Allowed)
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 1}
+	now := time.Now()
+	ctx := context.Background()
+
+	store.Take(ctx, "a", limit, now)
+	store.Take(ctx, "b", limit, now.Add(10*time.Second))
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestPerMinute(t *testing.T) {
+	l := PerMinute(120, 10)
+	assert.Equal(t, 2.0, l.Rate)
+	assert.True(t, l.Enabled())
+	assert.False(t, PerMinute(0, 10).Enabled())
+}
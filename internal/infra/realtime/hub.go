@@ -0,0 +1,346 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+)
+
+// Drop policies, applied when a message is sent to a client whose queue is
+// full.
+const (
+	// DropOldest discards the oldest queued message, so the client gets the
+	// latest prices.
+	DropOldest = "oldest"
+	// DropNewest discards the message being sent.
+	DropNewest = "newest"
+	// Disconnect ends the client, which is expected to reconnect and reload
+	// the prices.
+	Disconnect = "disconnect"
+)
+
+// DefaultMaxSubscriptions bounds the product IDs plus categories a client
+// follows.
+const DefaultMaxSubscriptions = 1000
+
+// recentMessages is how many outbox message IDs the hub remembers, so a
+// message published again by the relay is not sent twice.
+const recentMessages = 1024
+
+var (
+	ErrHubClosed            = errors.New("server shutting down")
+	ErrClientTooSlow        = errors.New("client too slow")
+	ErrInvalidPolicy        = fmt.Errorf("drop policy must be %s, %s or %s", DropOldest, DropNewest, Disconnect)
+	ErrNothingToSubscribe   = errors.New("products or categories are required")
+	ErrTooManySubscriptions = errors.New("too many products and categories followed")
+)
+
+// Hub sends the price changes published by the outbox relay to the
+// connected clients that follow the product or its category. Sending never
+// blocks: each client has a queue, and when it is full the drop policy
+// decides what is lost.
+type Hub struct {
+	QueueSize        int
+	Policy           string
+	MaxSubscriptions int
+
+	mu      sync.RWMutex
+	clients map[*Client]bool
+	closed  bool
+
+	// sent are the IDs of the last messages sent, in a ring starting at
+	// next
+	sentMu sync.Mutex
+	sent   []uint64
+	sentID map[uint64]bool
+	next   int
+}
+
+// NewHub returns a hub whose clients queue up to queueSize messages,
+// dropped as policy says once full.
+func NewHub(queueSize int, policy string) (*Hub, error) {
+	if policy != DropOldest && policy != DropNewest && policy != Disconnect {
+		return nil, ErrInvalidPolicy
+	}
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	return &Hub{
+		QueueSize:        queueSize,
+		Policy:           policy,
+		MaxSubscriptions: DefaultMaxSubscriptions,
+		clients:          map[*Client]bool{},
+		sentID:           make(map[uint64]bool, recentMessages),
+	}, nil
+}
+
+// Register adds a client following nothing yet. It returns nil once the
+// hub closed.
+func (h *Hub) Register() *Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	queue := make(chan []byte, h.QueueSize)
+	c := &Client{
+		C:          queue,
+		hub:        h,
+		queue:      queue,
+		products:   map[string]bool{},
+		categories: map[string]bool{},
+	}
+	h.clients[c] = true
+	return c
+}
+
+// Unregister ends c. It is a no-op when c already ended.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+	c.end(nil)
+}
+
+// Clients returns how many clients are connected.
+func (h *Hub) Clients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Publish sends product.price_changed events to the clients following the
+// product or its category; other messages, and messages already sent, are
+// ignored.
+func (h *Hub) Publish(_ context.Context, msg *entity.OutboxMessage) error {
+	if msg.EventType != entity.EventProductPriceChanged || !h.markSent(msg.ID) {
+		return nil
+	}
+	var event struct {
+		Data entity.PriceChangedOutput `json:"data"`
+	}
+	if err := json.Unmarshal(msg.Payload, &event); err != nil {
+		// retrying would not make the payload valid
+		return nil
+	}
+	data, err := json.Marshal(Message{Type: MessagePriceChanged, EventID: msg.ID, Data: &event.Data})
+	if err != nil {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if c.follows(event.Data.ID, event.Data.Category) {
+			c.send(data)
+		}
+	}
+	return nil
+}
+
+// markSent records id and reports whether it was not sent yet.
+func (h *Hub) markSent(id uint64) bool {
+	h.sentMu.Lock()
+	defer h.sentMu.Unlock()
+	if h.sentID[id] {
+		return false
+	}
+	if len(h.sent) < recentMessages {
+		h.sent = append(h.sent, id)
+	} else {
+		delete(h.sentID, h.sent[h.next])
+		h.sent[h.next] = id
+		h.next = (h.next + 1) % recentMessages
+	}
+	h.sentID[id] = true
+	return true
+}
+
+// Close ends every client and refuses new ones, so the connections close
+// when the server shuts down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for c := range h.clients {
+		delete(h.clients, c)
+		c.end(ErrHubClosed)
+	}
+}
+
+// Client is a connection to the hub. C receives the encoded messages to
+// write, and is closed when the client ends.
+type Client struct {
+	C <-chan []byte
+
+	hub        *Hub
+	mu         sync.Mutex
+	queue      chan []byte
+	products   map[string]bool
+	categories map[string]bool
+	ended      bool
+	err        error
+	dropped    int
+}
+
+// Handle applies a request of the client and returns the reply.
+func (c *Client) Handle(req Request) Message {
+	switch req.Type {
+	case MessageSubscribe:
+		m, err := c.Subscribe(req.Products, req.Categories)
+		if err != nil {
+			return ErrorMessage(err)
+		}
+		return m
+	case MessageUnsubscribe:
+		return c.Unsubscribe(req.Products, req.Categories)
+	}
+	return ErrorMessage(fmt.Errorf("type must be %s or %s", MessageSubscribe, MessageUnsubscribe))
+}
+
+// Subscribe follows the price changes of products and categories, and
+// returns everything the client follows. Categories match regardless of
+// case.
+func (c *Client) Subscribe(products, categories []string) (Message, error) {
+	if len(products) == 0 && len(categories) == 0 {
+		return Message{}, ErrNothingToSubscribe
+	}
+	for _, id := range products {
+		if _, err := entityPkg.ParseID(id); err != nil {
+			return Message{}, fmt.Errorf("%w: %q", entity.ErrInvalidID, id)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	add := 0
+	for _, id := range products {
+		if !c.products[id] {
+			add++
+		}
+	}
+	for _, category := range categories {
+		if !c.categories[strings.ToLower(category)] {
+			add++
+		}
+	}
+	if c.hub.MaxSubscriptions > 0 && len(c.products)+len(c.categories)+add > c.hub.MaxSubscriptions {
+		return Message{}, fmt.Errorf("%w, the limit is %d", ErrTooManySubscriptions, c.hub.MaxSubscriptions)
+	}
+	for _, id := range products {
+		c.products[id] = true
+	}
+	for _, category := range categories {
+		c.categories[strings.ToLower(category)] = true
+	}
+	return c.subscribed(), nil
+}
+
+// Unsubscribe stops following products and categories, and returns what
+// the client still follows.
+func (c *Client) Unsubscribe(products, categories []string) Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range products {
+		delete(c.products, id)
+	}
+	for _, category := range categories {
+		delete(c.categories, strings.ToLower(category))
+	}
+	return c.subscribed()
+}
+
+func (c *Client) subscribed() Message {
+	return Message{Type: MessageSubscribed, Products: keys(c.products), Categories: keys(c.categories)}
+}
+
+func keys(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for key := range set {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (c *Client) follows(productID, category string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.products[productID] || (category != "" && c.categories[strings.ToLower(category)])
+}
+
+// Send queues m, applying the drop policy when the queue is full.
+func (c *Client) Send(m Message) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	c.send(data)
+}
+
+func (c *Client) send(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ended {
+		return
+	}
+	select {
+	case c.queue <- data:
+		return
+	default:
+	}
+
+	c.dropped++
+	switch c.hub.Policy {
+	case DropNewest:
+	case Disconnect:
+		// the connection closes, and its handler unregisters the client
+		c.ended = true
+		c.err = ErrClientTooSlow
+		close(c.queue)
+	default:
+		// the writer may free a slot meanwhile, so neither step blocks
+		select {
+		case <-c.queue:
+		default:
+		}
+		select {
+		case c.queue <- data:
+		default:
+		}
+	}
+}
+
+// end closes the queue once, recording why the client ended.
+func (c *Client) end(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ended {
+		return
+	}
+	c.ended = true
+	c.err = err
+	close(c.queue)
+}
+
+// Err tells why the client ended: ErrHubClosed, ErrClientTooSlow, or nil
+// when it was unregistered.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Dropped returns how many messages the drop policy discarded.
+func (c *Client) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
@@ -0,0 +1,171 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func priceChanged(t *testing.T, id uint64, productID, category string, price float64) *entity.OutboxMessage {
+	product := &entity.Product{Name: "Product", Price: price, Category: category}
+	product.ID, _ = entityPkg.ParseID(productID)
+	msg, err := entity.NewOutboxMessage(entity.NewEvent(entity.EventProductPriceChanged,
+		entity.NewPriceChangedOutput(product, price-1)), entity.AggregateProduct, productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.ID = id
+	return msg
+}
+
+func receive(t *testing.T, c *Client) Message {
+	var m Message
+	select {
+	case data := <-c.C:
+		assert.Nil(t, json.Unmarshal(data, &m))
+	default:
+		t.Error("expected a message")
+	}
+	return m
+}
+
+func newHub(t *testing.T, queueSize int, policy string) *Hub {
+	h, err := NewHub(queueSize, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestNewHubRequiresPolicy(t *testing.T) {
+	_, err := NewHub(10, "fifo")
+	assert.Equal(t, ErrInvalidPolicy, err)
+}
+
+func TestPublishToFollowers(t *testing.T) {
+	h := newHub(t, 10, DropOldest)
+	first, second := entityPkg.NewID().String(), entityPkg.NewID().String()
+	byProduct, byCategory, other := h.Register(), h.Register(), h.Register()
+	_, err := byProduct.Subscribe([]string{first}, nil)
+	assert.Nil(t, err)
+	_, err = byCategory.Subscribe(nil, []string{"Drinks"})
+	assert.Nil(t, err)
+	_, err = other.Subscribe([]string{entityPkg.NewID().String()}, []string{"food"})
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	assert.Nil(t, h.Publish(ctx, priceChanged(t, 1, first, "", 10)))
+	assert.Nil(t, h.Publish(ctx, priceChanged(t, 2, second, "drinks", 20)))
+	// other events are ignored
+	assert.Nil(t, h.Publish(ctx, &entity.OutboxMessage{ID: 3, EventType: entity.EventProductUpdated, AggregateID: first}))
+
+	m := receive(t, byProduct)
+	assert.Equal(t, MessagePriceChanged, m.Type)
+	assert.Equal(t, uint64(1), m.EventID)
+	assert.Equal(t, &entity.PriceChangedOutput{ID: first, Name: "Product", OldPrice: 9, Price: 10}, m.Data)
+	assert.Len(t, byProduct.C, 0)
+
+	m = receive(t, byCategory)
+	assert.Equal(t, uint64(2), m.EventID)
+	assert.Equal(t, second, m.Data.ID)
+	assert.Len(t, byCategory.C, 0)
+
+	assert.Len(t, other.C, 0)
+}
+
+func TestRepublishedMessageIsSentOnce(t *testing.T) {
+	h := newHub(t, 10, DropOldest)
+	id := entityPkg.NewID().String()
+	c := h.Register()
+	c.Subscribe([]string{id}, nil)
+	ctx := context.Background()
+
+	// the relay publishes a message again when another publisher failed
+	assert.Nil(t, h.Publish(ctx, priceChanged(t, 1, id, "", 10)))
+	assert.Nil(t, h.Publish(ctx, priceChanged(t, 1, id, "", 10)))
+	assert.Equal(t, uint64(1), receive(t, c).EventID)
+	assert.Len(t, c.C, 0)
+
+	// only the last messages are remembered
+	for i := uint64(2); i <= recentMessages+1; i++ {
+		h.markSent(i)
+	}
+	assert.Nil(t, h.Publish(ctx, priceChanged(t, 1, id, "", 10)))
+	assert.Equal(t, uint64(1), receive(t, c).EventID)
+}
+
+func TestHandleRequests(t *testing.T) {
+	h := newHub(t, 10, DropOldest)
+	c := h.Register()
+	id := entityPkg.NewID().String()
+
+	m := c.Handle(Request{Type: MessageSubscribe, Products: []string{id}, Categories: []string{"Drinks", "food"}})
+	assert.Equal(t, Message{Type: MessageSubscribed, Products: []string{id}, Categories: []string{"drinks", "food"}}, m)
+
+	m = c.Handle(Request{Type: MessageUnsubscribe, Categories: []string{"FOOD"}})
+	assert.Equal(t, Message{Type: MessageSubscribed, Products: []string{id}, Categories: []string{"drinks"}}, m)
+
+	assert.Equal(t, MessageError, c.Handle(Request{Type: MessageSubscribe}).Type)
+	assert.Equal(t, MessageError, c.Handle(Request{Type: MessageSubscribe, Products: []string{"abc"}}).Type)
+	assert.Equal(t, MessageError, c.Handle(Request{Type: "ping"}).Type)
+
+	h.MaxSubscriptions = 3
+	_, err := c.Subscribe(nil, []string{"a", "b"})
+	assert.True(t, errors.Is(err, ErrTooManySubscriptions))
+	// following again what is already followed does not count
+	_, err = c.Subscribe([]string{id}, []string{"drinks", "a"})
+	assert.Nil(t, err)
+}
+
+func TestDropPolicies(t *testing.T) {
+	ctx := context.Background()
+	id := entityPkg.NewID().String()
+	publish := func(h *Hub) *Client {
+		c := h.Register()
+		c.Subscribe([]string{id}, nil)
+		for i := uint64(1); i <= 3; i++ {
+			h.Publish(ctx, priceChanged(t, i, id, "", float64(i)))
+		}
+		return c
+	}
+
+	c := publish(newHub(t, 2, DropOldest))
+	assert.Equal(t, uint64(2), receive(t, c).EventID)
+	assert.Equal(t, uint64(3), receive(t, c).EventID)
+	assert.Equal(t, 1, c.Dropped())
+
+	c = publish(newHub(t, 2, DropNewest))
+	assert.Equal(t, uint64(1), receive(t, c).EventID)
+	assert.Equal(t, uint64(2), receive(t, c).EventID)
+	assert.Equal(t, 1, c.Dropped())
+
+	h := newHub(t, 2, Disconnect)
+	c = publish(h)
+	assert.Equal(t, uint64(1), receive(t, c).EventID)
+	assert.Equal(t, uint64(2), receive(t, c).EventID)
+	_, open := <-c.C
+	assert.False(t, open)
+	assert.Equal(t, ErrClientTooSlow, c.Err())
+	h.Unregister(c)
+	assert.Equal(t, 0, h.Clients())
+}
+
+func TestCloseEndsClients(t *testing.T) {
+	h := newHub(t, 10, DropOldest)
+	c := h.Register()
+	other := h.Register()
+	h.Unregister(other)
+	assert.Nil(t, other.Err())
+
+	h.Close()
+	_, open := <-c.C
+	assert.False(t, open)
+	assert.Equal(t, ErrHubClosed, c.Err())
+	assert.Nil(t, h.Register())
+	assert.Equal(t, 0, h.Clients())
+}
@@ -0,0 +1,41 @@
+package realtime
+
+import "github.com/leobelini-studies/go_expert_api/internal/entity"
+
+// Types of the messages sent by the clients.
+const (
+	MessageSubscribe   = "subscribe"
+	MessageUnsubscribe = "unsubscribe"
+)
+
+// Types of the messages sent to the clients.
+const (
+	MessageSubscribed   = "subscribed"
+	MessagePriceChanged = "price_changed"
+	MessageError        = "error"
+)
+
+// Request is a message sent by a client, e.g.
+// {"type":"subscribe","products":["<id>"],"categories":["drinks"]}.
+type Request struct {
+	Type       string   `json:"type"`
+	Products   []string `json:"products,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+// Message is a message sent to a client. Subscribed messages list what the
+// client follows, price_changed ones carry the change and the ID of its
+// event, and error ones a description of the request refused.
+type Message struct {
+	Type       string                     `json:"type"`
+	Products   []string                   `json:"products,omitempty"`
+	Categories []string                   `json:"categories,omitempty"`
+	EventID    uint64                     `json:"event_id,omitempty"`
+	Data       *entity.PriceChangedOutput `json:"data,omitempty"`
+	Error      string                     `json:"error,omitempty"`
+}
+
+// ErrorMessage describes a request refused.
+func ErrorMessage(err error) Message {
+	return Message{Type: MessageError, Error: err.Error()}
+}
@@ -0,0 +1,115 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Client authentication modes accepted by ParseClientAuth.
+const (
+	ClientAuthNone    = "none"
+	ClientAuthRequest = "request"
+	ClientAuthVerify  = "verify_if_given"
+	ClientAuthRequire = "require"
+)
+
+// Config points to the PEM files used to serve HTTPS. ClientCAFile and
+// ClientAuth are only needed for mutual TLS.
+type Config struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	ClientAuth   string
+}
+
+// Enabled reports whether a certificate was configured.
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// Load builds the server TLS configuration. It returns nil when TLS is not
+// enabled.
+func Load(c Config) (*tls.Config, error) {
+	if !c.Enabled() {
+		if c.ClientCAFile != "" {
+			return nil, errors.New("client CA requires a server certificate")
+		}
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("both certificate and key files are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+	clientAuth, err := ParseClientAuth(c.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+	}
+	if clientAuth >= tls.VerifyClientCertIfGiven && cfg.ClientCAs == nil {
+		return nil, errors.New("verifying client certificates requires a client CA")
+	}
+	return cfg, nil
+}
+
+// ParseClientAuth maps a mode name to its tls.ClientAuthType. An empty name
+// means ClientAuthNone.
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(mode) {
+	case "", ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthRequest:
+		return tls.RequestClientCert, nil
+	case ClientAuthVerify:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("unknown client auth mode %q", mode)
+}
+
+// RedirectHandler sends plain HTTP requests to the same URL over HTTPS on
+// httpsPort.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			// bare IPv6 address
+			host = "[" + host + "]"
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		// 308 keeps the method and body of non-GET requests
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,161 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	pair    tls.Certificate
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert issues a certificate signed by parent, or a self-signed CA
+// when parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	c := &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+	c.pair, _ = tls.X509KeyPair(c.certPEM, c.keyPEM)
+	return c
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDisabled(t *testing.T) {
+	cfg, err := Load(Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, cfg)
+
+	_, err = Load(Config{ClientCAFile: "ca.pem"})
+	assert.NotNil(t, err)
+}
+
+func TestLoadRejectsIncompleteConfig(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, 0)
+	server := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth)
+	certFile := writeFile(t, "cert.pem", server.certPEM)
+	keyFile := writeFile(t, "key.pem", server.keyPEM)
+
+	_, err := Load(Config{CertFile: certFile})
+	assert.NotNil(t, err)
+
+	_, err = Load(Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire})
+	assert.NotNil(t, err)
+
+	_, err = Load(Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: "sometimes"})
+	assert.NotNil(t, err)
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, 0)
+	server := newTestCert(t, "server", ca, x509.ExtKeyUsageServerAuth)
+	client := newTestCert(t, "client", ca, x509.ExtKeyUsageClientAuth)
+
+	cfg, err := Load(Config{
+		CertFile:     writeFile(t, "cert.pem", server.certPEM),
+		KeyFile:      writeFile(t, "key.pem", server.keyPEM),
+		ClientCAFile: writeFile(t, "ca.pem", ca.certPEM),
+		ClientAuth:   ClientAuthRequire,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = cfg
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: certs,
+		}}}
+	}
+
+	_, err = newClient().Get(ts.URL)
+	assert.NotNil(t, err)
+
+	res, err := newClient(client.pair).Get(ts.URL)
+	if assert.Nil(t, err) {
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port, host, target, location string
+	}{
+		{"8443", "api.example.com:8080", "/products?page=2", "https://api.example.com:8443/products?page=2"},
+		{"443", "api.example.com", "/docs/", "https://api.example.com/docs/"},
+		{"443", "[::1]:8080", "/", "https://[::1]/"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		RedirectHandler(tt.port).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+		assert.Equal(t, tt.location, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormPluginName = "tracing"
+	spanKey        = "tracing:span"
+)
+
+// GormPlugin starts a client span for every statement. Spans are children of
+// the span in the statement context, so queries must use db.WithContext to
+// show up under the request that issued them.
+type GormPlugin struct {
+	tracer trace.Tracer
+}
+
+// NewGormPlugin uses tp, or the global tracer provider when tp is nil.
+func NewGormPlugin(tp trace.TracerProvider) *GormPlugin {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &GormPlugin{tracer: tp.Tracer(InstrumentationName)}
+}
+
+func (p *GormPlugin) Name() string {
+	return gormPluginName
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before(gormPluginName+":before_"+h.operation, p.before(h.operation)); err != nil {
+			return err
+		}
+		if err := h.after(gormPluginName+":after_"+h.operation, p.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemKey.String(db.Dialector.Name()),
+			semconv.DBOperationName(operation),
+		}
+		if db.Statement.Table != "" {
+			attrs = append(attrs, semconv.DBCollectionName(db.Statement.Table))
+		}
+		_, span := p.tracer.Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func (p *GormPlugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	// the SQL only has placeholders, values are never recorded
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type record struct {
+	ID   int
+	Name string
+}
+
+func attributeValue(attrs []attribute.KeyValue, key string) string {
+	for _, a := range attrs {
+		if string(a.Key) == key {
+			return a.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestGormPluginCreatesChildSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&record{})
+	assert.Nil(t, db.Use(NewGormPlugin(tp)))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	db.WithContext(ctx).Create(&record{ID: 1, Name: "secret-value"})
+	var found record
+	db.WithContext(ctx).First(&found, 1)
+	db.WithContext(ctx).Exec("SELECT * FROM missing_table")
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 4)
+
+	create, query, raw := spans[0], spans[1], spans[2]
+	assert.Equal(t, "gorm.create", create.Name)
+	assert.Equal(t, "gorm.query", query.Name)
+	assert.Equal(t, "gorm.raw", raw.Name)
+	for _, s := range []tracetest.SpanStub{create, query, raw} {
+		assert.Equal(t, parent.SpanContext().SpanID(), s.Parent.SpanID())
+		assert.Equal(t, trace.SpanKindClient, s.SpanKind)
+		assert.Equal(t, "sqlite", attributeValue(s.Attributes, "db.system"))
+	}
+	assert.Equal(t, "records", attributeValue(query.Attributes, "db.collection.name"))
+	assert.Contains(t, attributeValue(query.Attributes, "db.query.text"), "SELECT * FROM `records`")
+	assert.NotContains(t, attributeValue(create.Attributes, "db.query.text"), "secret-value")
+	assert.Equal(t, codes.Error, raw.Status.Code)
+	assert.Equal(t, codes.Unset, query.Status.Code)
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone, ServiceName: "test", SampleRatio: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), Config{Exporter: "zipkin"})
+	assert.NotNil(t, err)
+}
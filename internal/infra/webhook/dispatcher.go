@@ -0,0 +1,257 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+)
+
+const userAgent = "go_expert_api-webhooks/1.0"
+
+var errWebhookNotFound = errors.New("webhook no longer exists")
+
+type Options struct {
+	// MaxAttempts is how many times a delivery is tried before it is dead.
+	MaxAttempts int
+	// BackoffBase is the wait after the first failure, doubled after each
+	// of the next ones up to BackoffMax.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Workers is how many deliveries are sent at the same time.
+	Workers int
+	// PollInterval is how often retries that became due are looked for.
+	PollInterval time.Duration
+}
+
+// Dispatcher delivers the events published by the outbox relay to the
+// webhooks subscribed to them. Every delivery is stored before it is sent,
+// so the events published before a restart are still delivered after it.
+type Dispatcher struct {
+	Webhooks   database.WebhookInterface
+	Deliveries database.WebhookDeliveryInterface
+	Client     *http.Client
+	Options    Options
+
+	wake chan struct{}
+}
+
+func New(webhooks database.WebhookInterface, deliveries database.WebhookDeliveryInterface, client *http.Client, opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	return &Dispatcher{
+		Webhooks:   webhooks,
+		Deliveries: deliveries,
+		Client:     client,
+		Options:    opts,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Publish stores a delivery of the outbox message for every webhook
+// subscribed to its event. The deliveries are sent in the background, so
+// the outbox relay does not wait for slow receivers.
+func (d *Dispatcher) Publish(ctx context.Context, msg *entity.OutboxMessage) error {
+	webhooks, err := d.Webhooks.FindByEvent(ctx, msg.EventType)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	event := entity.Event{ID: msg.EventID, Type: msg.EventType}
+	deliveries := make([]*entity.WebhookDelivery, len(webhooks))
+	for i, webhook := range webhooks {
+		deliveries[i] = entity.NewWebhookDelivery(webhook.ID, event, msg.Payload)
+	}
+	if err := d.Deliveries.Create(ctx, deliveries); err != nil {
+		return err
+	}
+	d.notify()
+	return nil
+}
+
+// Redeliver schedules a delivery again, usually a dead one, with a fresh
+// set of attempts.
+func (d *Dispatcher) Redeliver(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	delivery.Redeliver()
+	if err := d.Deliveries.Update(ctx, delivery); err != nil {
+		return err
+	}
+	d.notify()
+	return nil
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends the due deliveries until ctx is cancelled, right after events
+// are published and every PollInterval for the retries.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Options.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.DeliverDue(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			slog.Error("failed to deliver webhooks", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue sends the deliveries due at now, Workers at a time, and
+// returns once all of them were tried.
+func (d *Dispatcher) DeliverDue(ctx context.Context, now time.Time) error {
+	limit := d.Options.Workers * 10
+	for ctx.Err() == nil {
+		deliveries, err := d.Deliveries.FindDue(ctx, now, limit)
+		if err != nil {
+			return err
+		}
+
+		webhooks := map[string]*entity.Webhook{}
+		sem := make(chan struct{}, d.Options.Workers)
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			id := delivery.WebhookID.String()
+			webhook, ok := webhooks[id]
+			if !ok {
+				if webhook, err = d.Webhooks.FindByID(ctx, id); err != nil {
+					webhook = nil
+				}
+				webhooks[id] = webhook
+			}
+
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(delivery *entity.WebhookDelivery) {
+				defer func() { <-sem; wg.Done() }()
+				d.deliver(ctx, webhook, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < limit {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, webhook *entity.Webhook, delivery *entity.WebhookDelivery) {
+	log := slog.Default().With("webhook_delivery", delivery.ID.String(), "event", delivery.Event)
+
+	if webhook == nil {
+		delivery.Fail(0, errWebhookNotFound, 0, 0)
+	} else {
+		status, err := d.send(ctx, webhook, delivery)
+		if err == nil {
+			delivery.Succeed(status)
+		} else {
+			delivery.Fail(status, err, d.Options.MaxAttempts, d.backoff(delivery.Attempts+1))
+		}
+	}
+
+	// the outcome is saved even when shutting down
+	if err := d.Deliveries.Update(context.WithoutCancel(ctx), delivery); err != nil {
+		log.Error("failed to save webhook delivery", "error", err)
+		return
+	}
+	switch delivery.Status {
+	case entity.DeliveryStatusSucceeded:
+		log.Info("webhook delivered", "status", delivery.LastStatusCode, "attempts", delivery.Attempts)
+	case entity.DeliveryStatusDead:
+		log.Warn("webhook delivery failed for good", "attempts", delivery.Attempts, "error", delivery.LastError)
+	default:
+		log.Info("webhook delivery failed, retrying", "attempts", delivery.Attempts,
+			"next_attempt_at", delivery.NextAttemptAt, "error", delivery.LastError)
+	}
+}
+
+// send posts the delivery and returns the status of the response, zero
+// when none was received. Any status but 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, webhook *entity.Webhook, delivery *entity.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	// the event ID is the same on every attempt, so receivers can ignore
+	// the events they already handled
+	req.Header.Set(HeaderID, delivery.EventID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drained so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait after the attempt-th failure: BackoffBase doubled for
+// each earlier failure, capped at BackoffMax, with jitter so the retries of
+// a webhook that was down do not all arrive together.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.Options.BackoffBase
+	for i := 1; i < attempt && wait < d.Options.BackoffMax; i++ {
+		wait *= 2
+	}
+	if d.Options.BackoffMax > 0 && wait > d.Options.BackoffMax {
+		wait = d.Options.BackoffMax
+	}
+	if wait <= 1 {
+		return wait
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// Cleanup deletes the deliveries that succeeded more than retention ago,
+// every interval until ctx is cancelled. Dead ones are kept.
+func (d *Dispatcher) Cleanup(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Deliveries.DeleteSucceededBefore(ctx, time.Now().Add(-retention)); err != nil {
+				slog.Error("failed to delete old webhook deliveries", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a webhook endpoint answering with the statuses given, then
+// 200, and recording what it received.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []received
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, received{header: r.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received(nil), rc.requests...)
+}
+
+func newDispatcher(t *testing.T, allowPrivate bool) (*Dispatcher, *database.Webhook, *database.WebhookDelivery) {
+	db := testutil.NewSQLite(t, &entity.Webhook{}, &entity.WebhookDelivery{})
+	webhooks := database.NewWebhook(db)
+	deliveries := database.NewWebhookDelivery(db)
+	d := New(webhooks, deliveries, NewClient(time.Second, allowPrivate), Options{
+		MaxAttempts: 3,
+		Workers:     2,
+	})
+	return d, webhooks, deliveries
+}
+
+// publish publishes an event the way the outbox relay does.
+func publish(t *testing.T, d *Dispatcher, eventType string, data interface{}) {
+	msg, err := entity.NewOutboxMessage(entity.NewEvent(eventType, data), "test", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Publish(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func subscribe(t *testing.T, webhooks *database.Webhook, url string, events ...string) *entity.Webhook {
+	webhook, _, err := entity.NewWebhook(entityPkg.NewID(), url, events, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := webhooks.Create(context.Background(), webhook); err != nil {
+		t.Fatal(err)
+	}
+	return webhook
+}
+
+func TestDeliverSignedEvent(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	d, webhooks, deliveries := newDispatcher(t, true)
+	webhook := subscribe(t, webhooks, server.URL, entity.EventProductCreated)
+	subscribe(t, webhooks, server.URL, entity.EventUserCreated)
+	ctx := context.Background()
+
+	product, _ := entity.NewProduct("Product", 10)
+	publish(t, d, entity.EventProductCreated, product)
+	assert.Nil(t, d.DeliverDue(ctx, time.Now()))
+
+	requests := rc.received()
+	assert.Len(t, requests, 1)
+	req := requests[0]
+	assert.Equal(t, entity.EventProductCreated, req.header.Get(HeaderEvent))
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	assert.Nil(t, Verify("secret", req.header.Get(HeaderTimestamp), req.header.Get(HeaderSignature), req.body, time.Minute, time.Now()))
+	assert.Equal(t, ErrInvalidSignature, Verify("other", req.header.Get(HeaderTimestamp), req.header.Get(HeaderSignature), req.body, time.Minute, time.Now()))
+
+	var event struct {
+		ID   string         `json:"id"`
+		Type string         `json:"type"`
+		Data entity.Product `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(req.body, &event))
+	assert.Equal(t, req.header.Get(HeaderID), event.ID)
+	assert.Equal(t, entity.EventProductCreated, event.Type)
+	assert.Equal(t, product.ID, event.Data.ID)
+
+	stored, _ := deliveries.FindByWebhook(ctx, webhook.ID.String(), entity.DeliveryStatusSucceeded, 10)
+	assert.Len(t, stored, 1)
+	assert.Equal(t, 1, stored[0].Attempts)
+	assert.Equal(t, http.StatusOK, stored[0].LastStatusCode)
+}
+
+func TestRepublishedEventIsDeliveredOnce(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	d, webhooks, _ := newDispatcher(t, true)
+	subscribe(t, webhooks, server.URL, entity.EventProductCreated)
+	ctx := context.Background()
+
+	msg, err := entity.NewOutboxMessage(entity.NewEvent(entity.EventProductCreated, nil), "test", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the relay retries the whole message when a later publisher fails
+	assert.Nil(t, d.Publish(ctx, msg))
+	assert.Nil(t, d.DeliverDue(ctx, time.Now()))
+	assert.Nil(t, d.Publish(ctx, msg))
+	assert.Nil(t, d.DeliverDue(ctx, time.Now()))
+
+	assert.Len(t, rc.received(), 1)
+}
+
+func TestRetryUntilDead(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusBadGateway}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	d, webhooks, deliveries := newDispatcher(t, true)
+	d.Options.BackoffBase = time.Minute
+	d.Options.BackoffMax = time.Hour
+	webhook := subscribe(t, webhooks, server.URL, entity.EventUserCreated)
+	ctx := context.Background()
+
+	publish(t, d, entity.EventUserCreated, map[string]string{"id": "1"})
+	assert.Nil(t, d.DeliverDue(ctx, time.Now()))
+
+	stored, _ := deliveries.FindByWebhook(ctx, webhook.ID.String(), "", 10)
+	delivery := stored[0]
+	assert.Equal(t, entity.DeliveryStatusPending, delivery.Status)
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+	// not due again before the backoff
+	assert.Nil(t, d.DeliverDue(ctx, time.Now()))
+	assert.Len(t, rc.received(), 1)
+
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, d.DeliverDue(ctx, time.Now().Add(3*time.Hour)))
+	}
+	requests := rc.received()
+	assert.Len(t, requests, 3)
+	// every attempt carries the same event
+	assert.Equal(t, requests[0].header.Get(HeaderID), requests[2].header.Get(HeaderID))
+	assert.Equal(t, requests[0].body, requests[2].body)
+
+	dead, _ := deliveries.FindByWebhook(ctx, webhook.ID.String(), entity.DeliveryStatusDead, 10)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, 3, dead[0].Attempts)
+	assert.Equal(t, http.StatusBadGateway, dead[0].LastStatusCode)
+
+	assert.Nil(t, d.Redeliver(ctx, dead[0]))
+	assert.Nil(t, d.DeliverDue(ctx, time.Now()))
+	assert.Len(t, rc.received(), 4)
+	stored, _ = deliveries.FindByWebhook(ctx, webhook.ID.String(), entity.DeliveryStatusSucceeded, 10)
+	assert.Len(t, stored, 1)
+}
+
+func TestRefusePrivateAddresses(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	d, webhooks, deliveries := newDispatcher(t, false)
+	webhook := subscribe(t, webhooks, server.URL, entity.EventProductDeleted)
+	ctx := context.Background()
+
+	publish(t, d, entity.EventProductDeleted, entity.DeletedOutput{ID: "1"})
+	assert.Nil(t, d.DeliverDue(ctx, time.Now()))
+
+	assert.Empty(t, rc.received())
+	stored, _ := deliveries.FindByWebhook(ctx, webhook.ID.String(), "", 10)
+	assert.Equal(t, 1, stored[0].Attempts)
+	assert.Contains(t, stored[0].LastError, ErrPrivateAddress.Error())
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{Options: Options{BackoffBase: time.Second, BackoffMax: 10 * time.Second}}
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 10 * time.Second} {
+		wait := d.backoff(attempt)
+		assert.GreaterOrEqual(t, wait, max/2)
+		assert.LessOrEqual(t, wait, max)
+	}
+}
+
+func TestVerifyExpiredTimestamp(t *testing.T) {
+	body := []byte(`{}`)
+	old := time.Now().Add(-time.Hour).Unix()
+	signature := Sign("secret", old, body)
+	err := Verify("secret", strconv.FormatInt(old, 10), signature, body, time.Minute, time.Now())
+	assert.Equal(t, ErrExpiredTimestamp, err)
+	err = Verify("secret", "yesterday", signature, body, time.Minute, time.Now())
+	assert.Equal(t, ErrInvalidSignature, err)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+	"net/http"
+	"time"
+)
+
+type APIKeyHandler struct {
+	APIKeyDB database.APIKeyInterface
+}
+
+func NewAPIKeyHandler(db database.APIKeyInterface) *APIKeyHandler {
+	return &APIKeyHandler{
+		APIKeyDB: db,
+	}
+}
+
+// CreateAPIKey Create API key godoc
+// @Summary     Create API key
+// @Description Create an API key for the authenticated user. The key is only returned once; send it in the X-API-Key header.
+// @Tags        api_keys
+// @Accept      json
+// @Produce     json
+// @Param       resquest body dto.CreateAPIKeyInput true "api key request"
+// @Success     201 {object} dto.CreateAPIKeyOutput
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /users/api_keys [post]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateAPIKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	userID, err := subjectFromToken(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	var expiresAt *time.Time
+	if input.ExpiresIn > 0 {
+		t := time.Now().Add(time.Second * time.Duration(input.ExpiresIn))
+		expiresAt = &t
+	}
+
+	key, plain, err := entity.NewAPIKey(userID, input.Name, input.Scopes, expiresAt)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	if err := h.APIKeyDB.Create(r.Context(), key); err != nil {
+		w.WriteHeader(render.ErrorStatus(err, http.StatusInternalServerError))
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	output := dto.CreateAPIKeyOutput{
+		APIKeyOutput: toAPIKeyOutput(key),
+		Key:          plain,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(output)
+}
+
+// GetAPIKeys List API keys godoc
+// @Summary     List API keys
+// @Description List the API keys of the authenticated user
+// @Tags        api_keys
+// @Accept      json
+// @Produce     json
+// @Success     200 {array} dto.APIKeyOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /users/api_keys [get]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := subjectFromToken(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	keys, err := h.APIKeyDB.FindByUser(r.Context(), userID.String())
+	if err != nil {
+		w.WriteHeader(render.ErrorStatus(err, http.StatusInternalServerError))
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	output := make([]dto.APIKeyOutput, 0, len(keys))
+	for _, key := range keys {
+		output = append(output, toAPIKeyOutput(key))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// RevokeAPIKey Revoke API key godoc
+// @Summary     Revoke API key
+// @Description Revoke an API key of the authenticated user
+// @Tags        api_keys
+// @Accept      json
+// @Produce     json
+// @Param       id path string true "api key ID" Format(uuid)
+// @Success     200
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     404 {object} dto.ErrorOutput
+// @Router      /users/api_keys/{id} [delete]
+// @Security ApiKeyAuth
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	userID, err := subjectFromToken(r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	if err := h.APIKeyDB.Revoke(r.Context(), userID.String(), id); err != nil {
+		w.WriteHeader(render.ErrorStatus(err, http.StatusNotFound))
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func toAPIKeyOutput(key *entity.APIKey) dto.APIKeyOutput {
+	return dto.APIKeyOutput{
+		ID:         key.ID.String(),
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
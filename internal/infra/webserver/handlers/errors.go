@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+)
+
+// renderError writes message as a dto.ErrorOutput in the format negotiated
+// for r.
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Render(w, r, status, dto.ErrorOutput{Message: message})
+}
+
+// StatusError is the failure of an operation shared with other transports,
+// such as gRPC, with the HTTP status it is answered with.
+type StatusError struct {
+	Status int
+	// RetryAfter is set when the call may succeed later, such as a login
+	// from a locked out account.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// renderStatusError answers with the status of a *StatusError, or 500 for
+// other errors.
+func renderStatusError(w http.ResponseWriter, r *http.Request, err error) {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if statusErr.RetryAfter > 0 {
+		tooManyAttempts(w, r, statusErr.RetryAfter)
+		return
+	}
+	renderError(w, r, statusErr.Status, err.Error())
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/infra/broker"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+)
+
+// eventReset tells a client resuming a stream that events were missed, so
+// it must reload what it shows.
+const eventReset = "reset"
+
+type EventHandler struct {
+	Broker *broker.Broker
+	// Heartbeat is how often a comment is sent on idle streams, so proxies
+	// keep them open and dead connections are noticed.
+	Heartbeat time.Duration
+	// WriteTimeout bounds each write: a client that stopped reading is
+	// disconnected once it expires.
+	WriteTimeout time.Duration
+	// Retry is the reconnection delay advised to clients.
+	Retry time.Duration
+	// MaxClients caps the open streams; zero means no limit.
+	MaxClients int
+}
+
+func NewEventHandler(b *broker.Broker) *EventHandler {
+	return &EventHandler{
+		Broker:       b,
+		Heartbeat:    15 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		Retry:        3 * time.Second,
+	}
+}
+
+// ProductEvents Stream product events godoc
+// @Summary     Stream product events
+// @Description Server-Sent Events stream of product.created, product.updated, product.deleted and product.price_changed events. Each event has an id; reconnecting with the Last-Event-ID header (sent by EventSource) or the last_event_id query parameter resumes after it. When the events after it are no longer buffered a "reset" event is sent first and the client should reload the products. Browsers may send the token in the access_token query parameter. Clients that fall behind are disconnected and expected to resume.
+// @Tags        products
+// @Produce     text/event-stream
+// @Param       Last-Event-ID header string false "ID of the last event received"
+// @Param       last_event_id query string false "ID of the last event received"
+// @Param       access_token query string false "access token, for clients that cannot set headers"
+// @Success     200 {string} string "event stream"
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     503 {object} dto.ErrorOutput
+// @Router      /products/events [get]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *EventHandler) ProductEvents(w http.ResponseWriter, r *http.Request) {
+	var lastID *uint64
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("last_event_id")
+	}
+	if last != "" {
+		id, err := strconv.ParseUint(last, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid last event id"))
+			return
+		}
+		lastID = &id
+	}
+
+	if h.MaxClients > 0 && h.Broker.Subscribers() >= h.MaxClients {
+		w.Header().Set("Retry-After", strconv.Itoa(int(h.Retry.Seconds())))
+		writeError(w, http.StatusServiceUnavailable, errors.New("too many event streams, try again later"))
+		return
+	}
+	sub, missed, resumed := h.Broker.Subscribe(lastID)
+	if sub == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("server shutting down"))
+		return
+	}
+	defer h.Broker.Unsubscribe(sub)
+
+	stream := &eventStream{w: w, rc: http.NewResponseController(w), timeout: h.WriteTimeout}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keeps nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	err := stream.write(fmt.Sprintf("retry: %d\n\n", h.Retry.Milliseconds()))
+	if err == nil && lastID != nil && !resumed {
+		err = stream.write("event: " + eventReset + "\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		if err != nil {
+			break
+		}
+		err = stream.event(event)
+	}
+
+	log := logger.FromContext(r.Context())
+	// the stream lasts as long as the client stays connected
+	ctx := middlewares.WithoutDeadline(r.Context())
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				if sub.Dropped() {
+					log.Warn("event stream dropped, client too slow")
+				}
+				return
+			}
+			err = stream.event(event)
+		case <-heartbeat.C:
+			err = stream.write(": heartbeat\n\n")
+		}
+	}
+	log.Info("event stream closed", "error", err)
+}
+
+// eventStream writes Server-Sent Events, flushing each one.
+type eventStream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (s *eventStream) event(event broker.Event) error {
+	return s.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data))
+}
+
+func (s *eventStream) write(data string) error {
+	if s.timeout > 0 {
+		_ = s.rc.SetWriteDeadline(time.Now().Add(s.timeout))
+	}
+	if _, err := s.w.Write([]byte(data)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
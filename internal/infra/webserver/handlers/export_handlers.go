@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/exporter"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+)
+
+type ExportHandler struct {
+	Exporter    *exporter.Exporter
+	Runner      *exporter.Runner
+	ExportJobDB database.ExportJobInterface
+	// TTL is how long the file of a background export can be downloaded.
+	TTL time.Duration
+}
+
+func NewExportHandler(exp *exporter.Exporter, runner *exporter.Runner, jobs database.ExportJobInterface, ttl time.Duration) *ExportHandler {
+	return &ExportHandler{
+		Exporter:    exp,
+		Runner:      runner,
+		ExportJobDB: jobs,
+		TTL:         ttl,
+	}
+}
+
+// ExportProducts Export products godoc
+// @Summary     Export products
+// @Description Export every product as CSV, NDJSON or an XLSX spreadsheet, in the order of the listing. The file is streamed as it is read from the database. With async=true the export is written in the background and downloaded from the download_url of the job polled at the returned Location.
+// @Tags        products
+// @Produce     text/csv
+// @Produce     application/x-ndjson
+// @Produce     application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce     json
+// @Param       format query string false "file format" Enums(csv, ndjson, xlsx) default(csv)
+// @Param       columns query string false "comma separated columns among id, name, price, sku, category and created_at; all by default"
+// @Param       sort query string false "creation order" Enums(asc, desc)
+// @Param       async query bool false "export in the background"
+// @Success     200 {file} file
+// @Success     202 {object} entity.ExportJob
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Failure     503 {object} dto.ErrorOutput
+// @Router      /products/export [get]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *ExportHandler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ExportHandler.ExportProducts")
+	defer span.End()
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = entity.ExportFormatCSV
+	}
+	if !entity.IsExportFormat(format) {
+		writeError(w, http.StatusBadRequest, entity.ErrInvalidExportFormat)
+		return
+	}
+	columns, err := exporter.ParseColumns(query.Get("columns"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	sort := query.Get("sort")
+	async, _ := strconv.ParseBool(query.Get("async"))
+
+	if async {
+		h.submit(w, r, format, query.Get("columns"), sort)
+		return
+	}
+
+	res := &exportResponse{
+		w:           w,
+		contentType: exporter.ContentType(format),
+		fileName:    "products-" + time.Now().UTC().Format("20060102-150405") + "." + format,
+	}
+	// the export lasts as long as the client keeps reading
+	ctx := middlewares.WithoutDeadline(r.Context())
+	_, err = h.Exporter.Run(ctx, res, exporter.Options{Format: format, Columns: columns, Sort: sort}, func(int) {
+		if res.started {
+			http.NewResponseController(w).Flush()
+		}
+	})
+	if err == nil {
+		return
+	}
+	if !res.started {
+		writeError(w, render.ErrorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+	// a truncated file must not look complete
+	logger.FromContext(r.Context()).Error("product export aborted", "error", err)
+	panic(http.ErrAbortHandler)
+}
+
+func (h *ExportHandler) submit(w http.ResponseWriter, r *http.Request, format, columns, sort string) {
+	var owner string
+	if p, ok := middlewares.PrincipalFromContext(r.Context()); ok {
+		owner = p.UserID
+	}
+	job, err := entity.NewExportJob(owner, format, columns, sort, h.TTL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.Runner.Submit(r.Context(), job)
+	switch {
+	case errors.Is(err, exporter.ErrQueueFull):
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		writeError(w, render.ErrorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/products/export/"+job.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetExportJob Get export job godoc
+// @Summary     Get export job
+// @Description Get the status of a background export, with its download_url once completed
+// @Tags        products
+// @Produce     json
+// @Param       id path string true "export job ID" Format(uuid)
+// @Success     200 {object} entity.ExportJob
+// @Failure     404 {object} dto.ErrorOutput
+// @Router      /products/export/{id} [get]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *ExportHandler) GetExportJob(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ExportHandler.GetExportJob")
+	defer span.End()
+
+	job, ok := h.findJob(w, r)
+	if !ok {
+		return
+	}
+	if job.IsDownloadable(time.Now()) {
+		job.DownloadURL = "/products/export/" + job.ID.String() + "/download"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// DownloadExport Download export godoc
+// @Summary     Download export
+// @Description Download the file of a completed background export. Range requests are supported.
+// @Tags        products
+// @Produce     text/csv
+// @Produce     application/x-ndjson
+// @Produce     application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param       id path string true "export job ID" Format(uuid)
+// @Success     200 {file} file
+// @Failure     404 {object} dto.ErrorOutput
+// @Failure     409 {object} dto.ErrorOutput
+// @Failure     410 {object} dto.ErrorOutput
+// @Router      /products/export/{id}/download [get]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *ExportHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ExportHandler.DownloadExport")
+	defer span.End()
+
+	job, ok := h.findJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != entity.ExportStatusCompleted {
+		writeError(w, http.StatusConflict, errors.New("export is "+job.Status))
+		return
+	}
+	if !job.IsDownloadable(time.Now()) {
+		writeError(w, http.StatusGone, errors.New("export expired"))
+		return
+	}
+	f, err := h.Runner.Open(job)
+	if err != nil {
+		writeError(w, http.StatusGone, errors.New("export file no longer available"))
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", exporter.ContentType(job.Format))
+	w.Header().Set("Content-Disposition", attachment(job.FileName()))
+	http.ServeContent(w, r, job.FileName(), *job.FinishedAt, f)
+}
+
+// findJob loads the job in the URL, answering 404 when it is missing or
+// belongs to another user.
+func (h *ExportHandler) findJob(w http.ResponseWriter, r *http.Request) (*entity.ExportJob, bool) {
+	job, err := h.ExportJobDB.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, render.ErrorStatus(err, http.StatusNotFound), err)
+		return nil, false
+	}
+	p, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok || job.OwnerID != p.UserID {
+		writeError(w, http.StatusNotFound, errors.New("export job not found"))
+		return nil, false
+	}
+	return job, true
+}
+
+func attachment(fileName string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+}
+
+// exportResponse sends the headers of a download with the first bytes of
+// the file, so an export failing before writing anything is still answered
+// with an error status.
+type exportResponse struct {
+	w           http.ResponseWriter
+	contentType string
+	fileName    string
+	started     bool
+}
+
+func (e *exportResponse) Write(p []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		e.w.Header().Set("Content-Type", e.contentType)
+		e.w.Header().Set("Content-Disposition", attachment(e.fileName))
+		// the response takes as long as the client needs to read it, so
+		// the server write timeout does not apply
+		_ = http.NewResponseController(e.w).SetWriteDeadline(time.Time{})
+		e.w.WriteHeader(http.StatusOK)
+	}
+	return e.w.Write(p)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/graph"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+)
+
+type GraphQLHandler struct {
+	Server *graph.Server
+	// MaxBodyBytes bounds the body of the POST requests.
+	MaxBodyBytes int64
+}
+
+func NewGraphQLHandler(server *graph.Server) *GraphQLHandler {
+	return &GraphQLHandler{Server: server, MaxBodyBytes: 1 << 20}
+}
+
+// Query GraphQL godoc
+// @Summary     GraphQL
+// @Description Runs a GraphQL query or mutation. The schema has products(filter, page, limit, sort), product(id) and me, plus the createProduct, updateProduct and deleteProduct mutations, which follow the rules of the product endpoints. Product fields need the products:read scope and mutations products:write. Queries may also be sent with GET in the query, variables and operationName query parameters; mutations are refused there. Queries deeper or more complex than GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_COMPLEXITY are refused. Errors carry a code in their extensions.
+// @Tags        graphql
+// @Accept      json
+// @Produce     json
+// @Param       request body dto.GraphQLInput true "GraphQL request"
+// @Success     200 {object} dto.GraphQLOutput
+// @Failure     400 {object} dto.GraphQLOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Router      /graphql [post]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var req graph.Request
+	readOnly := r.Method == http.MethodGet
+	if readOnly {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if variables := q.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeGraphQLError(w, r, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, h.MaxBodyBytes)
+		if err := render.Decode(r, &req); err != nil {
+			writeGraphQLError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if req.Query == "" {
+		writeGraphQLError(w, r, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	result := h.Server.Execute(r.Context(), req, readOnly)
+	render.Render(w, r, http.StatusOK, newGraphQLOutput(result))
+}
+
+// GraphiQL serves an in-browser IDE for the GraphQL endpoint. The token is
+// set in its headers editor and not kept in the browser storage.
+func (h *GraphQLHandler) GraphiQL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(graphiQLPage))
+}
+
+func newGraphQLOutput(result *graphql.Result) dto.GraphQLOutput {
+	out := dto.GraphQLOutput{Data: result.Data}
+	for _, err := range result.Errors {
+		e := dto.GraphQLError{Message: err.Message, Path: err.Path, Extensions: err.Extensions}
+		for _, l := range err.Locations {
+			e.Locations = append(e.Locations, dto.GraphQLLocation{Line: l.Line, Column: l.Column})
+		}
+		out.Errors = append(out.Errors, e)
+	}
+	return out
+}
+
+// writeGraphQLError reports requests that cannot be run in the GraphQL
+// error format, which GraphQL clients expect.
+func writeGraphQLError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Render(w, r, status, dto.GraphQLOutput{Errors: []dto.GraphQLError{{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": graph.CodeBadRequest},
+	}}})
+}
+
+const graphiQLPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>GraphiQL</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@3.0.10/graphiql.min.css">
+  <style>body { margin: 0; } #graphiql { height: 100vh; }</style>
+</head>
+<body>
+  <div id="graphiql">Loading...</div>
+  <script crossorigin src="https://unpkg.com/react@18.2.0/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18.2.0/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@3.0.10/graphiql.min.js"></script>
+  <script>
+    ReactDOM.createRoot(document.getElementById('graphiql')).render(
+      React.createElement(GraphiQL, {
+        fetcher: GraphiQL.createFetcher({ url: '/graphql' }),
+        defaultHeaders: '{"Authorization": "Bearer <token>"}',
+      })
+    );
+  </script>
+</body>
+</html>
+`
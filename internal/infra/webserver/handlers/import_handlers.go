@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/importer"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+)
+
+type ImportHandler struct {
+	Importer    *importer.Importer
+	Runner      *importer.Runner
+	ImportJobDB database.ImportJobInterface
+	// SyncMaxBytes bounds files imported within the request; larger files
+	// must be imported with async=true, up to MaxBytes.
+	SyncMaxBytes int64
+	MaxBytes     int64
+}
+
+func NewImportHandler(imp *importer.Importer, runner *importer.Runner, jobs database.ImportJobInterface, syncMaxBytes, maxBytes int64) *ImportHandler {
+	return &ImportHandler{
+		Importer:     imp,
+		Runner:       runner,
+		ImportJobDB:  jobs,
+		SyncMaxBytes: syncMaxBytes,
+		MaxBytes:     maxBytes,
+	}
+}
+
+// ImportProducts Import products godoc
+// @Summary     Import products
+// @Description Import products from a CSV file (columns name, price, sku and category) or NDJSON. Every row is validated and the valid ones are stored in batches; the report lists the rejected rows. With upsert=true rows whose sku exists update the product, otherwise they are rejected. With dry_run=true nothing is stored. With async=true the import runs in the background and its progress is polled at the returned Location.
+// @Tags        products
+// @Accept      text/csv
+// @Accept      application/x-ndjson
+// @Produce     json
+// @Param       file body string true "CSV or NDJSON file"
+// @Param       format query string false "file format, taken from the Content-Type when omitted" Enums(csv, ndjson)
+// @Param       dry_run query bool false "validate without storing"
+// @Param       upsert query bool false "update products with the same sku"
+// @Param       async query bool false "run in the background"
+// @Success     200 {object} entity.ImportReport
+// @Success     202 {object} entity.ImportJob
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     413 {object} dto.ErrorOutput
+// @Failure     415 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Failure     503 {object} dto.ErrorOutput
+// @Router      /products/import [post]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *ImportHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ImportHandler.ImportProducts")
+	defer span.End()
+
+	format := importFormat(r)
+	if format == "" {
+		writeError(w, http.StatusUnsupportedMediaType, entity.ErrInvalidImportFormat)
+		return
+	}
+	query := r.URL.Query()
+	dryRun, _ := strconv.ParseBool(query.Get("dry_run"))
+	upsert, _ := strconv.ParseBool(query.Get("upsert"))
+	async, _ := strconv.ParseBool(query.Get("async"))
+
+	if async {
+		h.submit(w, r, format, dryRun, upsert)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.SyncMaxBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge,
+				fmt.Errorf("files larger than %d bytes must be imported with async=true", h.SyncMaxBytes))
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	rows, err := importer.NewReader(format, bytes.NewReader(data))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report := entity.ImportReport{DryRun: dryRun, Upsert: upsert}
+	// the import may take longer than a single query is allowed to
+	if err := h.Importer.Run(middlewares.WithoutDeadline(r.Context()), rows, &report, nil); err != nil {
+		writeError(w, render.ErrorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func (h *ImportHandler) submit(w http.ResponseWriter, r *http.Request, format string, dryRun, upsert bool) {
+	var owner string
+	if p, ok := middlewares.PrincipalFromContext(r.Context()); ok {
+		owner = p.UserID
+	}
+	job, err := entity.NewImportJob(owner, format, dryRun, upsert)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.Runner.Submit(r.Context(), job, http.MaxBytesReader(w, r.Body, h.MaxBytes))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("files larger than %d bytes cannot be imported", h.MaxBytes))
+		return
+	case errors.Is(err, importer.ErrQueueFull):
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		writeError(w, render.ErrorStatus(err, http.StatusInternalServerError), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/products/import/"+job.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetImportJob Get import job godoc
+// @Summary     Get import job
+// @Description Get the status and progress of a background import
+// @Tags        products
+// @Produce     json
+// @Param       id path string true "import job ID" Format(uuid)
+// @Success     200 {object} entity.ImportJob
+// @Failure     404 {object} dto.ErrorOutput
+// @Router      /products/import/{id} [get]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *ImportHandler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ImportHandler.GetImportJob")
+	defer span.End()
+
+	job, err := h.ImportJobDB.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, render.ErrorStatus(err, http.StatusNotFound), err)
+		return
+	}
+	// jobs of other users are reported as missing
+	p, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok || job.OwnerID != p.UserID {
+		writeError(w, http.StatusNotFound, errors.New("import job not found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(job)
+}
+
+// importFormat reads the format from the query string or the Content-Type.
+func importFormat(r *http.Request) string {
+	switch format := r.URL.Query().Get("format"); format {
+	case entity.ImportFormatCSV, entity.ImportFormatNDJSON:
+		return format
+	case "":
+	default:
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return entity.ImportFormatCSV
+	case contentTypeNDJSON:
+		return entity.ImportFormatNDJSON
+	}
+	return ""
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.ErrorOutput{Message: err.Error()})
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/jwtauth"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"github.com/leobelini-studies/go_expert_api/pkg/totp"
+	"github.com/lestrrat-go/jwx/jwt"
+	"net/http"
+	"time"
+)
+
+const mfaTokenType = "mfa"
+
+var ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+
+// EnrollMFA Enroll MFA godoc
+// @Summary     Start MFA enrollment
+// @Description Generate a TOTP secret and the otpauth provisioning URI to be rendered as a QR code. MFA is only enforced after /users/mfa/activate.
+// @Tags        mfa
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Success     200 {object} dto.EnrollMFAOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     409 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /users/mfa/enroll [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.EnrollMFA")
+	defer span.End()
+
+	u, err := h.userFromToken(r)
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusUnauthorized), err.Error())
+		return
+	}
+
+	secret, err := u.EnrollMFA()
+	if err != nil {
+		renderError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err := h.UserDb.Update(r.Context(), u); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	output := dto.EnrollMFAOutput{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(h.MFAIssuer, u.Email, secret),
+	}
+	render.Render(w, r, http.StatusOK, output)
+}
+
+// ActivateMFA Activate MFA godoc
+// @Summary     Activate MFA
+// @Description Confirm the enrollment with a code from the authenticator app and receive the recovery codes. The recovery codes are only shown once.
+// @Tags        mfa
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Param       resquest body dto.MFACodeInput true "authenticator code"
+// @Success     200 {object} dto.ActivateMFAOutput
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /users/mfa/activate [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) ActivateMFA(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.ActivateMFA")
+	defer span.End()
+
+	var input dto.MFACodeInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	u, err := h.userFromToken(r)
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusUnauthorized), err.Error())
+		return
+	}
+
+	if err := u.EnableMFA(input.Code, time.Now()); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	codes, plain, err := entity.NewRecoveryCodes(u.ID, entity.RecoveryCodesCount)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := h.RecoveryCodeDB.Replace(r.Context(), u.ID.String(), codes); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	if err := h.UserDb.Update(r.Context(), u); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	output := dto.ActivateMFAOutput{
+		RecoveryCodes: plain,
+	}
+	render.Render(w, r, http.StatusOK, output)
+}
+
+// DisableMFA Disable MFA godoc
+// @Summary     Disable MFA
+// @Description Disable MFA with a code from the authenticator app or a recovery code
+// @Tags        mfa
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Param       resquest body dto.DisableMFAInput true "authenticator or recovery code"
+// @Success     200
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /users/mfa/disable [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.DisableMFA")
+	defer span.End()
+
+	var input dto.DisableMFAInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	u, err := h.userFromToken(r)
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusUnauthorized), err.Error())
+		return
+	}
+
+	if err := h.validateSecondFactor(r.Context(), u, input.Code, input.RecoveryCode); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	u.DisableMFA()
+	if err := h.UserDb.Update(r.Context(), u); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	if err := h.RecoveryCodeDB.DeleteByUser(r.Context(), u.ID.String()); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// VerifyMFA Verify MFA godoc
+// @Summary     Finish an MFA login
+// @Description Exchange the mfa_token returned by /users/generate_token and a code from the authenticator app (or a recovery code) for an access token. Each mfa_token can only be used once.
+// @Tags        mfa
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Param       resquest body dto.VerifyMFAInput true "mfa token and code"
+// @Success     200 {object} dto.GetJWTOutput
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     429 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /users/mfa/verify [post]
+func (h *UserHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.VerifyMFA")
+	defer span.End()
+
+	var input dto.VerifyMFAInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output, err := h.VerifyMFALogin(r.Context(), input.MFAToken, input.Code, input.RecoveryCode, middlewares.ClientIP(r))
+	if err != nil {
+		renderStatusError(w, r, err)
+		return
+	}
+	render.Render(w, r, http.StatusOK, output)
+}
+
+// VerifyMFALogin exchanges an MFA token and a TOTP or recovery code for an
+// access token. The MFA token is revoked once used, so it cannot be replayed
+// with another code. Failures are *StatusError.
+func (h *UserHandler) VerifyMFALogin(ctx context.Context, mfaToken, code, recoveryCode, ip string) (dto.GetJWTOutput, error) {
+	u, claims, err := h.userFromMFAToken(ctx, mfaToken)
+	if err != nil {
+		return dto.GetJWTOutput{}, &StatusError{Status: render.ErrorStatus(err, http.StatusUnauthorized), Err: err}
+	}
+
+	if wait := loginAttempt(ctx, h.LoginGuard, u.Email, ip); wait > 0 {
+		return dto.GetJWTOutput{}, &StatusError{Status: http.StatusTooManyRequests, RetryAfter: wait, Err: ErrTooManyAttempts}
+	}
+
+	// a code that could not be checked in time is not a failed attempt, as
+	// in checkCredentials
+	if err := h.validateSecondFactor(ctx, u, code, recoveryCode); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			loginReleased(ctx, h.LoginGuard, u.Email, ip)
+		}
+		return dto.GetJWTOutput{}, &StatusError{Status: render.ErrorStatus(err, http.StatusUnauthorized), Err: err}
+	}
+
+	if h.RevokedTokenDB != nil {
+		if err := h.RevokedTokenDB.Revoke(ctx, claims.JwtID(), claims.Expiration()); err != nil {
+			loginReleased(ctx, h.LoginGuard, u.Email, ip)
+			return dto.GetJWTOutput{}, &StatusError{Status: render.ErrorStatus(err, http.StatusInternalServerError), Err: err}
+		}
+	}
+
+	loginSucceeded(ctx, h.LoginGuard, u.Email, ip)
+
+	token, err := h.issueAccessToken(u)
+	if err != nil {
+		return dto.GetJWTOutput{}, &StatusError{Status: http.StatusInternalServerError, Err: err}
+	}
+
+	return dto.GetJWTOutput{
+		AccessToken: token,
+	}, nil
+}
+
+// validateSecondFactor accepts either a TOTP code or an unused recovery
+// code. Accepted TOTP steps are persisted so a code cannot be replayed.
+// Deadlines reached while checking the code are returned as they are.
+func (h *UserHandler) validateSecondFactor(ctx context.Context, u *entity.User, code, recoveryCode string) error {
+	if code != "" {
+		previous := u.MFALastStep
+		if err := u.ValidateMFACode(code, time.Now()); err != nil {
+			return err
+		}
+		return h.UserDb.UpdateMFALastStep(ctx, u, previous)
+	}
+
+	if recoveryCode != "" {
+		if !u.MFAEnabled {
+			return entity.ErrMFANotEnabled
+		}
+		err := h.RecoveryCodeDB.Consume(ctx, u.ID.String(), entity.HashRecoveryCode(recoveryCode))
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		if err != nil {
+			return entity.ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	return entity.ErrInvalidMFACode
+}
+
+func (h *UserHandler) issueMFAToken(u *entity.User) (string, error) {
+	clains := map[string]interface{}{
+		"sub": u.ID.String(),
+		"jti": entityPkg.NewID().String(),
+		"typ": mfaTokenType,
+		"exp": time.Now().Add(time.Second * time.Duration(h.MFATokenExpiresIn)).Unix(),
+	}
+	_, token, err := h.MFAJwt.Encode(clains)
+	return token, err
+}
+
+// userFromMFAToken returns the user of an MFA token along with its claims.
+// Tokens already used by VerifyMFALogin are rejected.
+func (h *UserHandler) userFromMFAToken(ctx context.Context, tokenString string) (*entity.User, jwt.Token, error) {
+	if h.MFAJwt == nil || tokenString == "" {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	token, err := jwtauth.VerifyToken(h.MFAJwt, tokenString)
+	if err != nil {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	if typ, _ := token.Get("typ"); typ != mfaTokenType || token.JwtID() == "" {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	if h.RevokedTokenDB != nil {
+		revoked, err := h.RevokedTokenDB.IsRevoked(ctx, token.JwtID())
+		if err != nil {
+			return nil, nil, err
+		}
+		if revoked {
+			return nil, nil, ErrInvalidMFAToken
+		}
+	}
+
+	u, err := h.UserDb.FindByID(ctx, token.Subject())
+	if err != nil || !u.MFAEnabled {
+		return nil, nil, ErrInvalidMFAToken
+	}
+	return u, token, nil
+}
+
+// userFromToken loads the user identified by the subject of the access token
+// verified by the authentication middleware.
+func (h *UserHandler) userFromToken(r *http.Request) (*entity.User, error) {
+	id, err := subjectFromToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return h.UserDb.FindByID(r.Context(), id.String())
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/jwtauth"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/lockout"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+	"github.com/lestrrat-go/jwx/jwt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error codes from RFC 6749 section 5.2.
+const (
+	oauthInvalidRequest       = "invalid_request"
+	oauthInvalidClient        = "invalid_client"
+	oauthInvalidGrant         = "invalid_grant"
+	oauthUnauthorizedClient   = "unauthorized_client"
+	oauthUnsupportedGrantType = "unsupported_grant_type"
+	oauthInvalidScope         = "invalid_scope"
+	oauthServerError          = "server_error"
+)
+
+// clientSubjectPrefix marks tokens issued to a client rather than to a user,
+// so user-only routes never mistake a client ID for a user ID.
+const clientSubjectPrefix = "client:"
+
+var ErrInvalidClient = errors.New("invalid client credentials")
+
+type OAuthHandler struct {
+	ClientDB             database.OAuthClientInterface
+	UserDb               database.UserInterface
+	RevokedTokenDB       database.RevokedTokenInterface
+	Jwt                  *jwtauth.JWTAuth
+	JwtExperiesIn        int
+	LoginGuard           *lockout.Guard
+	RequireVerifiedEmail bool
+}
+
+func NewOAuthHandler(clientDB database.OAuthClientInterface, userDB database.UserInterface, revokedDB database.RevokedTokenInterface, Jwt *jwtauth.JWTAuth, JwtExperiesIn int) *OAuthHandler {
+	return &OAuthHandler{
+		ClientDB:       clientDB,
+		UserDb:         userDB,
+		RevokedTokenDB: revokedDB,
+		Jwt:            Jwt,
+		JwtExperiesIn:  JwtExperiesIn,
+	}
+}
+
+// CreateClient Create OAuth client godoc
+// @Summary     Register OAuth client
+// @Description Register a client allowed to request tokens at /oauth/token. The client secret is only returned once.
+// @Tags        admin
+// @Accept      json
+// @Produce     json
+// @Param       resquest body dto.CreateOAuthClientInput true "client request"
+// @Success     201 {object} dto.CreateOAuthClientOutput
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     403 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /admin/oauth/clients [post]
+// @Security ApiKeyAuth
+func (h *OAuthHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	var input dto.CreateOAuthClientInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	client, secret, err := entity.NewOAuthClient(input.Name, input.GrantTypes, input.Scopes)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	if err := h.ClientDB.Create(r.Context(), client); err != nil {
+		w.WriteHeader(render.ErrorStatus(err, http.StatusInternalServerError))
+		error := dto.ErrorOutput{Message: err.Error()}
+		json.NewEncoder(w).Encode(error)
+		return
+	}
+
+	output := dto.CreateOAuthClientOutput{
+		ClientID:     client.ID.String(),
+		ClientSecret: secret,
+		Name:         client.Name,
+		GrantTypes:   strings.Fields(client.GrantTypes),
+		Scopes:       client.ScopeList(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(output)
+}
+
+// Token OAuth token godoc
+// @Summary     OAuth2 token endpoint
+// @Description Issue an access token with the client_credentials or password grant (RFC 6749). The client authenticates with HTTP Basic or client_id/client_secret form fields.
+// @Tags        oauth
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       grant_type    formData string true  "client_credentials or password"
+// @Param       scope         formData string false "space separated scopes"
+// @Param       username      formData string false "user email, password grant only"
+// @Param       password      formData string false "user password, password grant only"
+// @Param       client_id     formData string false "client ID when not using HTTP Basic"
+// @Param       client_secret formData string false "client secret when not using HTTP Basic"
+// @Success     200 {object} dto.OAuthTokenOutput
+// @Failure     400 {object} dto.OAuthErrorOutput
+// @Failure     401 {object} dto.OAuthErrorOutput
+// @Failure     429 {object} dto.OAuthErrorOutput
+// @Router      /oauth/token [post]
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, oauthInvalidRequest, err.Error())
+		return
+	}
+
+	client, err := h.authenticateClient(r)
+	if err != nil {
+		if _, _, ok := r.BasicAuth(); ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		oauthError(w, http.StatusUnauthorized, oauthInvalidClient, err.Error())
+		return
+	}
+
+	grantType := r.PostForm.Get("grant_type")
+	if grantType != entity.GrantTypeClientCredentials && grantType != entity.GrantTypePassword {
+		oauthError(w, http.StatusBadRequest, oauthUnsupportedGrantType, "")
+		return
+	}
+	if !client.AllowsGrant(grantType) {
+		oauthError(w, http.StatusBadRequest, oauthUnauthorizedClient, "grant type not allowed for this client")
+		return
+	}
+
+	scopes, err := client.GrantScopes(strings.Fields(r.PostForm.Get("scope")))
+	if err != nil {
+		oauthError(w, http.StatusBadRequest, oauthInvalidScope, err.Error())
+		return
+	}
+	scope := strings.Join(scopes, " ")
+
+	claims := map[string]interface{}{
+		"client_id": client.ID.String(),
+		"scope":     scope,
+	}
+	subject := clientSubjectPrefix + client.ID.String()
+
+	if grantType == entity.GrantTypePassword {
+		email := r.PostForm.Get("username")
+		ip := middlewares.ClientIP(r)
+		u, wait, err := checkCredentials(r.Context(), h.UserDb, h.LoginGuard, email, r.PostForm.Get("password"), ip)
+		if wait > 0 {
+			tooManyAttempts(w, r, wait)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			oauthError(w, http.StatusGatewayTimeout, oauthServerError, err.Error())
+			return
+		}
+		if err != nil {
+			oauthError(w, http.StatusBadRequest, oauthInvalidGrant, err.Error())
+			return
+		}
+		if u.MFAEnabled {
+			loginReleased(r.Context(), h.LoginGuard, email, ip)
+		} else {
+			loginSucceeded(r.Context(), h.LoginGuard, email, ip)
+		}
+		if h.RequireVerifiedEmail && !u.IsEmailVerified() {
+			oauthError(w, http.StatusBadRequest, oauthInvalidGrant, "email not verified")
+			return
+		}
+		if u.MFAEnabled {
+			oauthError(w, http.StatusBadRequest, oauthInvalidGrant, "mfa is enabled for this user, use /users/generate_token")
+			return
+		}
+
+		subject = u.ID.String()
+		claims["role"] = u.Role
+	}
+
+	token, err := newAccessToken(h.Jwt, subject, h.JwtExperiesIn, claims)
+	if err != nil {
+		oauthError(w, http.StatusInternalServerError, oauthServerError, err.Error())
+		return
+	}
+
+	output := dto.OAuthTokenOutput{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   h.JwtExperiesIn,
+		Scope:       scope,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// Introspect OAuth token introspection godoc
+// @Summary     OAuth2 token introspection
+// @Description Tell whether an access token is active and return its claims (RFC 7662). Requires client authentication.
+// @Tags        oauth
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       token           formData string true  "access token"
+// @Param       token_type_hint formData string false "ignored, only access tokens are issued"
+// @Success     200 {object} dto.IntrospectionOutput
+// @Failure     401 {object} dto.OAuthErrorOutput
+// @Router      /oauth/introspect [post]
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, oauthInvalidRequest, err.Error())
+		return
+	}
+
+	if _, err := h.authenticateClient(r); err != nil {
+		oauthError(w, http.StatusUnauthorized, oauthInvalidClient, err.Error())
+		return
+	}
+
+	output := dto.IntrospectionOutput{Active: false}
+
+	token, err := jwtauth.VerifyToken(h.Jwt, r.PostForm.Get("token"))
+	if err == nil && h.isActive(r.Context(), token) {
+		scope, _ := token.Get("scope")
+		clientID, _ := token.Get("client_id")
+		output = dto.IntrospectionOutput{
+			Active:    true,
+			Sub:       token.Subject(),
+			TokenType: "Bearer",
+			Exp:       token.Expiration().Unix(),
+			Jti:       token.JwtID(),
+		}
+		output.Scope, _ = scope.(string)
+		output.ClientID, _ = clientID.(string)
+		if !token.IssuedAt().IsZero() {
+			output.Iat = token.IssuedAt().Unix()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// Revoke OAuth token revocation godoc
+// @Summary     OAuth2 token revocation
+// @Description Revoke an access token issued to the authenticated client (RFC 7009). Unknown or foreign tokens are ignored and still answered with 200.
+// @Tags        oauth
+// @Accept      x-www-form-urlencoded
+// @Produce     json
+// @Param       token           formData string true  "access token"
+// @Param       token_type_hint formData string false "ignored, only access tokens are issued"
+// @Success     200
+// @Failure     401 {object} dto.OAuthErrorOutput
+// @Failure     500 {object} dto.OAuthErrorOutput
+// @Router      /oauth/revoke [post]
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, oauthInvalidRequest, err.Error())
+		return
+	}
+
+	client, err := h.authenticateClient(r)
+	if err != nil {
+		oauthError(w, http.StatusUnauthorized, oauthInvalidClient, err.Error())
+		return
+	}
+
+	// Decode checks the signature but not the expiration: revoking an
+	// expired token is a no-op anyway.
+	token, err := h.Jwt.Decode(r.PostForm.Get("token"))
+	if err != nil || token == nil || token.JwtID() == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	clientID, _ := token.Get("client_id")
+	if clientID != client.ID.String() {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := h.RevokedTokenDB.Revoke(r.Context(), token.JwtID(), token.Expiration()); err != nil {
+		oauthError(w, http.StatusInternalServerError, oauthServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RevokedTokenCleanup periodically deletes revoked tokens that already
+// expired, until ctx is cancelled.
+func (h *OAuthHandler) RevokedTokenCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := h.RevokedTokenDB.DeleteExpired(ctx, now); err != nil {
+				slog.Error("failed to delete expired revoked tokens", "error", err)
+			}
+		}
+	}
+}
+
+func (h *OAuthHandler) isActive(ctx context.Context, token jwt.Token) bool {
+	if token.JwtID() == "" {
+		return true
+	}
+	revoked, err := h.RevokedTokenDB.IsRevoked(ctx, token.JwtID())
+	return err == nil && !revoked
+}
+
+// authenticateClient reads the client credentials from HTTP Basic, whose
+// values are form-encoded as required by RFC 6749 section 2.3.1, or from the
+// client_id and client_secret form fields.
+func (h *OAuthHandler) authenticateClient(r *http.Request) (*entity.OAuthClient, error) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return nil, ErrInvalidClient
+		}
+		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+			return nil, ErrInvalidClient
+		}
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	if clientID == "" || clientSecret == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := h.ClientDB.FindByID(r.Context(), clientID)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+	if err := client.ValidateSecret(clientSecret); err != nil {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+func oauthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.OAuthErrorOutput{Error: code, ErrorDescription: description})
+}
@@ -1,23 +1,28 @@
 package handlers
 
 import (
-	"encoding/json"
 	"github.com/go-chi/chi"
 	"github.com/leobelini-studies/go_expert_api/internal/dto"
 	"github.com/leobelini-studies/go_expert_api/internal/entity"
 	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/batch"
 	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
 	"net/http"
 	"strconv"
 )
 
 type ProductHandler struct {
 	ProductDB database.ProductInterface
+	Batch     *batch.Executor
 }
 
 func NewProductHandler(db database.ProductInterface) *ProductHandler {
 	return &ProductHandler{
 		ProductDB: db,
+		Batch:     batch.New(db, batch.DefaultMaxOperations),
 	}
 }
 
@@ -26,73 +31,143 @@ func NewProductHandler(db database.ProductInterface) *ProductHandler {
 // @Description Create products
 // @Tags        products
 // @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
 // @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
 // @Param       resquest body dto.CreateProductInput true "product request"
+// @Param       Idempotency-Key header string false "key that makes retries return the first response"
 // @Success     201
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     409 {object} dto.ErrorOutput
+// @Failure     422 {object} dto.ErrorOutput
 // @Failure     500 {object} dto.ErrorOutput
 // @Router      /products [post]
 // @Security ApiKeyAuth
+// @Security XAPIKeyAuth
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ProductHandler.CreateProduct")
+	defer span.End()
+
 	var product dto.CreateProductInput
-	err := json.NewDecoder(r.Body).Decode(&product)
+	err := render.Decode(r, &product)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	p, err := entity.NewProduct(product.Name, product.Price)
+	if err == nil {
+		p.SKU = product.SKU
+		p.Category = product.Category
+		err = p.Validate()
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = h.ProductDB.Create(p)
+	if p.SKU != "" {
+		if _, err := h.ProductDB.FindBySKU(r.Context(), p.SKU); err == nil {
+			renderError(w, r, http.StatusConflict, entity.ErrSKUAlreadyUsed.Error())
+			return
+		}
+	}
+
+	err = h.ProductDB.Create(r.Context(), p)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
 }
 
+// BatchProducts Batch product operations godoc
+// @Summary     Batch product operations
+// @Description Apply a list of create, update and delete operations in one request, each with the rules of the single product endpoints. Results are returned in order with the status and body each operation would have had on its own. In best-effort mode every operation runs and the response is 200 when all succeeded, 207 otherwise. With atomic=true the operations share a transaction: the first failure rolls back the batch, the response is 422 (or the 5xx of the failure) and the operations not applied report 424.
+// @Tags        products
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Param       resquest body dto.BatchInput true "operations"
+// @Param       Idempotency-Key header string false "key that makes retries return the first response"
+// @Success     200 {object} dto.BatchOutput
+// @Success     207 {object} dto.BatchOutput
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     422 {object} dto.BatchOutput
+// @Router      /products/batch [post]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *ProductHandler) BatchProducts(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ProductHandler.BatchProducts")
+	defer span.End()
+
+	var input dto.BatchInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.Batch.Validate(input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	output := h.Batch.Run(r.Context(), input)
+	status := http.StatusOK
+	if output.Failed > 0 {
+		status = http.StatusMultiStatus
+		if output.Atomic {
+			status = http.StatusUnprocessableEntity
+			for _, result := range output.Results {
+				if result.Status >= http.StatusInternalServerError {
+					status = result.Status
+					break
+				}
+			}
+		}
+	}
+	render.Render(w, r, status, output)
+}
+
 // GetProduct Get Product godoc
 // @Summary     Get product
 // @Description Get product
 // @Tags        products
 // @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
 // @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
 // @Param       id path string true "product ID" Format(uuid)
 // @Success     200 {array} entity.Product
 // @Failure     404 {object} dto.ErrorOutput
 // @Failure     500 {object} dto.ErrorOutput
 // @Router      /products/{id} [get]
 // @Security ApiKeyAuth
+// @Security XAPIKeyAuth
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ProductHandler.GetProduct")
+	defer span.End()
+
 	id := chi.URLParam(r, "id")
 
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: "Invalid ID"}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	product, err := h.ProductDB.FindByID(id)
+	product, err := h.ProductDB.FindByID(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, render.ErrorStatus(err, http.StatusNotFound), err.Error())
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(product)
+	render.Render(w, r, http.StatusOK, product)
 }
 
 // UpdateProduct Update Product godoc
@@ -100,7 +175,11 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 // @Description Update product
 // @Tags        products
 // @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
 // @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
 // @Param       id path string true "product ID" Format(uuid)
 // @Param       resquest body entity.Product true "product update"
 // @Success     200
@@ -108,46 +187,47 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 // @Failure     500 {object} dto.ErrorOutput
 // @Router      /products/{id} [put]
 // @Security ApiKeyAuth
+// @Security XAPIKeyAuth
 func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ProductHandler.UpdateProduct")
+	defer span.End()
+
 	id := chi.URLParam(r, "id")
 
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: "Invalid ID"}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
 	var product entity.Product
-	err := json.NewDecoder(r.Body).Decode(&product)
+	err := render.Decode(r, &product)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	product.ID, err = entityPkg.ParseID(id)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	_, err = h.ProductDB.FindByID(id)
+	_, err = h.ProductDB.FindByID(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, render.ErrorStatus(err, http.StatusNotFound), err.Error())
 		return
 	}
 
-	err = h.ProductDB.Update(&product)
+	if product.SKU != "" {
+		if other, err := h.ProductDB.FindBySKU(r.Context(), product.SKU); err == nil && other.ID != product.ID {
+			renderError(w, r, http.StatusConflict, entity.ErrSKUAlreadyUsed.Error())
+			return
+		}
+	}
+
+	err = h.ProductDB.Update(r.Context(), &product)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
@@ -159,34 +239,38 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 // @Description Delete product
 // @Tags        products
 // @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
 // @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
 // @Param       id path string true "product ID" Format(uuid)
 // @Success     200
 // @Failure     404 {object} dto.ErrorOutput
 // @Failure     500 {object} dto.ErrorOutput
 // @Router      /products/{id} [delete]
 // @Security ApiKeyAuth
+// @Security XAPIKeyAuth
 func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ProductHandler.DeleteProduct")
+	defer span.End()
+
 	id := chi.URLParam(r, "id")
 
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: "Invalid ID"}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	_, err := h.ProductDB.FindByID(id)
+	_, err := h.ProductDB.FindByID(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(render.ErrorStatus(err, http.StatusNotFound))
 		return
 	}
 
-	err = h.ProductDB.Delete(id)
+	err = h.ProductDB.Delete(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
@@ -198,15 +282,25 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 // @Description Get all product
 // @Tags        products
 // @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
 // @Produce     json
-// @Param       page query string false "page number"
+// @Produce     xml
+// @Produce     application/msgpack
+// @Produce     application/x-ndjson
+// @Param       page query string false "page number; without it every product is streamed"
 // @Param       limit query string false "limit"
+// @Param       format query string false "ndjson streams one product per line" Enums(ndjson)
 // @Success     200 {array} entity.Product
 // @Failure     404 {object} dto.ErrorOutput
 // @Failure     500 {object} dto.ErrorOutput
 // @Router      /products [get]
 // @Security ApiKeyAuth
+// @Security XAPIKeyAuth
 func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ProductHandler.GetProducts")
+	defer span.End()
+
 	page := r.URL.Query().Get("page")
 	limit := r.URL.Query().Get("limit")
 
@@ -222,15 +316,43 @@ func (h *ProductHandler) GetProducts(w http.ResponseWriter, r *http.Request) {
 
 	sort := r.URL.Query().Get("sort")
 
-	products, err := h.ProductDB.FindAll(pageInt, limitInt, sort)
+	// only JSON is streamed; other formats render the whole list
+	if pageInt == 0 && render.CodecOf(r) == render.JSON {
+		h.streamProducts(w, r, sort)
+		return
+	}
+
+	products, err := h.ProductDB.FindAll(r.Context(), pageInt, limitInt, sort)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(products)
+	render.Render(w, r, http.StatusOK, render.List{Root: "products", Name: "product", Items: products})
+}
+
+// streamProducts writes every product as it is read from the database, so
+// listing the whole table uses constant memory. Once the first product was
+// sent the status can no longer change, so a later failure aborts the
+// connection instead of leaving a truncated body that looks complete.
+func (h *ProductHandler) streamProducts(w http.ResponseWriter, r *http.Request, sort string) {
+	stream := newJSONStream(w, wantsNDJSON(r))
+	// the stream lasts as long as the client keeps reading
+	ctx := middlewares.WithoutDeadline(r.Context())
+	err := h.ProductDB.Stream(ctx, sort, func(product *entity.Product) error {
+		return stream.Write(product)
+	})
+	if err == nil {
+		err = stream.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	if !stream.Started() {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+	logger.FromContext(r.Context()).Error("product stream aborted", "error", err)
+	panic(http.ErrAbortHandler)
 }
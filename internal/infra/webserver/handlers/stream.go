@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+	// streamFlushEvery is how many items are buffered before a streamed
+	// response is flushed to the client.
+	streamFlushEvery = 100
+)
+
+// wantsNDJSON reports whether the client asked for newline-delimited JSON,
+// with ?format=ndjson or the Accept header.
+func wantsNDJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), contentTypeNDJSON)
+}
+
+// jsonStream writes items one by one as a JSON array or as NDJSON, so large
+// lists are sent without being held in memory. Nothing is written before the
+// first item, so a failure before it can still be answered with an error
+// status.
+type jsonStream struct {
+	w       http.ResponseWriter
+	enc     *json.Encoder
+	ndjson  bool
+	started bool
+	count   int
+}
+
+func newJSONStream(w http.ResponseWriter, ndjson bool) *jsonStream {
+	return &jsonStream{w: w, enc: json.NewEncoder(w), ndjson: ndjson}
+}
+
+func (s *jsonStream) start() error {
+	s.started = true
+	if s.ndjson {
+		s.w.Header().Set("Content-Type", contentTypeNDJSON)
+	} else {
+		s.w.Header().Set("Content-Type", contentTypeJSON)
+	}
+	// the response takes as long as the client needs to read it, so the
+	// server write timeout does not apply
+	_ = http.NewResponseController(s.w).SetWriteDeadline(time.Time{})
+	s.w.WriteHeader(http.StatusOK)
+	if !s.ndjson {
+		_, err := s.w.Write([]byte("["))
+		return err
+	}
+	return nil
+}
+
+// Started reports whether the response status was already sent.
+func (s *jsonStream) Started() bool {
+	return s.started
+}
+
+func (s *jsonStream) Write(v interface{}) error {
+	if !s.started {
+		if err := s.start(); err != nil {
+			return err
+		}
+	} else if !s.ndjson {
+		if _, err := s.w.Write([]byte(",")); err != nil {
+			return err
+		}
+	}
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	s.count++
+	if s.count%streamFlushEvery == 0 {
+		return http.NewResponseController(s.w).Flush()
+	}
+	return nil
+}
+
+// Close ends the response. An empty stream is sent as [] or an empty body.
+func (s *jsonStream) Close() error {
+	if !s.started {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	if !s.ndjson {
+		if _, err := s.w.Write([]byte("]\n")); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(s.w).Flush()
+}
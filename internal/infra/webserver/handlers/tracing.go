@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/leobelini-studies/go_expert_api/internal/infra/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer(tracing.InstrumentationName)
+
+// startSpan starts a span named after the handler method and returns the
+// request carrying it, so the calls made by the handler become its children.
+func startSpan(r *http.Request, name string) (*http.Request, trace.Span) {
+	ctx, span := tracer.Start(r.Context(), name)
+	return r.WithContext(ctx), span
+}
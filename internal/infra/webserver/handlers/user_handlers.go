@@ -1,108 +1,613 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/go-chi/jwtauth"
 	"github.com/leobelini-studies/go_expert_api/internal/dto"
 	"github.com/leobelini-studies/go_expert_api/internal/entity"
 	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/lockout"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/mail"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/metrics"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+	entityPkg "github.com/leobelini-studies/go_expert_api/pkg/entity"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+var (
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrTooManyAttempts    = errors.New("too many failed attempts, try again later")
+	ErrEmailNotVerified   = errors.New("email not verified")
+)
+
+const (
+	passwordResetQueueSize = 100
+	// passwordResetTimeout bounds the lookup and the email of one reset.
+	passwordResetTimeout = 30 * time.Second
+)
+
+// dummyUser is checked against when the email is unknown so that the response
+// time does not reveal whether an account exists.
+var dummyUser, _ = entity.NewUser("", "", "dummy-password")
+
+// credentialsError is reported to clients as ErrInvalidCredentials but keeps
+// the login outcome, unknown user or bad password, for metrics.
+type credentialsError struct {
+	outcome string
+}
+
+func (e *credentialsError) Error() string {
+	return ErrInvalidCredentials.Error()
+}
+
+func (e *credentialsError) Is(target error) bool {
+	return target == ErrInvalidCredentials
+}
+
+func loginOutcome(err error) string {
+	var credErr *credentialsError
+	if errors.As(err, &credErr) {
+		return credErr.outcome
+	}
+	return metrics.LoginError
+}
+
 type UserHandler struct {
-	UserDb        database.UserInterface
-	Jwt           *jwtauth.JWTAuth
-	JwtExperiesIn int
+	UserDb                     database.UserInterface
+	UserTokenDB                database.UserTokenInterface
+	Mailer                     mail.Mailer
+	Jwt                        *jwtauth.JWTAuth
+	JwtExperiesIn              int
+	EmailVerificationExpiresIn int
+	PasswordResetExpiresIn     int
+	RequireVerifiedEmail       bool
+	RecoveryCodeDB             database.RecoveryCodeInterface
+	MFAJwt                     *jwtauth.JWTAuth
+	MFATokenExpiresIn          int
+	MFAIssuer                  string
+	RevokedTokenDB             database.RevokedTokenInterface
+	LoginGuard                 *lockout.Guard
+	Metrics                    *metrics.Metrics
+
+	// passwordResets queues the emails of ForgotPassword for
+	// SendPasswordResets.
+	passwordResets chan string
 }
 
-func NewUserHandler(db database.UserInterface, Jwt *jwtauth.JWTAuth, JwtExperiesIn int) *UserHandler {
+func NewUserHandler(db database.UserInterface, tokenDB database.UserTokenInterface, mailer mail.Mailer, Jwt *jwtauth.JWTAuth, JwtExperiesIn int) *UserHandler {
 	return &UserHandler{
-		UserDb:        db,
-		Jwt:           Jwt,
-		JwtExperiesIn: JwtExperiesIn,
+		UserDb:                     db,
+		UserTokenDB:                tokenDB,
+		Mailer:                     mailer,
+		Jwt:                        Jwt,
+		JwtExperiesIn:              JwtExperiesIn,
+		EmailVerificationExpiresIn: 86400,
+		PasswordResetExpiresIn:     3600,
+		MFATokenExpiresIn:          300,
+		MFAIssuer:                  "Go Expert API",
+		passwordResets:             make(chan string, passwordResetQueueSize),
 	}
 }
 
 // CreateUser Create user godoc
 // @Summary     Create user
-// @Description Create user
+// @Description Create user and send the email verification token
 // @Tags        users
 // @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
 // @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
 // @Param       resquest body dto.CreateUserInput true "user request"
+// @Param       Idempotency-Key header string false "key that makes retries return the first response"
 // @Success     201
+// @Failure     409 {object} dto.ErrorOutput
+// @Failure     422 {object} dto.ErrorOutput
 // @Failure     500 {object} dto.ErrorOutput
 // @Router      /users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.CreateUser")
+	defer span.End()
+
 	var user dto.CreateUserInput
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	if err := render.Decode(r, &user); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	u, err := entity.NewUser(user.Name, user.Email, user.Password)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
-		return
-	}
-	if err := h.UserDb.Create(u); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+	if _, err := h.Register(r.Context(), user); err != nil {
+		renderStatusError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
 }
 
+// Register creates the user and sends the email verification token.
+// Failures are *StatusError.
+func (h *UserHandler) Register(ctx context.Context, input dto.CreateUserInput) (*entity.User, error) {
+	u, err := entity.NewUser(input.Name, input.Email, input.Password)
+	if err != nil {
+		return nil, &StatusError{Status: http.StatusBadRequest, Err: err}
+	}
+	if err := h.UserDb.Create(ctx, u); err != nil {
+		return nil, &StatusError{Status: render.ErrorStatus(err, http.StatusInternalServerError), Err: err}
+	}
+
+	if err := h.sendVerificationEmail(ctx, u); err != nil {
+		logger.FromContext(ctx).Error("failed to send verification email", "user_id", u.ID.String(), "error", err)
+	}
+	return u, nil
+}
+
 // GetJWT Get JWT godoc
 // @Summary     Get a user JWT
-// @Description Get a user JWT
+// @Description Get a user JWT. When MFA is enabled a short-lived mfa_token is returned instead, to be exchanged at /users/mfa/verify.
 // @Tags        users
 // @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
 // @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
 // @Param       resquest body dto.GetJWTInput true "user credentials"
 // @Success     200  {object} dto.GetJWTOutput
-// @Failure     404 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     403 {object} dto.ErrorOutput
+// @Failure     429 {object} dto.ErrorOutput
 // @Failure     500 {object} dto.ErrorOutput
 // @Router      /users/generate_token [post]
 func (h *UserHandler) GetJWT(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.GetJWT")
+	defer span.End()
+
 	var user dto.GetJWTInput
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+	if err := render.Decode(r, &user); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	u, err := h.UserDb.FindByEmail(user.Email)
+	output, err := h.Login(r.Context(), user.Email, user.Password, middlewares.ClientIP(r))
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
+		renderStatusError(w, r, err)
 		return
 	}
+	render.Render(w, r, http.StatusOK, output)
+}
+
+// Login checks the credentials and issues an access token, or an MFA token
+// when the user enabled MFA. Failures are *StatusError. It backs
+// /users/generate_token and the gRPC AuthService.
+func (h *UserHandler) Login(ctx context.Context, email, password, ip string) (dto.GetJWTOutput, error) {
+	u, wait, err := checkCredentials(ctx, h.UserDb, h.LoginGuard, email, password, ip)
+	if wait > 0 {
+		h.Metrics.ObserveLogin(metrics.LoginLocked)
+		return dto.GetJWTOutput{}, &StatusError{Status: http.StatusTooManyRequests, RetryAfter: wait, Err: err}
+	}
+	if err != nil {
+		h.Metrics.ObserveLogin(loginOutcome(err))
+		return dto.GetJWTOutput{}, &StatusError{Status: render.ErrorStatus(err, http.StatusUnauthorized), Err: err}
+	}
 
-	if !u.ValidatePassword(user.Password) {
-		w.WriteHeader(http.StatusUnauthorized)
-		error := dto.ErrorOutput{Message: err.Error()}
-		json.NewEncoder(w).Encode(error)
-		return
+	// the password was right; with MFA the login is finished, and counted,
+	// by VerifyMFALogin
+	if u.MFAEnabled {
+		loginReleased(ctx, h.LoginGuard, email, ip)
+	} else {
+		loginSucceeded(ctx, h.LoginGuard, email, ip)
 	}
 
-	clains := map[string]interface{}{
-		"sub": u.ID.String(),
-		"exp": time.Now().Add(time.Second * time.Duration(h.JwtExperiesIn)).Unix(),
+	if h.RequireVerifiedEmail && !u.IsEmailVerified() {
+		h.Metrics.ObserveLogin(metrics.LoginUnverified)
+		return dto.GetJWTOutput{}, &StatusError{Status: http.StatusForbidden, Err: ErrEmailNotVerified}
 	}
-	_, token, _ := h.Jwt.Encode(clains)
 
-	accessToken := dto.GetJWTOutput{
+	if u.MFAEnabled && h.MFAJwt != nil {
+		mfaToken, err := h.issueMFAToken(u)
+		if err != nil {
+			return dto.GetJWTOutput{}, &StatusError{Status: http.StatusInternalServerError, Err: err}
+		}
+
+		h.Metrics.ObserveLogin(metrics.LoginMFARequired)
+		return dto.GetJWTOutput{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, nil
+	}
+
+	token, err := h.issueAccessToken(u)
+	if err != nil {
+		return dto.GetJWTOutput{}, &StatusError{Status: http.StatusInternalServerError, Err: err}
+	}
+
+	h.Metrics.ObserveLogin(metrics.LoginSuccess)
+	return dto.GetJWTOutput{
 		AccessToken: token,
+	}, nil
+}
+
+// VerifyEmail Verify email godoc
+// @Summary     Verify user email
+// @Description Confirm the user email with the token sent on sign up
+// @Tags        users
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Param       resquest body dto.VerifyEmailInput true "verification token"
+// @Success     200
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /users/verify_email [post]
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.VerifyEmail")
+	defer span.End()
+
+	var input dto.VerifyEmailInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token, err := h.consumeToken(r.Context(), entity.TokenTypeEmailVerification, input.Token)
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	u, err := h.UserDb.FindByID(r.Context(), token.UserID.String())
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusBadRequest), ErrInvalidToken.Error())
+		return
+	}
+
+	u.VerifyEmail()
+	if err := h.UserDb.Update(r.Context(), u); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ForgotPassword Forgot password godoc
+// @Summary     Request a password reset
+// @Description Send a password reset token to the user email. The email is sent in the background, so the response is the same, and as fast, whether the email exists or not.
+// @Tags        users
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Param       resquest body dto.ForgotPasswordInput true "user email"
+// @Success     202
+// @Failure     400 {object} dto.ErrorOutput
+// @Router      /users/forgot_password [post]
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.ForgotPassword")
+	defer span.End()
+
+	var input dto.ForgotPasswordInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the account is looked up and the email sent in the background, so the
+	// response takes the same time whether the email exists or not
+	select {
+	case h.passwordResets <- input.Email:
+	default:
+		logger.FromContext(r.Context()).Warn("password reset queue is full")
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// SendPasswordResets sends the password reset emails requested through
+// ForgotPassword, until ctx is cancelled.
+func (h *UserHandler) SendPasswordResets(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case email := <-h.passwordResets:
+			h.sendPasswordReset(ctx, email)
+		}
+	}
+}
+
+func (h *UserHandler) sendPasswordReset(ctx context.Context, email string) {
+	ctx, cancel := context.WithTimeout(ctx, passwordResetTimeout)
+	defer cancel()
+
+	u, err := h.UserDb.FindByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+	if err := h.sendPasswordResetEmail(ctx, u); err != nil {
+		logger.FromContext(ctx).Error("failed to send password reset email", "user_id", u.ID.String(), "error", err)
+	}
+}
+
+// ResetPassword Reset password godoc
+// @Summary     Reset password
+// @Description Set a new password using the token sent by forgot_password
+// @Tags        users
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Param       resquest body dto.ResetPasswordInput true "reset token and new password"
+// @Success     200
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /users/reset_password [post]
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.ResetPassword")
+	defer span.End()
+
+	var input dto.ResetPasswordInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Password == "" {
+		renderError(w, r, http.StatusBadRequest, "password is required")
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
+
+	token, err := h.consumeToken(r.Context(), entity.TokenTypePasswordReset, input.Token)
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	u, err := h.UserDb.FindByID(r.Context(), token.UserID.String())
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusBadRequest), ErrInvalidToken.Error())
+		return
+	}
+
+	if err := u.ChangePassword(input.Password); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Receiving the reset email proves ownership of the address.
+	u.VerifyEmail()
+	if err := h.UserDb.Update(r.Context(), u); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	if err := h.UserTokenDB.DeleteByUser(r.Context(), u.ID.String(), entity.TokenTypePasswordReset); err != nil {
+		logger.FromContext(r.Context()).Error("failed to delete password reset tokens", "user_id", u.ID.String(), "error", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// UnlockUser Unlock user godoc
+// @Summary     Unlock a user
+// @Description Clear the failed login counters of an account and/or a client IP
+// @Tags        admin
+// @Accept      json
+// @Accept      xml
+// @Accept      application/msgpack
+// @Produce     json
+// @Produce     xml
+// @Produce     application/msgpack
+// @Param       resquest body dto.UnlockUserInput true "account email and/or client IP"
+// @Success     200
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     403 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /admin/users/unlock [post]
+// @Security ApiKeyAuth
+func (h *UserHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserHandler.UnlockUser")
+	defer span.End()
+
+	var input dto.UnlockUserInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Email == "" && input.IP == "" {
+		renderError(w, r, http.StatusBadRequest, "email or ip is required")
+		return
+	}
+
+	if h.LoginGuard != nil {
+		if err := h.LoginGuard.Unlock(r.Context(), input.Email, input.IP); err != nil {
+			renderError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(accessToken)
+}
+
+// checkCredentials validates an email and password pair through the login
+// guard. wait is set when the account or the IP is locked out. Unknown emails
+// cost the same bcrypt comparison as wrong passwords and get the same error.
+// A wrong pair stays counted as a failed attempt; on success the caller ends
+// the attempt with loginSucceeded or loginReleased.
+func checkCredentials(ctx context.Context, userDB database.UserInterface, guard *lockout.Guard, email, password, ip string) (*entity.User, time.Duration, error) {
+	if wait := loginAttempt(ctx, guard, email, ip); wait > 0 {
+		return nil, wait, ErrTooManyAttempts
+	}
+
+	u, err := userDB.FindByEmail(ctx, email)
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		loginReleased(ctx, guard, email, ip)
+		return nil, 0, err
+	}
+	if err != nil {
+		dummyUser.ValidatePassword(password)
+		return nil, 0, &credentialsError{outcome: metrics.LoginUnknownUser}
+	}
+
+	if !u.ValidatePassword(password) {
+		return nil, 0, &credentialsError{outcome: metrics.LoginBadPassword}
+	}
+
+	return u, 0, nil
+}
+
+// loginAttempt reserves a login attempt and returns zero, or how long the
+// account or IP must wait. Errors from the counter store do not block logins.
+func loginAttempt(ctx context.Context, guard *lockout.Guard, email, ip string) time.Duration {
+	if guard == nil {
+		return 0
+	}
+	wait, err := guard.Attempt(ctx, email, ip)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to check login attempts", "error", err)
+		return 0
+	}
+	return wait
+}
+
+// loginReleased takes back an attempt that did not fail.
+func loginReleased(ctx context.Context, guard *lockout.Guard, email, ip string) {
+	if guard == nil {
+		return
+	}
+	if err := guard.Release(ctx, email, ip); err != nil {
+		logger.FromContext(ctx).Error("failed to release login attempt", "error", err)
+	}
+}
+
+func loginSucceeded(ctx context.Context, guard *lockout.Guard, email, ip string) {
+	if guard == nil {
+		return
+	}
+	if err := guard.Succeed(ctx, email, ip); err != nil {
+		logger.FromContext(ctx).Error("failed to reset login attempts", "error", err)
+	}
+}
+
+func tooManyAttempts(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	renderError(w, r, http.StatusTooManyRequests, ErrTooManyAttempts.Error())
+}
+
+// subjectFromToken returns the user ID from the access token verified by the
+// authentication middleware.
+func subjectFromToken(r *http.Request) (entityPkg.ID, error) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return entityPkg.ID{}, err
+	}
+	sub, _ := claims["sub"].(string)
+	return entityPkg.ParseID(sub)
+}
+
+func (h *UserHandler) issueAccessToken(u *entity.User) (string, error) {
+	role := u.Role
+	if role == "" {
+		role = entity.RoleUser
+	}
+	return newAccessToken(h.Jwt, u.ID.String(), h.JwtExperiesIn, map[string]interface{}{
+		"role": role,
+	})
+}
+
+// newAccessToken signs the token format accepted by the protected routes.
+// Every token has a jti so it can be revoked through /oauth/revoke.
+func newAccessToken(ja *jwtauth.JWTAuth, subject string, expiresIn int, extra map[string]interface{}) (string, error) {
+	now := time.Now()
+	clains := map[string]interface{}{
+		"sub": subject,
+		"jti": entityPkg.NewID().String(),
+		"iat": now.Unix(),
+		"exp": now.Add(time.Second * time.Duration(expiresIn)).Unix(),
+	}
+	for k, v := range extra {
+		clains[k] = v
+	}
+	_, token, err := ja.Encode(clains)
+	return token, err
+}
+
+// consumeToken looks up the token by its hash, checks that it is still valid
+// and marks it as used. Every failure is reported as ErrInvalidToken so the
+// caller cannot tell an unknown token from an expired one.
+func (h *UserHandler) consumeToken(ctx context.Context, tokenType, secret string) (*entity.UserToken, error) {
+	if secret == "" {
+		return nil, ErrInvalidToken
+	}
+
+	token, err := h.UserTokenDB.FindByHash(ctx, tokenType, entity.HashToken(secret))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := token.Validate(); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := h.UserTokenDB.MarkUsed(ctx, token); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+func (h *UserHandler) sendVerificationEmail(ctx context.Context, u *entity.User) error {
+	ttl := time.Second * time.Duration(h.EmailVerificationExpiresIn)
+	token, secret, err := entity.NewUserToken(u.ID, entity.TokenTypeEmailVerification, ttl)
+	if err != nil {
+		return err
+	}
+	if err := h.UserTokenDB.Create(ctx, token); err != nil {
+		return err
+	}
+
+	return h.Mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the token below to confirm your email at POST /users/verify_email:\n\n%s\n\nThe token expires at %s.",
+			u.Name, secret, token.ExpiresAt.Format(time.RFC1123)),
+	})
+}
+
+func (h *UserHandler) sendPasswordResetEmail(ctx context.Context, u *entity.User) error {
+	if err := h.UserTokenDB.DeleteByUser(ctx, u.ID.String(), entity.TokenTypePasswordReset); err != nil {
+		return err
+	}
+
+	ttl := time.Second * time.Duration(h.PasswordResetExpiresIn)
+	token, secret, err := entity.NewUserToken(u.ID, entity.TokenTypePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+	if err := h.UserTokenDB.Create(ctx, token); err != nil {
+		return err
+	}
+
+	return h.Mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the token below to choose a new password at POST /users/reset_password:\n\n%s\n\nThe token expires at %s. If you did not ask for a password reset you can ignore this email.",
+			u.Name, secret, token.ExpiresAt.Format(time.RFC1123)),
+	})
 }
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webhook"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/render"
+)
+
+const maxDeliveriesListed = 100
+
+type WebhookHandler struct {
+	WebhookDB  database.WebhookInterface
+	DeliveryDB database.WebhookDeliveryInterface
+	Dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookHandler(webhooks database.WebhookInterface, deliveries database.WebhookDeliveryInterface, dispatcher *webhook.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		WebhookDB:  webhooks,
+		DeliveryDB: deliveries,
+		Dispatcher: dispatcher,
+	}
+}
+
+// CreateWebhook Create webhook godoc
+// @Summary     Create webhook
+// @Description Subscribe a URL to events. Each event is POSTed as JSON with the X-Webhook-Event, X-Webhook-ID (the event ID, the same on every retry), X-Webhook-Timestamp and X-Webhook-Signature headers. The signature is "sha256=" followed by the hex HMAC-SHA256, keyed by the secret, of the timestamp, a dot and the body. A secret is generated when none is given; it is only returned once. Any status but 2xx is retried with exponential backoff.
+// @Tags        webhooks
+// @Accept      json
+// @Produce     json
+// @Param       resquest body dto.CreateWebhookInput true "webhook request"
+// @Success     201 {object} dto.CreateWebhookOutput
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     403 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /admin/webhooks [post]
+// @Security ApiKeyAuth
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "WebhookHandler.CreateWebhook")
+	defer span.End()
+
+	var input dto.CreateWebhookInput
+	if err := render.Decode(r, &input); err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, err := subjectFromToken(r)
+	if err != nil {
+		renderError(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	hook, secret, err := entity.NewWebhook(userID, input.URL, input.Events, input.Secret)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.WebhookDB.Create(r.Context(), hook); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	output := dto.CreateWebhookOutput{
+		WebhookOutput: toWebhookOutput(hook),
+		Secret:        secret,
+	}
+	render.Render(w, r, http.StatusCreated, output)
+}
+
+// GetWebhooks List webhooks godoc
+// @Summary     List webhooks
+// @Description List the webhooks of the authenticated user
+// @Tags        webhooks
+// @Produce     json
+// @Success     200 {array} dto.WebhookOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     403 {object} dto.ErrorOutput
+// @Failure     500 {object} dto.ErrorOutput
+// @Router      /admin/webhooks [get]
+// @Security ApiKeyAuth
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "WebhookHandler.GetWebhooks")
+	defer span.End()
+
+	userID, err := subjectFromToken(r)
+	if err != nil {
+		renderError(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	hooks, err := h.WebhookDB.FindByUser(r.Context(), userID.String())
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	output := make([]dto.WebhookOutput, 0, len(hooks))
+	for _, hook := range hooks {
+		output = append(output, toWebhookOutput(hook))
+	}
+	render.Render(w, r, http.StatusOK, output)
+}
+
+// DeleteWebhook Delete webhook godoc
+// @Summary     Delete webhook
+// @Description Delete a webhook of the authenticated user with its deliveries
+// @Tags        webhooks
+// @Produce     json
+// @Param       id path string true "webhook ID" Format(uuid)
+// @Success     200
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     404 {object} dto.ErrorOutput
+// @Router      /admin/webhooks/{id} [delete]
+// @Security ApiKeyAuth
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "WebhookHandler.DeleteWebhook")
+	defer span.End()
+
+	userID, err := subjectFromToken(r)
+	if err != nil {
+		renderError(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := h.WebhookDB.Delete(r.Context(), userID.String(), chi.URLParam(r, "id")); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusNotFound), err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetDeliveries List webhook deliveries godoc
+// @Summary     List webhook deliveries
+// @Description List the latest deliveries of a webhook, newest first. status=dead lists the dead letters: the deliveries that failed every attempt.
+// @Tags        webhooks
+// @Produce     json
+// @Param       id path string true "webhook ID" Format(uuid)
+// @Param       status query string false "only deliveries with this status" Enums(pending, succeeded, dead)
+// @Param       limit query int false "maximum number of deliveries, up to 100"
+// @Success     200 {array} entity.WebhookDelivery
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     404 {object} dto.ErrorOutput
+// @Router      /admin/webhooks/{id}/deliveries [get]
+// @Security ApiKeyAuth
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "WebhookHandler.GetDeliveries")
+	defer span.End()
+
+	hook, ok := h.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", entity.DeliveryStatusPending, entity.DeliveryStatusSucceeded, entity.DeliveryStatusDead:
+	default:
+		renderError(w, r, http.StatusBadRequest, "status must be pending, succeeded or dead")
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > maxDeliveriesListed {
+		limit = maxDeliveriesListed
+	}
+
+	deliveries, err := h.DeliveryDB.FindByWebhook(r.Context(), hook.ID.String(), status, limit)
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	render.Render(w, r, http.StatusOK, deliveries)
+}
+
+// Redeliver Redeliver webhook delivery godoc
+// @Summary     Redeliver webhook delivery
+// @Description Send a delivery again, with a fresh set of attempts. Usually used on dead letters once the receiver is fixed.
+// @Tags        webhooks
+// @Produce     json
+// @Param       id path string true "webhook ID" Format(uuid)
+// @Param       deliveryID path string true "delivery ID" Format(uuid)
+// @Success     202 {object} entity.WebhookDelivery
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     404 {object} dto.ErrorOutput
+// @Failure     409 {object} dto.ErrorOutput
+// @Router      /admin/webhooks/{id}/deliveries/{deliveryID}/redeliver [post]
+// @Security ApiKeyAuth
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "WebhookHandler.Redeliver")
+	defer span.End()
+
+	hook, ok := h.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.DeliveryDB.FindByID(r.Context(), hook.ID.String(), chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusNotFound), err.Error())
+		return
+	}
+	// a pending delivery is already being retried
+	if delivery.Status == entity.DeliveryStatusPending {
+		renderError(w, r, http.StatusConflict, "delivery is still pending")
+		return
+	}
+	if err := h.Dispatcher.Redeliver(r.Context(), delivery); err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	render.Render(w, r, http.StatusAccepted, delivery)
+}
+
+// findWebhook loads the webhook in the URL, answering 404 when it is
+// missing or belongs to another user.
+func (h *WebhookHandler) findWebhook(w http.ResponseWriter, r *http.Request) (*entity.Webhook, bool) {
+	userID, err := subjectFromToken(r)
+	if err != nil {
+		renderError(w, r, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+	hook, err := h.WebhookDB.FindByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		renderError(w, r, render.ErrorStatus(err, http.StatusNotFound), err.Error())
+		return nil, false
+	}
+	if hook.UserID != userID {
+		renderError(w, r, http.StatusNotFound, "webhook not found")
+		return nil, false
+	}
+	return hook, true
+}
+
+func toWebhookOutput(hook *entity.Webhook) dto.WebhookOutput {
+	return dto.WebhookOutput{
+		ID:        hook.ID.String(),
+		URL:       hook.URL,
+		Events:    hook.EventList(),
+		CreatedAt: hook.CreatedAt,
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/realtime"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/webserver/middlewares"
+)
+
+type WebSocketHandler struct {
+	Hub *realtime.Hub
+	// AllowedOrigins are the browser origins, besides the API's own, that
+	// may connect. Clients that send no Origin, such as the POS apps, are
+	// always accepted.
+	AllowedOrigins []string
+	// PingInterval is how often a ping is sent; a connection without any
+	// message or pong for PongTimeout is closed.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	// WriteTimeout bounds each write.
+	WriteTimeout time.Duration
+	// MaxMessageSize bounds the messages read from the clients.
+	MaxMessageSize int64
+	// MaxClients caps the open connections; zero means no limit.
+	MaxClients int
+	// Retry is the reconnection delay advised when refusing a connection.
+	Retry time.Duration
+}
+
+func NewWebSocketHandler(hub *realtime.Hub) *WebSocketHandler {
+	return &WebSocketHandler{
+		Hub:            hub,
+		PingInterval:   30 * time.Second,
+		PongTimeout:    60 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxMessageSize: 64 << 10,
+		Retry:          3 * time.Second,
+	}
+}
+
+// Connect Price updates over WebSocket godoc
+// @Summary     Price updates over WebSocket
+// @Description Upgrades to a WebSocket that pushes the price changes of the products the client follows. Clients send {"type":"subscribe"|"unsubscribe","products":[ids],"categories":[names]} and receive {"type":"subscribed"} with everything they follow, {"type":"price_changed","event_id":1,"data":{"id","name","category","old_price","price"}} and {"type":"error","error":"..."}. Categories match regardless of case. The server pings periodically and closes connections that stop answering. Messages that do not fit the queue of a slow client are dropped as WS_DROP_POLICY says; with "disconnect" the connection is closed with code 1013. Browsers may send the token in the access_token query parameter.
+// @Tags        products
+// @Param       access_token query string false "access token, for clients that cannot set headers"
+// @Success     101
+// @Failure     400 {object} dto.ErrorOutput
+// @Failure     401 {object} dto.ErrorOutput
+// @Failure     403 {object} dto.ErrorOutput
+// @Failure     503 {object} dto.ErrorOutput
+// @Router      /ws [get]
+// @Security ApiKeyAuth
+// @Security XAPIKeyAuth
+func (h *WebSocketHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	if h.MaxClients > 0 && h.Hub.Clients() >= h.MaxClients {
+		w.Header().Set("Retry-After", strconv.Itoa(int(h.Retry.Seconds())))
+		writeError(w, http.StatusServiceUnavailable, errors.New("too many connections, try again later"))
+		return
+	}
+	client := h.Hub.Register()
+	if client == nil {
+		writeError(w, http.StatusServiceUnavailable, realtime.ErrHubClosed)
+		return
+	}
+	defer h.Hub.Unregister(client)
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: h.checkOrigin,
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			writeError(w, status, reason)
+		},
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	log := logger.FromContext(r.Context())
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		h.write(conn, client)
+		// unblocks the read below when the writing stopped first
+		conn.Close()
+	}()
+	err = h.read(conn, client)
+	h.Hub.Unregister(client)
+	<-written
+
+	if errors.Is(client.Err(), realtime.ErrClientTooSlow) {
+		log.Warn("websocket closed, client too slow", "dropped", client.Dropped())
+		return
+	}
+	if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		log.Info("websocket closed", "error", err, "dropped", client.Dropped())
+	}
+}
+
+// checkOrigin accepts clients without an Origin, those of the API's own
+// host and the allowed origins.
+func (h *WebSocketHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return middlewares.OriginAllowed(h.AllowedOrigins, origin)
+}
+
+// read handles the requests of the client until the connection closes or
+// stops answering the pings.
+func (h *WebSocketHandler) read(conn *websocket.Conn, client *realtime.Client) error {
+	conn.SetReadLimit(h.MaxMessageSize)
+	alive := func() {
+		_ = conn.SetReadDeadline(time.Now().Add(h.PongTimeout))
+	}
+	alive()
+	conn.SetPongHandler(func(string) error {
+		alive()
+		return nil
+	})
+	for {
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		alive()
+		if kind != websocket.TextMessage {
+			client.Send(realtime.ErrorMessage(errors.New("messages must be JSON text")))
+			continue
+		}
+		var req realtime.Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			client.Send(realtime.ErrorMessage(errors.New("invalid json")))
+			continue
+		}
+		client.Send(client.Handle(req))
+	}
+}
+
+// write sends the queued messages and the pings until the client ends,
+// then closes the connection telling why.
+func (h *WebSocketHandler) write(conn *websocket.Conn, client *realtime.Client) {
+	ping := time.NewTicker(h.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case data, ok := <-client.C:
+			if !ok {
+				h.close(conn, client.Err())
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(h.WriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.WriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *WebSocketHandler) close(conn *websocket.Conn, reason error) {
+	var code int
+	switch {
+	case errors.Is(reason, realtime.ErrClientTooSlow):
+		code = websocket.CloseTryAgainLater
+	case errors.Is(reason, realtime.ErrHubClosed):
+		code = websocket.CloseGoingAway
+	default:
+		// the client went away
+		return
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason.Error()),
+		time.Now().Add(h.WriteTimeout))
+}
@@ -0,0 +1,196 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+var ErrUnauthorizedToken = errors.New("token is unauthorized")
+
+const accessTokenParam = "access_token"
+
+// lastUsedPrecision avoids writing to the database on every request made
+// with the same API key.
+const lastUsedPrecision = time.Minute
+
+// Authenticate accepts either an `Authorization: Bearer <jwt>` header or an
+// X-API-Key header and stores the resulting Principal in the request context.
+// Bearer tokens are also stored the jwtauth way so jwtauth.FromContext keeps
+// working in handlers. API keys are only accepted when apiKeys is not nil and
+// bearer tokens whose jti was revoked are rejected when revoked is not nil.
+func Authenticate(tokenAuth *jwtauth.JWTAuth, apiKeys database.APIKeyInterface, revoked database.RevokedTokenInterface) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := r.Header.Get(APIKeyHeader); key != "" && apiKeys != nil {
+				ctx, err := AuthenticateAPIKey(r.Context(), apiKeys, key)
+				if err != nil {
+					unauthorized(w, err.Error())
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			ctx, err := AuthenticateToken(r.Context(), tokenAuth, revoked, jwtauth.TokenFromHeader(r))
+			if err != nil {
+				unauthorized(w, err.Error())
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AuthenticateAPIKey validates a plain API key and returns ctx with its
+// Principal. Other transports, such as gRPC, authenticate through it too.
+func AuthenticateAPIKey(ctx context.Context, apiKeys database.APIKeyInterface, key string) (context.Context, error) {
+	apiKey, err := validateAPIKey(ctx, apiKeys, key)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = withLogSubject(ctx, apiKey.UserID.String())
+	return WithPrincipal(ctx, &Principal{
+		UserID:     apiKey.UserID.String(),
+		AuthMethod: AuthMethodAPIKey,
+		Scopes:     apiKey.ScopeList(),
+	}), nil
+}
+
+// AuthenticateToken verifies a bearer token and returns ctx with its
+// Principal and the token, stored the jwtauth way. Tokens whose jti was
+// revoked are rejected when revoked is not nil.
+func AuthenticateToken(ctx context.Context, tokenAuth *jwtauth.JWTAuth, revoked database.RevokedTokenInterface, tokenString string) (context.Context, error) {
+	if tokenString == "" {
+		return ctx, ErrUnauthorizedToken
+	}
+	token, err := jwtauth.VerifyToken(tokenAuth, tokenString)
+	if err != nil || token == nil {
+		return ctx, ErrUnauthorizedToken
+	}
+
+	if revoked != nil && token.JwtID() != "" {
+		isRevoked, err := revoked.IsRevoked(ctx, token.JwtID())
+		if err != nil || isRevoked {
+			return ctx, ErrUnauthorizedToken
+		}
+	}
+
+	scope, _ := token.Get("scope")
+	ctx = jwtauth.NewContext(ctx, token, nil)
+	ctx = withLogSubject(ctx, token.Subject())
+	return WithPrincipal(ctx, &Principal{
+		UserID:     token.Subject(),
+		AuthMethod: AuthMethodJWT,
+		Scopes:     scopesFromClaim(scope),
+	}), nil
+}
+
+// RequireScope rejects callers whose credentials do not grant scope. It must
+// run after Authenticate.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok || !p.HasScope(scope) {
+				forbidden(w, "missing scope "+scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireUserToken only accepts unrestricted user access tokens. It protects
+// account management routes from API keys and scoped OAuth tokens, which
+// could otherwise be used to mint broader credentials.
+func RequireUserToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFromContext(r.Context())
+		if !ok || p.AuthMethod != AuthMethodJWT || p.Scopes != nil {
+			forbidden(w, "a user access token is required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole only lets through requests whose access token carries the given
+// role claim. It must run after Authenticate.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil || claims["role"] != role {
+				forbidden(w, "forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TokenFromQuery moves a bearer token sent in the access_token query
+// parameter to the Authorization header, for clients that cannot set
+// headers, such as the browser EventSource. It must run before Authenticate
+// and only on the routes those clients use, as URLs end up in browser
+// history and proxy logs.
+func TokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		token := query.Get(accessTokenParam)
+		if token == "" || r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.Clone(r.Context())
+		query.Del(accessTokenParam)
+		r.URL.RawQuery = query.Encode()
+		r.Header.Set("Authorization", "Bearer "+token)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validateAPIKey(ctx context.Context, apiKeys database.APIKeyInterface, plain string) (*entity.APIKey, error) {
+	prefix, secret, err := entity.ParseAPIKey(plain)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := apiKeys.FindByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, entity.ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if err := key.Validate(secret, now); err != nil {
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedPrecision {
+		// failing to record usage must not block the request
+		_ = apiKeys.TouchLastUsed(ctx, key, now)
+	}
+	return key, nil
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(dto.ErrorOutput{Message: message})
+}
+
+func forbidden(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(dto.ErrorOutput{Message: message})
+}
@@ -0,0 +1,175 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newAuthTestServer(t *testing.T) (*jwtauth.JWTAuth, *database.APIKey, http.Handler) {
+	tokenAuth, apiKeyDB, _, handler := newAuthTestServerWithRevocation(t)
+	return tokenAuth, apiKeyDB, handler
+}
+
+func newAuthTestServerWithRevocation(t *testing.T) (*jwtauth.JWTAuth, *database.APIKey, *database.RevokedToken, http.Handler) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Error(err)
+	}
+	db.AutoMigrate(&entity.APIKey{}, &entity.RevokedToken{})
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	apiKeyDB := database.NewAPIKey(db)
+	revokedDB := database.NewRevokedToken(db)
+
+	handler := Authenticate(tokenAuth, apiKeyDB, revokedDB)(RequireScope(entity.ScopeProductsWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		w.Write([]byte(p.AuthMethod + ":" + p.UserID))
+	})))
+	return tokenAuth, apiKeyDB, revokedDB, handler
+}
+
+func TestAuthenticateWithJWT(t *testing.T) {
+	tokenAuth, _, handler := newAuthTestServer(t)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{
+		"sub": "user-id",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "jwt:user-id", rec.Body.String())
+}
+
+func TestAuthenticateWithAPIKey(t *testing.T) {
+	_, apiKeyDB, handler := newAuthTestServer(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+
+	key, plain, _ := entity.NewAPIKey(user.ID, "writer", []string{entity.ScopeProductsWrite}, nil)
+	assert.Nil(t, apiKeyDB.Create(context.Background(), key))
+
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set(APIKeyHeader, plain)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "api_key:"+user.ID.String(), rec.Body.String())
+
+	keyFound, _ := apiKeyDB.FindByPrefix(context.Background(), key.Prefix)
+	assert.NotNil(t, keyFound.LastUsedAt)
+}
+
+func TestAuthenticateWithAPIKeyMissingScope(t *testing.T) {
+	_, apiKeyDB, handler := newAuthTestServer(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+
+	key, plain, _ := entity.NewAPIKey(user.ID, "reader", []string{entity.ScopeProductsRead}, nil)
+	assert.Nil(t, apiKeyDB.Create(context.Background(), key))
+
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set(APIKeyHeader, plain)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestAuthenticateRejectsInvalidCredentials(t *testing.T) {
+	_, apiKeyDB, handler := newAuthTestServer(t)
+	user, _ := entity.NewUser("John Doe", "1y3t3@example.com", "password")
+	key, plain, _ := entity.NewAPIKey(user.ID, "writer", []string{entity.ScopeProductsWrite}, nil)
+	assert.Nil(t, apiKeyDB.Create(context.Background(), key))
+	assert.Nil(t, apiKeyDB.Revoke(context.Background(), user.ID.String(), key.ID.String()))
+
+	for _, header := range []map[string]string{
+		{},
+		{"Authorization": "Bearer invalid"},
+		{APIKeyHeader: "gea_unknown_secret"},
+		{APIKeyHeader: plain},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestAuthenticateRejectsRevokedJWT(t *testing.T) {
+	tokenAuth, _, revokedDB, handler := newAuthTestServerWithRevocation(t)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{
+		"sub": "user-id",
+		"jti": "token-id",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	assert.Nil(t, revokedDB.Revoke(context.Background(), "token-id", time.Now().Add(time.Minute)))
+
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestScopedJWT(t *testing.T) {
+	tokenAuth, _, handler := newAuthTestServer(t)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{
+		"sub":   "client-id",
+		"scope": entity.ScopeProductsRead,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/products", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	Authenticate(tokenAuth, nil, nil)(RequireUserToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestTokenFromQuery(t *testing.T) {
+	tokenAuth, _, authenticate := newAuthTestServer(t)
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{
+		"sub": "user-id",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	var query string
+	handler := TokenFromQuery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		authenticate.ServeHTTP(w, r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/products/events?access_token="+token+"&x=1", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "jwt:user-id", rec.Body.String())
+	assert.Equal(t, "x=1", query)
+
+	// the header wins over the query
+	req = httptest.NewRequest(http.MethodGet, "/products/events?access_token="+token, nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const forwardedForHeader = "X-Forwarded-For"
+
+type clientIPKey struct{}
+
+// IPResolver finds the address of the client behind the reverse proxies the
+// API trusts. X-Forwarded-For is only honored when the connection comes from
+// a trusted proxy, and it is read from right to left so entries prepended by
+// the client cannot spoof the address.
+type IPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewIPResolver accepts IPs and CIDR ranges of the trusted proxies.
+func NewIPResolver(trustedProxies []string) (*IPResolver, error) {
+	r := &IPResolver{}
+	for _, p := range trustedProxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", p)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			p = fmt.Sprintf("%s/%d", p, bits)
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		r.trusted = append(r.trusted, network)
+	}
+	return r, nil
+}
+
+func (res *IPResolver) ClientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !res.isTrusted(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values(forwardedForHeader), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !res.isTrusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (res *IPResolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range res.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP stores the client IP found by res in the request context.
+func RealIP(res *IPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPKey{}, res.ClientIP(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIP returns the IP stored by RealIP, or the connection address when
+// RealIP did not run.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestFrom(remoteAddr string, forwardedFor ...string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	for _, f := range forwardedFor {
+		req.Header.Add(forwardedForHeader, f)
+	}
+	return req
+}
+
+func TestClientIPIgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+	res, err := NewIPResolver(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "203.0.113.7", res.ClientIP(requestFrom("203.0.113.7:1234", "1.2.3.4")))
+}
+
+func TestClientIPUsesForwardedForFromTrustedProxy(t *testing.T) {
+	res, err := NewIPResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "198.51.100.2", res.ClientIP(requestFrom("10.0.0.5:80", "198.51.100.2")))
+	// the client prepended a fake address, the right-most untrusted hop wins
+	assert.Equal(t, "198.51.100.2", res.ClientIP(requestFrom("10.0.0.5:80", "1.1.1.1, 198.51.100.2, 192.168.1.1")))
+	assert.Equal(t, "198.51.100.2", res.ClientIP(requestFrom("10.0.0.5:80", "1.1.1.1", "198.51.100.2")))
+	// every hop is trusted
+	assert.Equal(t, "10.0.0.9", res.ClientIP(requestFrom("10.0.0.5:80", "10.0.0.9")))
+	// garbage stops the walk at the last valid hop
+	assert.Equal(t, "198.51.100.2", res.ClientIP(requestFrom("10.0.0.5:80", "garbage, 198.51.100.2")))
+	assert.Equal(t, "10.0.0.5", res.ClientIP(requestFrom("10.0.0.5:80")))
+}
+
+func TestNewIPResolverRejectsInvalidProxies(t *testing.T) {
+	_, err := NewIPResolver([]string{"not-an-ip"})
+	assert.NotNil(t, err)
+}
+
+func TestRealIPStoresClientIP(t *testing.T) {
+	res, _ := NewIPResolver([]string{"10.0.0.1"})
+	var ip string
+	handler := RealIP(res)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = ClientIP(r)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), requestFrom("10.0.0.1:80", "198.51.100.2"))
+	assert.Equal(t, "198.51.100.2", ip)
+
+	assert.Equal(t, "203.0.113.7", ClientIP(requestFrom("203.0.113.7:1234")))
+}
@@ -0,0 +1,217 @@
+package middlewares
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// DefaultCompressibleTypes are the media types Compress encodes when no list
+// is given. Formats that are already compressed, such as images or xlsx, are
+// left out.
+var DefaultCompressibleTypes = []string{
+	"application/json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/msgpack",
+	"application/javascript",
+	"image/svg+xml",
+	"text/csv",
+	"text/css",
+	"text/html",
+	"text/javascript",
+	"text/plain",
+	"text/xml",
+}
+
+// supportedEncodings lists the encodings Compress offers, preferred first
+// when the client accepts several with the same weight.
+var supportedEncodings = []string{EncodingBrotli, EncodingGzip}
+
+type flushWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compress encodes responses with brotli or gzip, whichever the client
+// prefers in Accept-Encoding. Only the given media types, or
+// DefaultCompressibleTypes, are encoded. level follows compress/gzip (1 to 9)
+// and is used for both encoders; zero disables compression. Flushing the
+// response flushes the encoder too, so streamed responses reach the client
+// as they are written.
+func Compress(level int, types ...string) func(http.Handler) http.Handler {
+	if len(types) == 0 {
+		types = DefaultCompressibleTypes
+	}
+	allowed := make(map[string]bool, len(types))
+	for _, t := range types {
+		allowed[t] = true
+	}
+	if level > gzip.BestCompression {
+		level = gzip.BestCompression
+	}
+	pools := map[string]*sync.Pool{
+		EncodingBrotli: {New: func() interface{} { return brotli.NewWriterLevel(io.Discard, level) }},
+		EncodingGzip: {New: func() interface{} {
+			gw, _ := gzip.NewWriterLevel(io.Discard, level)
+			return gw
+		}},
+	}
+
+	return func(next http.Handler) http.Handler {
+		if level <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			cw := &compressWriter{
+				ResponseWriter: w,
+				allowed:        allowed,
+				head:           r.Method == http.MethodHead,
+			}
+			if encoding != "" {
+				cw.encoding = encoding
+				cw.pool = pools[encoding]
+			}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the supported encoding with the highest weight in
+// an Accept-Encoding header, or "" when none is acceptable.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	weights := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range supportedEncodings {
+		q, ok := weights[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	allowed     map[string]bool
+	head        bool
+	encoding    string
+	pool        *sync.Pool
+	encoder     flushWriter
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	mediaType, _, _ := strings.Cut(h.Get("Content-Type"), ";")
+	if cw.allowed[strings.TrimSpace(mediaType)] {
+		// the body depends on Accept-Encoding even when not encoded
+		h.Add("Vary", "Accept-Encoding")
+		// a range is counted in bytes of the uncompressed body
+		if cw.pool != nil && !cw.head && h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" && bodyAllowed(status) {
+			h.Set("Content-Encoding", cw.encoding)
+			// the length after compression is unknown
+			h.Del("Content-Length")
+			cw.encoder = cw.pool.Get().(flushWriter)
+			cw.encoder.Reset(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *compressWriter) Flush() {
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	return hj.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to extend
+// the write deadline of streamed responses.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) close() {
+	if cw.encoder == nil {
+		return
+	}
+	cw.encoder.Close()
+	cw.encoder.Reset(io.Discard)
+	cw.pool.Put(cw.encoder)
+	cw.encoder = nil
+}
+
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
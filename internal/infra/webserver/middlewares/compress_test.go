@@ -0,0 +1,137 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+var jsonBody = strings.Repeat(`{"name":"Product","price":10},`, 100)
+
+func newCompressHandler(contentType string) http.Handler {
+	return Compress(5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Vary", "Origin")
+		w.Write([]byte(jsonBody))
+	}))
+}
+
+func compressRequest(acceptEncoding string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	return req
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                       "",
+		"identity":               "",
+		"gzip":                   EncodingGzip,
+		"gzip, deflate, br":      EncodingBrotli,
+		"br;q=0.5, gzip":         EncodingGzip,
+		"br;q=0, gzip;q=0":       "",
+		"*":                      EncodingBrotli,
+		"*;q=0.1, gzip;q=0.5":    EncodingGzip,
+		"GZIP;q=1.0, br;q=0.999": EncodingGzip,
+	}
+	for header, want := range tests {
+		assert.Equal(t, want, negotiateEncoding(header), header)
+	}
+}
+
+func TestCompressGzip(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newCompressHandler("application/json; charset=utf-8").ServeHTTP(rec, compressRequest("gzip"))
+
+	assert.Equal(t, EncodingGzip, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, []string{"Origin", "Accept-Encoding"}, rec.Header().Values("Vary"))
+	assert.Less(t, rec.Body.Len(), len(jsonBody))
+
+	gr, err := gzip.NewReader(rec.Body)
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(gr)
+	assert.Equal(t, jsonBody, string(body))
+}
+
+func TestCompressBrotli(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newCompressHandler("application/json").ServeHTTP(rec, compressRequest("gzip, br"))
+
+	assert.Equal(t, EncodingBrotli, rec.Header().Get("Content-Encoding"))
+	body, _ := io.ReadAll(brotli.NewReader(rec.Body))
+	assert.Equal(t, jsonBody, string(body))
+}
+
+func TestCompressSkipsIncompressibleTypes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newCompressHandler("image/png").ServeHTTP(rec, compressRequest("gzip"))
+
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, jsonBody, rec.Body.String())
+}
+
+func TestCompressSkipsRanges(t *testing.T) {
+	handler := Compress(5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "products.csv", time.Time{}, strings.NewReader(jsonBody))
+	}))
+	req := compressRequest("gzip")
+	req.Header.Set("Range", "bytes=0-9")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, jsonBody[:10], rec.Body.String())
+}
+
+func TestCompressWithoutAcceptEncoding(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newCompressHandler("application/json").ServeHTTP(rec, compressRequest(""))
+
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+	assert.Equal(t, jsonBody, rec.Body.String())
+}
+
+func TestCompressFlushesStreamedResponses(t *testing.T) {
+	flushed := make(chan struct{})
+	proceed := make(chan struct{})
+	handler := Compress(5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"id\":1}\n"))
+		w.(http.Flusher).Flush()
+		close(flushed)
+		<-proceed
+		w.Write([]byte("{\"id\":2}\n"))
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	<-flushed
+
+	// the first line can be decoded before the handler writes the second
+	gr, err := gzip.NewReader(res.Body)
+	assert.Nil(t, err)
+	line := make([]byte, len("{\"id\":1}\n"))
+	_, err = io.ReadFull(gr, line)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"id\":1}\n", string(line))
+
+	close(proceed)
+	rest, _ := io.ReadAll(gr)
+	assert.Equal(t, "{\"id\":2}\n", string(rest))
+}
@@ -0,0 +1,153 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures which browser origins may call the API.
+type CORSOptions struct {
+	// AllowedOrigins lists exact origins such as https://app.example.com,
+	// wildcard subdomains such as https://*.example.com, or "*" for any.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// CORS answers preflight requests and adds the Access-Control-* headers to
+// responses for allowed origins. Requests from other origins are served
+// without those headers, so browsers refuse to expose the response. It must
+// run before routing, since preflight requests use OPTIONS, which the routes
+// do not handle.
+func CORS(opts CORSOptions) func(http.Handler) http.Handler {
+	methods := upperAll(opts.AllowedMethods)
+	allowedMethods := strings.Join(methods, ", ")
+	exposedHeaders := strings.Join(opts.ExposedHeaders, ", ")
+	anyHeader := contains(opts.AllowedHeaders, "*")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		if len(opts.AllowedOrigins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !OriginAllowed(opts.AllowedOrigins, origin) {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if contains(opts.AllowedOrigins, "*") && !opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				// credentials are never allowed together with "*", so the
+				// origin is echoed back
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposedHeaders != "" {
+					h.Set("Access-Control-Expose-Headers", exposedHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !contains(methods, strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", allowedMethods)
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				if allowed := allowedHeaders(opts.AllowedHeaders, anyHeader, requested); allowed != "" {
+					h.Set("Access-Control-Allow-Headers", allowed)
+				}
+			}
+			if opts.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// OriginAllowed reports whether origin matches one of the allowed origins,
+// given as for CORSOptions.AllowedOrigins.
+func OriginAllowed(allowed []string, origin string) bool {
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, origin) {
+			return true
+		}
+		if i := strings.Index(a, "*."); i >= 0 {
+			prefix, suffix := a[:i], a[i+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowedHeaders returns the requested headers the API accepts.
+func allowedHeaders(allowed []string, any bool, requested string) string {
+	var headers []string
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if any || containsFold(allowed, h) {
+			headers = append(headers, h)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+func upperAll(values []string) []string {
+	upper := make([]string, len(values))
+	for i, v := range values {
+		upper[i] = strings.ToUpper(strings.TrimSpace(v))
+	}
+	return upper
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func corsRequest(method, origin string) *http.Request {
+	req := httptest.NewRequest(method, "/products", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	return req
+}
+
+func newCORSHandler(opts CORSOptions) http.Handler {
+	return CORS(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+var testCORSOptions = CORSOptions{
+	AllowedOrigins:   []string{"https://app.example.com", "https://*.preview.example.com"},
+	AllowedMethods:   []string{"GET", "POST"},
+	AllowedHeaders:   []string{"Authorization", "Content-Type"},
+	ExposedHeaders:   []string{"Location"},
+	AllowCredentials: true,
+	MaxAge:           10 * time.Minute,
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	handler := newCORSHandler(testCORSOptions)
+
+	for _, origin := range []string{"https://app.example.com", "https://pr-1.preview.example.com"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, corsRequest(http.MethodGet, origin))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, origin, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Location", rec.Header().Get("Access-Control-Expose-Headers"))
+		assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+	}
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	handler := newCORSHandler(testCORSOptions)
+
+	for _, origin := range []string{"https://evil.example.com", "https://preview.example.com", ""} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, corsRequest(http.MethodGet, origin))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler := newCORSHandler(testCORSOptions)
+
+	req := corsRequest(http.MethodOptions, "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-unknown")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+
+	req = corsRequest(http.MethodOptions, "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	handler := newCORSHandler(CORSOptions{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, corsRequest(http.MethodGet, "https://any.example.org"))
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORSDisabled(t *testing.T) {
+	handler := newCORSHandler(CORSOptions{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, corsRequest(http.MethodGet, "https://app.example.com"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rec.Header().Get("Vary"))
+}
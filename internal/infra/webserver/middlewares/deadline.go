@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Deadline bounds the time a request may spend on database work. The
+// repositories run their queries with the request context, so queries still
+// running when the deadline expires are cancelled and the handlers answer
+// 504. A zero timeout disables the deadline.
+func Deadline(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), requestContextKey{}, r.Context())
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type requestContextKey struct{}
+
+// detachedContext is cancelled with the request but carries the values of
+// the context it was detached from.
+type detachedContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+// WithoutDeadline returns ctx without the deadline set by Deadline, for work
+// meant to take as long as the client keeps reading, such as streamed
+// exports. It is still cancelled when the client goes away.
+func WithoutDeadline(ctx context.Context) context.Context {
+	parent, ok := ctx.Value(requestContextKey{}).(context.Context)
+	if !ok {
+		return ctx
+	}
+	return detachedContext{Context: parent, values: ctx}
+}
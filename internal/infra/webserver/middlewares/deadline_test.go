@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadlineSetsRequestDeadline(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	handler := Deadline(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
+
+func TestDeadlineDisabled(t *testing.T) {
+	var ok bool
+	handler := Deadline(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok = r.Context().Deadline()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.False(t, ok)
+}
+
+func TestWithoutDeadline(t *testing.T) {
+	type key struct{}
+	var hasDeadline bool
+	var value interface{}
+	handler := Deadline(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithoutDeadline(context.WithValue(r.Context(), key{}, "kept"))
+		_, hasDeadline = ctx.Deadline()
+		value = ctx.Value(key{})
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.False(t, hasDeadline)
+	assert.Equal(t, "kept", value)
+}
@@ -0,0 +1,176 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyMaxRequestBody = 1 << 20
+	// idempotencyRetryAfter is suggested to clients whose key is still being
+	// processed by another request.
+	idempotencyRetryAfter = "1"
+)
+
+// idempotencyReplayHeaders are the response headers stored with the key and
+// sent again on replays.
+var idempotencyReplayHeaders = []string{"Content-Type", "Location"}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first request with a key is processed and its response stored
+// for ttl; retries with the same body get the stored response back, retries
+// with a different body get 422 and retries arriving while the first one is
+// still running get 409. Keys are scoped to the authenticated user or, on
+// public routes, to the client IP. Requests without the header and 5xx
+// responses are not stored. A key still in flight after lease, which must
+// outlast the request deadline, is left by a request that died and is taken
+// over by the next retry.
+func Idempotency(keys database.IdempotencyKeyInterface, ttl, lease time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, idempotencyMaxRequestBody))
+			if err != nil {
+				idempotencyError(w, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			key, err := entity.NewIdempotencyKey(KeyBySubject(r), value, requestFingerprint(r, body), ttl, lease)
+			if err != nil {
+				idempotencyError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			log := logger.FromContext(r.Context())
+			existing, err := keys.Acquire(r.Context(), key)
+			if err != nil {
+				log.Error("failed to acquire idempotency key", "error", err)
+				idempotencyError(w, http.StatusInternalServerError, "failed to process idempotency key")
+				return
+			}
+			if existing != nil {
+				replay(w, existing, key.Fingerprint)
+				return
+			}
+
+			var response bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&response)
+
+			// the key must be settled even when the client went away
+			ctx := context.WithoutCancel(r.Context())
+			defer func() {
+				if p := recover(); p != nil {
+					_ = keys.Release(ctx, key)
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError {
+				if err := keys.Release(ctx, key); err != nil {
+					log.Error("failed to release idempotency key", "error", err)
+				}
+				return
+			}
+
+			key.Complete(status, storedHeaders(ww.Header()), response.Bytes())
+			if err := keys.Save(ctx, key); err != nil {
+				log.Error("failed to store idempotent response", "error", err)
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, key *entity.IdempotencyKey, fingerprint string) {
+	switch err := key.Check(fingerprint); {
+	case errors.Is(err, entity.ErrIdempotencyKeyReused):
+		idempotencyError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case errors.Is(err, entity.ErrIdempotencyKeyInFlight):
+		w.Header().Set("Retry-After", idempotencyRetryAfter)
+		idempotencyError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	var header http.Header
+	if err := json.Unmarshal([]byte(key.ResponseHeader), &header); err == nil {
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(key.StatusCode)
+	w.Write(key.ResponseBody)
+}
+
+// requestFingerprint identifies what a request asks for, so a key reused for
+// another operation can be told apart from a retry. The negotiated headers
+// are part of it, since the stored response is encoded for them.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\x00"))
+	h.Write([]byte(r.Header.Get("Accept") + "\x00" + r.Header.Get("Content-Type") + "\x00"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func storedHeaders(h http.Header) string {
+	stored := http.Header{}
+	for _, name := range idempotencyReplayHeaders {
+		if values := h.Values(name); len(values) > 0 {
+			stored[name] = values
+		}
+	}
+	b, _ := json.Marshal(stored)
+	return string(b)
+}
+
+func idempotencyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(dto.ErrorOutput{Message: message})
+}
+
+// IdempotencyKeyCleanup periodically deletes expired idempotency keys, until
+// ctx is cancelled.
+func IdempotencyKeyCleanup(ctx context.Context, keys database.IdempotencyKeyInterface, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := keys.DeleteExpired(ctx, now); err != nil {
+				slog.Error("failed to delete expired idempotency keys", "error", err)
+			}
+		}
+	}
+}
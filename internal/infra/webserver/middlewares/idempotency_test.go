@@ -0,0 +1,156 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/entity"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/database"
+	"github.com/leobelini-studies/go_expert_api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdempotencyKeyDB(t *testing.T) *database.IdempotencyKey {
+	db := testutil.NewSQLite(t, &entity.IdempotencyKey{})
+	return database.NewIdempotencyKey(db)
+}
+
+func idempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	req.RemoteAddr = "203.0.113.7:1"
+	req.Header.Set(IdempotencyKeyHeader, key)
+	return req
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var calls int32
+	handler := Idempotency(newIdempotencyKeyDB(t), time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/products/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"call":` + string(rune('0'+n)) + `}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("abc", `{"name":"Product 1"}`))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("abc", `{"name":"Product 1"}`))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "/products/1", rec.Header().Get("Location"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `{"call":1}`, rec.Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// another client may use the same key
+	req := idempotentRequest("abc", `{"name":"Product 1"}`)
+	req.RemoteAddr = "203.0.113.8:1"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, `{"call":2}`, rec.Body.String())
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	handler := Idempotency(newIdempotencyKeyDB(t), time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("abc", `{"name":"Product 1"}`))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("abc", `{"name":"Product 2"}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// the stored response was encoded for the first request's headers
+	req := idempotentRequest("abc", `{"name":"Product 1"}`)
+	req.Header.Set("Accept", "application/xml")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestIdempotencyConcurrentRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotency(newIdempotencyKeyDB(t), time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, idempotentRequest("abc", `{}`))
+		done <- rec.Code
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("abc", `{}`))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, <-done)
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	status := http.StatusInternalServerError
+	handler := Idempotency(newIdempotencyKeyDB(t), time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("abc", `{}`))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	status = http.StatusCreated
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("abc", `{}`))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotencyTakesOverKeyAfterLease(t *testing.T) {
+	keys := newIdempotencyKeyDB(t)
+	// a request that died while holding the key
+	req := idempotentRequest("abc", `{}`)
+	stale, _ := entity.NewIdempotencyKey(KeyBySubject(req), "abc", requestFingerprint(req, []byte(`{}`)), time.Hour, -time.Second)
+	keys.Acquire(context.Background(), stale)
+
+	handler := Idempotency(keys, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotencyIgnoresRequestsWithoutKey(t *testing.T) {
+	var calls int32
+	handler := Idempotency(newIdempotencyKeyDB(t), time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("", `{}`))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest(strings.Repeat("a", entity.IdempotencyKeyMaxLength+1), `{}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestLogKey struct{}
+
+// requestLog collects attributes discovered while the request goes down the
+// middleware chain, such as the authenticated subject, so the access log
+// written by RequestLogger can include them.
+type requestLog struct {
+	subject string
+}
+
+// RequestLogger stores a logger carrying the request ID, method and path in
+// the request context and writes one access log entry per request with the
+// route pattern, status, latency and authenticated subject. It must run
+// after RequestID.
+func RequestLogger(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			l := base.With(
+				slog.String("request_id", RequestIDFromContext(r.Context())),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				l = l.With(slog.String("trace_id", sc.TraceID().String()))
+			}
+			l.Debug("request started", slog.String("remote_addr", r.RemoteAddr), logger.Headers(r.Header))
+
+			state := &requestLog{}
+			ctx := context.WithValue(r.Context(), requestLogKey{}, state)
+			ctx = logger.WithContext(ctx, l)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			attrs := []slog.Attr{
+				slog.String("route", routePattern(r)),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if state.subject != "" {
+				attrs = append(attrs, slog.String("sub", state.subject))
+			}
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			l.LogAttrs(r.Context(), level, "request completed", attrs...)
+		})
+	}
+}
+
+// withLogSubject records the authenticated subject for the access log and
+// returns a context whose logger includes it.
+func withLogSubject(ctx context.Context, subject string) context.Context {
+	if state, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		state.subject = subject
+	}
+	return logger.WithContext(ctx, logger.FromContext(ctx).With(slog.String("sub", subject)))
+}
+
+// routePattern returns the chi pattern that matched, such as
+// /products/{id}, which unlike the path has bounded cardinality.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return r.URL.Path
+}
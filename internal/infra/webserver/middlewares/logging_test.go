@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLoggerWritesAccessLog(t *testing.T) {
+	tokenAuth, _, _ := newAuthTestServer(t)
+	var buf bytes.Buffer
+
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(RequestLogger(logger.New(&buf, "info", logger.FormatJSON)))
+	r.With(Authenticate(tokenAuth, nil, nil)).Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("handler log")
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	_, token, _ := tokenAuth.Encode(map[string]interface{}{
+		"sub": "user-id",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	req := httptest.NewRequest(http.MethodGet, "/products/42", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var handlerEntry, accessEntry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(lines[0], &handlerEntry))
+	assert.Nil(t, json.Unmarshal(lines[1], &accessEntry))
+
+	assert.Equal(t, "req-1", handlerEntry["request_id"])
+	assert.Equal(t, "user-id", handlerEntry["sub"])
+
+	assert.Equal(t, "request completed", accessEntry["msg"])
+	assert.Equal(t, "req-1", accessEntry["request_id"])
+	assert.Equal(t, "/products/{id}", accessEntry["route"])
+	assert.Equal(t, "/products/42", accessEntry["path"])
+	assert.Equal(t, float64(http.StatusTeapot), accessEntry["status"])
+	assert.Equal(t, "user-id", accessEntry["sub"])
+	assert.Contains(t, accessEntry, "latency")
+	assert.NotContains(t, buf.String(), token)
+}
+
+func TestRequestLoggerRedactsHeadersAtDebug(t *testing.T) {
+	var buf bytes.Buffer
+	handler := RequestID(RequestLogger(logger.New(&buf, "debug", logger.FormatJSON))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Authorization", "Bearer very-secret")
+	req.Header.Set(APIKeyHeader, "gea_abc_def")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, buf.String(), "request started")
+	assert.NotContains(t, buf.String(), "very-secret")
+	assert.NotContains(t, buf.String(), "gea_abc_def")
+}
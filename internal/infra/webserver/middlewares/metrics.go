@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/metrics"
+)
+
+// unmatchedRoute labels requests that matched no route, so unknown paths do
+// not create new time series.
+const unmatchedRoute = "unmatched"
+
+// Instrument records request count, 5xx errors and latency per chi route
+// pattern.
+func Instrument(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			m.ObserveHTTPRequest(r.Method, route, status, time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentUsesRoutePattern(t *testing.T) {
+	m := metrics.New()
+	r := chi.NewRouter()
+	r.Use(Instrument(m))
+	r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/1", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/2", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	assert.Contains(t, string(body), `go_expert_api_http_requests_total{method="GET",route="/products/{id}",status="500"} 2`)
+	assert.Contains(t, string(body), `go_expert_api_http_request_errors_total{method="GET",route="/products/{id}"} 2`)
+	assert.Contains(t, string(body), `go_expert_api_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.NotContains(t, string(body), "/products/1")
+}
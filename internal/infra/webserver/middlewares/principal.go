@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+type principalKey struct{}
+
+// Principal identifies who is calling the API, however they authenticated.
+type Principal struct {
+	UserID     string
+	AuthMethod string
+	// Scopes limits what the caller may do. A nil slice means the caller is
+	// not restricted, which is the case for user access tokens.
+	Scopes []string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+func scopesFromClaim(claim interface{}) []string {
+	s, ok := claim.(string)
+	if !ok {
+		return nil
+	}
+	return strings.Fields(s)
+}
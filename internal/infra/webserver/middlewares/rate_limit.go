@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/dto"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/logger"
+	"github.com/leobelini-studies/go_expert_api/internal/infra/ratelimit"
+)
+
+// KeyFunc identifies the client a request is counted against.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP counts requests per client IP. It is meant for public routes.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// KeyBySubject counts requests per authenticated user or client, falling back
+// to the client IP. It must run after Authenticate.
+func KeyBySubject(r *http.Request) string {
+	if p, ok := PrincipalFromContext(r.Context()); ok && p.UserID != "" {
+		return "sub:" + p.UserID
+	}
+	return KeyByIP(r)
+}
+
+// RateLimit applies limit to the requests of a route group. name separates
+// the buckets of different groups. Every response carries the RateLimit-*
+// headers and rejected requests get 429 with Retry-After. When the store
+// fails the request is let through.
+func RateLimit(store ratelimit.Store, name string, limit ratelimit.Limit, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !limit.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := store.Take(r.Context(), name+":"+key(r), limit, time.Now())
+			if err != nil {
+				logger.FromContext(r.Context()).Error("failed to check rate limit", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(dto.ErrorOutput{Message: "rate limit exceeded"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
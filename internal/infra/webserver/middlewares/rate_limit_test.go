@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leobelini-studies/go_expert_api/internal/infra/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store down")
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func TestRateLimitByIP(t *testing.T) {
+	handler := RateLimit(ratelimit.NewMemoryStore(), "public", ratelimit.Limit{Rate: 1, Burst: 2}, KeyByIP)(okHandler)
+
+	for i := 1; i >= 0; i-- {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, requestFrom("203.0.113.7:1"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, string(rune('0'+i)), rec.Header().Get("RateLimit-Remaining"))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, requestFrom("203.0.113.7:1"))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Reset"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, requestFrom("203.0.113.8:1"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRateLimitBySubject(t *testing.T) {
+	handler := RateLimit(ratelimit.NewMemoryStore(), "products", ratelimit.Limit{Rate: 1, Burst: 1}, KeyBySubject)(okHandler)
+
+	request := func(userID string) int {
+		req := requestFrom("203.0.113.7:1")
+		req = req.WithContext(WithPrincipal(req.Context(), &Principal{UserID: userID}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("user-1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("user-1"))
+	// same IP, different user
+	assert.Equal(t, http.StatusOK, request("user-2"))
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	handler := RateLimit(failingStore{}, "public", ratelimit.Limit{Rate: 1, Burst: 1}, KeyByIP)(okHandler)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, requestFrom("203.0.113.7:1"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	handler := RateLimit(ratelimit.NewMemoryStore(), "public", ratelimit.PerMinute(0, 0), KeyByIP)(okHandler)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, requestFrom("203.0.113.7:1"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := SecurityHeaders(SecurityHeadersOptions{HSTSMaxAge: 365 * 24 * time.Hour, HSTSIncludeSubdomains: true})(okHandler)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products", nil))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+	assert.Equal(t, APIContentSecurityPolicy, rec.Header().Get("Content-Security-Policy"))
+	// not sent over plain HTTP
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+}
+
+func TestContentSecurityPolicyOverridesDefault(t *testing.T) {
+	handler := SecurityHeaders(SecurityHeadersOptions{})(ContentSecurityPolicy(DocsContentSecurityPolicy)(okHandler))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/index.html", nil))
+	assert.Equal(t, DocsContentSecurityPolicy, rec.Header().Get("Content-Security-Policy"))
+}
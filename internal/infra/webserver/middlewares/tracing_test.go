@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContinuesIncomingTrace(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	r := chi.NewRouter()
+	r.Use(Trace(tp))
+	r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tp.Tracer("test").Start(r.Context(), "ProductHandler.GetProduct")
+		span.End()
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+
+	handler, server := spans[0], spans[1]
+	assert.Equal(t, "GET /products/{id}", server.Name)
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.True(t, server.Parent.IsRemote())
+	assert.Equal(t, codes.Error, server.Status.Code)
+
+	assert.Equal(t, "ProductHandler.GetProduct", handler.Name)
+	assert.Equal(t, server.SpanContext.SpanID(), handler.Parent.SpanID())
+}
+
+func TestTraceStartsNewTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	handler := Trace(tp)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", nil))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.False(t, spans[0].Parent.IsValid())
+	assert.Equal(t, "POST", spans[0].Name)
+}
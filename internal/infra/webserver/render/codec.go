@@ -0,0 +1,131 @@
+package render
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"reflect"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeXML     = "application/xml"
+	MediaTypeMsgPack = "application/msgpack"
+)
+
+// Codec encodes responses and decodes requests in one media type.
+type Codec interface {
+	// MediaTypes lists the names the format is known by, the one sent in
+	// responses first.
+	MediaTypes() []string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Codecs are the supported formats, in the order preferred when the client
+// accepts several equally.
+var Codecs = []Codec{JSON, XML, MsgPack}
+
+var (
+	JSON    Codec = jsonCodec{}
+	XML     Codec = xmlCodec{}
+	MsgPack Codec = msgpackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) MediaTypes() []string { return []string{MediaTypeJSON} }
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// xmlCodec uses the xml tags of the types. A value without a root element
+// name, such as a slice, must be wrapped in a List.
+type xmlCodec struct{}
+
+func (xmlCodec) MediaTypes() []string { return []string{MediaTypeXML, "text/xml"} }
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// msgpackCodec reads the json tags, so the keys are the same as in JSON.
+type msgpackCodec struct{}
+
+func init() {
+	// IDs are sent as text, as in JSON, instead of their 16 bytes
+	msgpack.Register(uuid.UUID{},
+		func(enc *msgpack.Encoder, v reflect.Value) error {
+			return enc.EncodeString(v.Interface().(uuid.UUID).String())
+		},
+		func(dec *msgpack.Decoder, v reflect.Value) error {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(id))
+			return nil
+		})
+}
+
+func (msgpackCodec) MediaTypes() []string {
+	return []string{MediaTypeMsgPack, "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// List renders Items as an array in JSON and MessagePack and as Name
+// elements inside a Root element in XML, e.g. <products><product/></products>.
+type List struct {
+	Root  string
+	Name  string
+	Items interface{}
+}
+
+func (l List) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Items)
+}
+
+func (l List) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.Encode(l.Items)
+}
+
+func (l List) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	root := xml.StartElement{Name: xml.Name{Local: l.Root}}
+	if err := e.EncodeToken(root); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(l.Items, xml.StartElement{Name: xml.Name{Local: l.Name}}); err != nil {
+		return err
+	}
+	return e.EncodeToken(root.End())
+}
@@ -0,0 +1,222 @@
+package render
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+type contextKey struct{}
+
+type format struct {
+	codec     Codec
+	mediaType string
+}
+
+var defaultFormat = format{codec: JSON, mediaType: MediaTypeJSON}
+
+type errorOutput struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// Negotiate picks the response format from the Accept header and checks the
+// request body is in a supported format, before the handler runs. Requests
+// accepting no format are answered with 406 and bodies in other formats with
+// 415. Without Accept or Content-Type JSON is used. alsoAccepted lists the
+// media types the handlers produce or read on their own, such as NDJSON
+// streams; their errors are rendered as JSON.
+func Negotiate(alsoAccepted ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f, ok := negotiate(r.Header.Get("Accept"), alsoAccepted)
+			if !ok {
+				w.Header().Set("Vary", "Accept")
+				write(w, defaultFormat, http.StatusNotAcceptable, errorOutput{
+					Message: "acceptable formats are " + strings.Join(acceptable(alsoAccepted), ", "),
+				})
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), contextKey{}, f))
+
+			if r.ContentLength != 0 {
+				if _, err := requestCodec(r); err != nil && !containsMediaType(alsoAccepted, r.Header.Get("Content-Type")) {
+					Render(w, r, http.StatusUnsupportedMediaType, errorOutput{
+						Message: "request bodies must be " + strings.Join(acceptable(nil), ", "),
+					})
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Render writes v with status in the format chosen by Negotiate, or JSON
+// when the request was not negotiated.
+func Render(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	f, ok := r.Context().Value(contextKey{}).(format)
+	if !ok {
+		f = defaultFormat
+	}
+	w.Header().Add("Vary", "Accept")
+	return write(w, f, status, v)
+}
+
+// ErrorStatus returns the status to answer a failed call with. Calls that
+// ran out of time, such as queries past the request deadline, get 504
+// whatever status would be used for err otherwise.
+func ErrorStatus(err error, status int) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return status
+}
+
+func write(w http.ResponseWriter, f format, status int, v interface{}) error {
+	w.Header().Set("Content-Type", f.mediaType)
+	w.WriteHeader(status)
+	return f.codec.Encode(w, v)
+}
+
+// CodecOf returns the codec Render uses for r.
+func CodecOf(r *http.Request) Codec {
+	if f, ok := r.Context().Value(contextKey{}).(format); ok {
+		return f.codec
+	}
+	return JSON
+}
+
+// Decode reads the request body into v in the format of its Content-Type,
+// JSON when it has none.
+func Decode(r *http.Request, v interface{}) error {
+	codec, err := requestCodec(r)
+	if err != nil {
+		return err
+	}
+	return codec.Decode(r.Body, v)
+}
+
+func requestCodec(r *http.Request) (Codec, error) {
+	header := r.Header.Get("Content-Type")
+	if header == "" {
+		return JSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	for _, c := range Codecs {
+		for _, t := range c.MediaTypes() {
+			if t == mediaType {
+				return c, nil
+			}
+		}
+	}
+	return nil, ErrUnsupportedMediaType
+}
+
+func containsMediaType(list []string, header string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	for _, t := range list {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptable(alsoAccepted []string) []string {
+	var types []string
+	for _, c := range Codecs {
+		types = append(types, c.MediaTypes()[0])
+	}
+	return append(types, alsoAccepted...)
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiate returns the format of the candidate the client accepts with the
+// highest quality; among equals the first one, so JSON wins over */*.
+func negotiate(accept string, alsoAccepted []string) (format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return defaultFormat, true
+	}
+	ranges := parseAccept(accept)
+
+	var best format
+	var bestQ float64
+	try := func(mediaType string, codec Codec) {
+		if q := quality(ranges, mediaType); q > bestQ {
+			best, bestQ = format{codec: codec, mediaType: mediaType}, q
+		}
+	}
+	for _, c := range Codecs {
+		for _, t := range c.MediaTypes() {
+			try(t, c)
+		}
+	}
+	for _, t := range alsoAccepted {
+		try(t, nil)
+	}
+	if bestQ == 0 {
+		return format{}, false
+	}
+	if best.codec == nil {
+		// the handler writes the body itself; only errors use the codec
+		return defaultFormat, true
+	}
+	return best, true
+}
+
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// quality is the q of the most specific range matching mediaType, 0 when
+// none matches.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch r.mediaType {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
@@ -0,0 +1,171 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type item struct {
+	XMLName xml.Name  `json:"-" xml:"item"`
+	ID      uuid.UUID `json:"id" xml:"id"`
+	Name    string    `json:"name" xml:"name"`
+	Price   float64   `json:"price" xml:"price"`
+	SKU     string    `json:"sku,omitempty" xml:"sku,omitempty"`
+}
+
+var testID = uuid.MustParse("0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10")
+
+// echo decodes an item from the body, or uses a fixed one on GET, and
+// renders it back.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	v := item{ID: testID, Name: "Product", Price: 10.5}
+	if r.Method != http.MethodGet {
+		if err := Decode(r, &v); err != nil {
+			Render(w, r, http.StatusBadRequest, errorOutput{Message: err.Error()})
+			return
+		}
+	}
+	Render(w, r, http.StatusOK, v)
+})
+
+func serve(handler http.Handler, method, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/items", strings.NewReader(body))
+	if body == "" {
+		req.ContentLength = 0
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                      MediaTypeJSON,
+		"*/*":                   MediaTypeJSON,
+		"application/*":         MediaTypeJSON,
+		"application/xml":       MediaTypeXML,
+		"text/xml":              "text/xml",
+		"application/x-msgpack": "application/x-msgpack",
+		"application/json;q=0.5, application/xml": MediaTypeXML,
+		"application/msgpack, */*;q=0.1":          MediaTypeMsgPack,
+		"text/html, */*;q=0.8":                    MediaTypeJSON,
+		"application/xml;q=0, */*":                MediaTypeJSON,
+		"application/x-ndjson":                    MediaTypeJSON,
+	}
+	for accept, want := range tests {
+		f, ok := negotiate(accept, []string{"application/x-ndjson"})
+		if assert.True(t, ok, accept) {
+			assert.Equal(t, want, f.mediaType, accept)
+		}
+	}
+
+	_, ok := negotiate("text/html", nil)
+	assert.False(t, ok)
+	_, ok = negotiate("application/json;q=0", nil)
+	assert.False(t, ok)
+}
+
+func TestRenderJSON(t *testing.T) {
+	rec := serve(Negotiate()(echo), http.MethodGet, "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MediaTypeJSON, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+	assert.JSONEq(t, `{"id":"0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10","name":"Product","price":10.5}`, rec.Body.String())
+}
+
+func TestRenderXML(t *testing.T) {
+	rec := serve(Negotiate()(echo), http.MethodGet, "", map[string]string{"Accept": "application/xml"})
+	assert.Equal(t, MediaTypeXML, rec.Header().Get("Content-Type"))
+	assert.Equal(t, xml.Header+`<item><id>0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10</id><name>Product</name><price>10.5</price></item>`, rec.Body.String())
+}
+
+func TestRenderMsgPack(t *testing.T) {
+	rec := serve(Negotiate()(echo), http.MethodGet, "", map[string]string{"Accept": "application/msgpack"})
+	assert.Equal(t, MediaTypeMsgPack, rec.Header().Get("Content-Type"))
+
+	var got map[string]interface{}
+	assert.Nil(t, msgpack.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, map[string]interface{}{"id": testID.String(), "name": "Product", "price": 10.5}, got)
+}
+
+func TestDecodeEachFormat(t *testing.T) {
+	packed, _ := msgpack.Marshal(map[string]interface{}{"id": testID.String(), "name": "Packed", "price": 3, "sku": "P-1"})
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"", `{"id":"0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10","name":"Packed","price":3,"sku":"P-1"}`},
+		{"application/json; charset=utf-8", `{"id":"0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10","name":"Packed","price":3,"sku":"P-1"}`},
+		{"application/xml", `<item><id>0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10</id><name>Packed</name><price>3</price><sku>P-1</sku></item>`},
+		{"application/msgpack", string(packed)},
+	}
+	for _, tt := range tests {
+		rec := serve(Negotiate()(echo), http.MethodPost, tt.body, map[string]string{"Content-Type": tt.contentType})
+		assert.Equal(t, http.StatusOK, rec.Code, tt.contentType)
+		assert.JSONEq(t, `{"id":"0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10","name":"Packed","price":3,"sku":"P-1"}`, rec.Body.String(), tt.contentType)
+	}
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	rec := serve(Negotiate()(echo), http.MethodGet, "", map[string]string{"Accept": "text/html"})
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Equal(t, MediaTypeJSON, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "application/msgpack")
+}
+
+func TestNegotiateUnsupportedMediaType(t *testing.T) {
+	rec := serve(Negotiate()(echo), http.MethodPost, "name=Product", map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+		"Accept":       "application/xml",
+	})
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	// the error is rendered in the accepted format
+	assert.Equal(t, MediaTypeXML, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<error><message>request bodies must be")
+
+	// types the handler reads itself pass through
+	rec = serve(Negotiate("text/csv")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})), http.MethodPost, "name\nProduct\n", map[string]string{"Content-Type": "text/csv"})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestRenderList(t *testing.T) {
+	list := List{Root: "items", Name: "item", Items: []item{
+		{ID: testID, Name: "Product 1", Price: 1},
+		{ID: testID, Name: "Product 2", Price: 2, SKU: "P-2"},
+	}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, JSON.Encode(&buf, list))
+	assert.True(t, strings.HasPrefix(buf.String(), `[{"id":`), buf.String())
+
+	buf.Reset()
+	assert.Nil(t, XML.Encode(&buf, list))
+	assert.Equal(t, xml.Header+`<items>`+
+		`<item><id>0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10</id><name>Product 1</name><price>1</price></item>`+
+		`<item><id>0b0c5a8e-3f0e-4a55-8c4b-2d1f7f1e9a10</id><name>Product 2</name><price>2</price><sku>P-2</sku></item>`+
+		`</items>`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, MsgPack.Encode(&buf, list))
+	var got []item
+	assert.Nil(t, MsgPack.Decode(&buf, &got))
+	assert.Equal(t, list.Items, got)
+}
+
+func TestRenderWithoutNegotiate(t *testing.T) {
+	rec := serve(echo, http.MethodGet, "", map[string]string{"Accept": "application/xml"})
+	assert.Equal(t, MediaTypeJSON, rec.Header().Get("Content-Type"))
+}
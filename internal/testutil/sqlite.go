@@ -0,0 +1,30 @@
+// Package testutil holds fixtures shared by the tests of several packages.
+package testutil
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// NewSQLite opens an in-memory database with the tables of models, closed
+// when the test ends. It is limited to one connection, as every connection
+// to file::memory: opens a different database.
+func NewSQLite(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
@@ -0,0 +1,64 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Secret from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := Code(rfcSecret, Step(now))
+
+	step, ok := Validate(rfcSecret, code, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	step, ok = Validate(rfcSecret, code, now.Add(Period*time.Second), 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	_, ok = Validate(rfcSecret, code, now.Add(2*Period*time.Second), 1)
+	assert.False(t, ok)
+
+	_, ok = Validate(rfcSecret, "12345", now, 1)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.Nil(t, err)
+	assert.Len(t, secret, 32)
+
+	_, err = Code(secret, 1)
+	assert.Nil(t, err)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Go Expert API", "1y3t3@example.com", rfcSecret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Go%20Expert%20API:1y3t3@example.com?"))
+	assert.Contains(t, uri, "secret="+rfcSecret)
+	assert.Contains(t, uri, "issuer=Go+Expert+API")
+}